@@ -1,10 +1,13 @@
 package assets
 
 import (
+	"path/filepath"
+	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestRenderAgentSystemPrompt(t *testing.T) {
@@ -133,3 +136,47 @@ func TestEmbeddedFS(t *testing.T) {
 		assert.NotEmpty(t, entries)
 	})
 }
+
+func TestToolInteractivePatterns(t *testing.T) {
+	content, err := Tools.ReadFile(filepath.Join(ToolsDir, "kubectl.yaml"))
+	require.NoError(t, err)
+	var definition struct {
+		Interactive []string `yaml:"interactive"`
+	}
+	require.NoError(t, yaml.Unmarshal(content, &definition))
+
+	interactive := func(command string) bool {
+		for _, pattern := range definition.Interactive {
+			if regexp.MustCompile(pattern).MatchString(command) {
+				return true
+			}
+		}
+		return false
+	}
+
+	tests := []struct {
+		command  string
+		expected bool
+	}{
+		{"kubectl exec -it api -- sh", true},
+		{"kubectl exec -n prod api -ti -- bash", true},
+		{"kubectl exec api -i -t -- sh", true},
+		{"kubectl attach api --tty", true},
+		{"kubectl attach api --tty=true -i", true},
+		{"kubectl run debug --rm -it --image=busybox -- sh", true},
+		{"kubectl edit deployment api", true},
+		{"kubectl exec api -- ls", false},
+		{"kubectl exec pod -- ls -t", false},
+		{"kubectl exec api -- sh -c 'ls -it'", false},
+		{"kubectl exec api --tty=false -- ls", false},
+		{"kubectl exec api --timeout=5s -- top", false},
+		{"kubectl run debug --image=busybox --restart=Never -- sleep 10", false},
+		{"kubectl get pods -t", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			assert.Equal(t, tt.expected, interactive(tt.command))
+		})
+	}
+}
@@ -3,13 +3,22 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"sync"
 
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/datolabs-io/opsy/internal/agent"
+	"github.com/datolabs-io/opsy/internal/audit"
 	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/datolabs-io/opsy/internal/headless"
+	"github.com/datolabs-io/opsy/internal/llm"
+	"github.com/datolabs-io/opsy/internal/session"
 	"github.com/datolabs-io/opsy/internal/thememanager"
 	"github.com/datolabs-io/opsy/internal/tool"
 	"github.com/datolabs-io/opsy/internal/toolmanager"
@@ -19,11 +28,45 @@ import (
 const (
 	// ErrNoTaskProvided is the error message for no task provided.
 	ErrNoTaskProvided = "no task provided"
+	// ErrSessionsDisabled is the error message for resuming a session while the sessions are disabled.
+	ErrSessionsDisabled = "sessions are disabled in the configuration"
+
+	// commandSessions is the command listing and showing the persisted sessions.
+	commandSessions = "sessions"
+	// commandResume is the command resuming a persisted session.
+	commandResume = "resume"
+	// commandAudit is the command verifying the audit log.
+	commandAudit = "audit"
+	// auditUsage is the usage of the audit command.
+	auditUsage = "usage: opsy audit verify [path]"
+	// sessionsUsage is the usage of the sessions command.
+	sessionsUsage = "usage: opsy sessions list | opsy sessions show <id>"
+	// resumeUsage is the usage of the resume command.
+	resumeUsage = "usage: opsy resume <id> [task]"
+	// resumeTask is the instruction sending a resumed session back to work, unless another one is given.
+	resumeTask = "Resume the task: continue from where you left off, or summarize the result if it is already complete."
+
+	// dryRunHeader is the header of the commands printed at the end of a dry run.
+	dryRunHeader = "Dry run: the task would have executed the following commands:"
+	// dryRunEmpty is the message printed at the end of a dry run without commands.
+	dryRunEmpty = "Dry run: the task would not have executed any commands."
+)
+
+var (
+	// dryRun is the flag enabling the dry-run mode.
+	dryRun = flag.Bool("dry-run", false, "record the commands the task would execute instead of executing them")
+	// runReadOnly is the flag allowing read-only commands to run in dry-run mode.
+	runReadOnly = flag.Bool("run-read-only", false, "execute read-only commands in dry-run mode")
+	// headlessMode is the flag running the task without the TUI.
+	headlessMode = flag.Bool("headless", false, "run the task without the TUI, printing its progress to stdout")
+	// outputFormat is the flag selecting the output format of the headless mode.
+	outputFormat = flag.String("output", string(headless.FormatText), "output format of the headless mode: text or json (implies --headless)")
 )
 
 // main is the entry point for the Opsy application.
 func main() {
 	ctx := context.Background()
+	flag.Parse()
 
 	task, err := getTask()
 	if err != nil {
@@ -35,33 +78,83 @@ func main() {
 		log.Fatal(err)
 	}
 
+	conf := cfg.GetConfig()
+	if *dryRun {
+		conf.Tools.Exec.DryRun.Enabled = true
+	}
+	if *runReadOnly {
+		conf.Tools.Exec.DryRun.RunReadOnly = true
+	}
+
 	logger, err := cfg.GetLogger()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	store := session.New(session.WithConfig(conf), session.WithLogger(logger))
+	if task == commandSessions {
+		os.Exit(runSessions(os.Stdout, store, flag.Args()[1:]))
+	}
+	if task == commandAudit {
+		os.Exit(runAudit(os.Stdout, conf.Audit.Path, flag.Args()[1:]))
+	}
+
+	var history []llm.Message
+	var sess *session.Session
+	if task == commandResume {
+		if flag.NArg() < 2 {
+			fmt.Fprintln(os.Stderr, resumeUsage)
+			os.Exit(2)
+		}
+		if !conf.Sessions.Enabled {
+			log.Fatal(ErrSessionsDisabled)
+		}
+		if sess, err = store.Load(flag.Arg(1)); err != nil {
+			log.Fatal(err)
+		}
+		history = sess.Messages
+		task = resumeTask
+		if flag.NArg() > 2 {
+			task = flag.Arg(2)
+		}
+	}
+
 	logger.With("task", task).Info("Started Opsy")
 
+	format, err := headless.ParseFormat(*outputFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	themeManager := thememanager.New(thememanager.WithLogger(logger))
-	if err := themeManager.LoadTheme(cfg.GetConfig().UI.Theme); err != nil {
+	if err := themeManager.LoadTheme(conf.UI.Theme); err != nil {
 		log.Fatal(err)
 	}
 
 	communication := &agent.Communication{
-		Commands: make(chan tool.Command),
-		Messages: make(chan agent.Message),
-		Status:   make(chan agent.Status),
+		Commands:  make(chan tool.Command),
+		Messages:  make(chan agent.Message),
+		Status:    make(chan agent.Status),
+		Approvals: make(chan tool.ApprovalRequest),
+		Output:    make(chan tool.CommandOutput),
+		Usage:     make(chan agent.Usage),
+		Tools:     make(chan agent.ToolRun),
+		Terminal:  make(chan tool.TerminalRequest),
 	}
 
-	agnt := agent.New(
-		agent.WithConfig(cfg.GetConfig()),
+	agentOpts := []agent.Option{
+		agent.WithConfig(conf),
 		agent.WithLogger(logger),
 		agent.WithContext(ctx),
 		agent.WithCommunication(communication),
-	)
+	}
+	if conf.Audit.Enabled {
+		agentOpts = append(agentOpts, agent.WithAuditLog(audit.New(audit.WithConfig(conf), audit.WithLogger(logger))))
+	}
+	agnt := agent.New(agentOpts...)
 
 	toolManager := toolmanager.New(
-		toolmanager.WithConfig(cfg.GetConfig()),
+		toolmanager.WithConfig(conf),
 		toolmanager.WithLogger(logger),
 		toolmanager.WithContext(ctx),
 		toolmanager.WithAgent(agnt),
@@ -70,21 +163,63 @@ func main() {
 		log.Fatal(err)
 	}
 
+	var recorder *session.Recorder
+	if conf.Sessions.Enabled {
+		if sess == nil {
+			if sess, err = store.Create(task); err != nil {
+				log.Fatal(err)
+			}
+		}
+		recorder = session.NewRecorder(store, sess)
+		logger.With("session", sess.ID).Info("Session started")
+	}
+	conversation := agent.NewConversation(agent.WithHistory(history), agent.WithSaveHandler(recorder.SetMessages))
+	origin := audit.Origin{}
+	if sess != nil {
+		origin.SessionID = sess.ID
+	}
+
+	if *headlessMode || isFlagSet("output") {
+		printer := headless.New(headless.WithFormat(format), headless.WithLogger(logger))
+		origin.Task = task
+		os.Exit(runHeadless(audit.WithOrigin(ctx, origin), task, agnt, toolManager.GetTools(), communication, printer,
+			conversation, recorder, logger))
+	}
+
+	headerTask := task
+	if sess != nil && len(history) > 0 {
+		headerTask = sess.Task
+	}
+
+	followUps := make(chan string)
 	tui := tui.New(
 		tui.WithTheme(themeManager.GetTheme()),
-		tui.WithConfig(cfg.GetConfig()),
-		tui.WithTask(task),
+		tui.WithConfig(conf),
+		tui.WithTask(headerTask),
 		tui.WithToolsCount(len(toolManager.GetTools())),
+		tui.WithFollowUps(followUps),
+		tui.WithCancelTask(agnt.Cancel),
 	)
 	p := tea.NewProgram(tui, tea.WithAltScreen(), tea.WithMouseCellMotion(), tea.WithContext(ctx))
 
 	go func() {
-		if _, err := agnt.Run(&tool.RunOptions{Task: task, Tools: toolManager.GetTools()}, ctx); err != nil {
-			communication.Status <- agent.StatusError
-			logger.With("task", task).Error("Opsy finished with error", "error", err)
-		} else {
-			communication.Status <- agent.StatusFinished
-			logger.With("task", task).Info("Opsy finished")
+		for {
+			recorder.Start(task)
+			origin.Task = task
+			outputs, err := agnt.Continue(conversation, &tool.RunOptions{Task: task, Tools: toolManager.GetTools()},
+				audit.WithOrigin(ctx, origin))
+			recorder.Finish(outputs, err)
+			logger.With("task", task).With("usage", agnt.Usage()).Info("Token usage")
+			if err != nil {
+				communication.Status <- agent.StatusError
+				logger.With("task", task).Error("Opsy finished with error", "error", err)
+			} else {
+				communication.Status <- agent.StatusFinished
+				logger.With("task", task).Info("Opsy finished")
+			}
+
+			task = <-followUps
+			logger.With("task", task).Info("Follow-up received")
 		}
 	}()
 
@@ -94,8 +229,14 @@ func main() {
 		}
 	}()
 
+	var mu sync.Mutex
+	commands := []tool.Command{}
 	go func() {
 		for msg := range communication.Commands {
+			mu.Lock()
+			commands = append(commands, msg)
+			mu.Unlock()
+			recorder.AddCommands(msg)
 			p.Send(msg)
 		}
 	}()
@@ -106,15 +247,170 @@ func main() {
 		}
 	}()
 
-	if _, err := p.Run(); err != nil {
+	go func() {
+		for msg := range communication.Approvals {
+			p.Send(msg)
+		}
+	}()
+
+	go func() {
+		for msg := range communication.Output {
+			p.Send(msg)
+		}
+	}()
+
+	go func() {
+		for msg := range communication.Usage {
+			p.Send(msg)
+		}
+	}()
+
+	go func() {
+		for msg := range communication.Tools {
+			p.Send(msg)
+		}
+	}()
+
+	go func() {
+		for msg := range communication.Terminal {
+			p.Send(msg)
+		}
+	}()
+
+	_, err = p.Run()
+	recorder.Interrupt()
+	if err != nil {
 		log.Fatal(err)
 	}
+
+	if conf.Tools.Exec.DryRun.Enabled {
+		mu.Lock()
+		printDryRun(os.Stdout, commands)
+		mu.Unlock()
+	}
+}
+
+// runHeadless runs the task without the TUI, printing its progress and summary, and returns the exit code.
+func runHeadless(ctx context.Context, task string, agnt *agent.Agent, tools map[string]tool.Tool,
+	communication *agent.Communication, printer *headless.Printer, conversation *agent.Conversation,
+	recorder *session.Recorder, logger *slog.Logger) int {
+	printerCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		printer.Run(printerCtx, communication)
+		close(done)
+	}()
+
+	recorder.Start(task)
+	outputs, err := agnt.Continue(conversation, &tool.RunOptions{Task: task, Tools: tools}, ctx)
+	cancel()
+	<-done
+	recorder.AddCommands(printer.Commands()...)
+	recorder.Finish(outputs, err)
+
+	summary := headless.Summary{
+		Task:     task,
+		Status:   agent.StatusFinished,
+		Outputs:  outputs,
+		Commands: printer.Commands(),
+		Usage:    agnt.Usage(),
+	}
+	logger.With("task", task).With("usage", summary.Usage).Info("Token usage")
+
+	if err != nil {
+		summary.Status = agent.StatusError
+		summary.Error = err.Error()
+		logger.With("task", task).Error("Opsy finished with error", "error", err)
+	} else {
+		logger.With("task", task).Info("Opsy finished")
+	}
+
+	printer.PrintSummary(summary)
+	if summary.Status == agent.StatusError {
+		return 1
+	}
+
+	return 0
+}
+
+// runSessions runs the sessions command, listing the sessions or showing one of them, and returns the exit code.
+func runSessions(w io.Writer, store *session.Store, args []string) int {
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		sessions, err := store.List()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		session.WriteList(w, sessions)
+	case len(args) == 2 && args[0] == "show":
+		s, err := store.Load(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		session.WriteDetails(w, s)
+	default:
+		fmt.Fprintln(os.Stderr, sessionsUsage)
+		return 2
+	}
+
+	return 0
+}
+
+// runAudit runs the audit command, verifying the hash chain of the audit log, and returns the exit code.
+func runAudit(w io.Writer, path string, args []string) int {
+	if len(args) == 0 || len(args) > 2 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, auditUsage)
+		return 2
+	}
+	if len(args) == 2 {
+		path = args[1]
+	}
+
+	entries, err := audit.VerifyFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v (%d valid entries before)\n", path, err, entries)
+		return 1
+	}
+
+	fmt.Fprintf(w, "%s: %d entries, hash chain intact\n", path, entries)
+	return 0
+}
+
+// isFlagSet returns true if the flag with the given name was set on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
+
+// printDryRun prints the ordered list of commands the task would have executed.
+func printDryRun(w io.Writer, commands []tool.Command) {
+	if len(commands) == 0 {
+		fmt.Fprintln(w, dryRunEmpty)
+		return
+	}
+
+	fmt.Fprintln(w, dryRunHeader)
+	for i, cmd := range commands {
+		line := fmt.Sprintf("%3d. [%s] %s", i+1, cmd.Location(), cmd.Command)
+		if !cmd.DryRun {
+			line += " (executed)"
+		}
+		fmt.Fprintln(w, line)
+	}
 }
 
 // getTask returns the task from the command line arguments.
 func getTask() (string, error) {
-	if len(os.Args) > 1 && os.Args[1] != "" {
-		return os.Args[1], nil
+	if task := flag.Arg(0); task != "" {
+		return task, nil
 	}
 
 	return "", errors.New(ErrNoTaskProvided)
@@ -2,19 +2,21 @@ package agent
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/datolabs-io/opsy/assets"
+	"github.com/datolabs-io/opsy/internal/audit"
 	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/datolabs-io/opsy/internal/llm"
 	"github.com/datolabs-io/opsy/internal/tool"
-
-	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/option"
-	"github.com/anthropics/anthropic-sdk-go/packages/param"
 )
 
 const (
@@ -22,6 +24,16 @@ const (
 	ErrNoRunOptions = "no run options provided"
 	// ErrNoTaskProvided is the error returned when no task is provided.
 	ErrNoTaskProvided = "no task provided"
+	// ErrNoApprovalChannel is the error returned when a command approval is requested without an approval channel.
+	ErrNoApprovalChannel = "no approval channel configured"
+	// ErrNoTerminalChannel is the error returned when an interactive command is run without a terminal channel.
+	ErrNoTerminalChannel = "no terminal channel configured"
+	// ErrBudgetExceeded is the error returned when the task reaches a limit of its budget.
+	ErrBudgetExceeded = "task budget exceeded"
+	// ErrNoProvider is the error returned when the agent runs without an LLM provider.
+	ErrNoProvider = "no LLM provider configured"
+	// ErrCancelled is the error returned when the user cancels the task.
+	ErrCancelled = "task cancelled by the user"
 
 	// StatusReady is the status of the agent when it is ready to run.
 	StatusReady = "Ready"
@@ -31,22 +43,40 @@ const (
 	StatusFinished = "Finished"
 	// StatusError is the status of the agent when it has encountered an error.
 	StatusError = "Error"
+	// StatusRetrying is the status of the agent when it retries a failed LLM request.
+	StatusRetrying = "Retrying (%d/%d)…"
+
+	// statusOverloaded is the HTTP status code returned by the Anthropic API when it is overloaded.
+	statusOverloaded = 529
+	// messageCancelled is the message shown when the task is stopped because the user cancelled it.
+	messageCancelled = "Stopped: the task was cancelled by the user."
 )
 
+// errCancelled is the error returned when the user cancels the task.
+var errCancelled = errors.New(ErrCancelled)
+
 // Status is the status of the agent.
 type Status string
 
 // Agent is a struct that contains the state of the agent.
 type Agent struct {
-	client        *anthropic.Client
+	provider      llm.Provider
 	ctx           context.Context
 	cfg           config.Configuration
 	logger        *slog.Logger
 	communication *Communication
+	usage         *usageTracker
+	auditLog      *audit.Log
+	// runs are the cancel functions of the running tasks, by run number.
+	runs    map[uint64]context.CancelCauseFunc
+	lastRun uint64
+	mu      sync.Mutex
 }
 
 // Message is a struct that contains a message from the agent.
 type Message struct {
+	// ID identifies a streamed message; a message with the same ID replaces the previously sent version.
+	ID string
 	// Tool is the name of the tool that sent the message.
 	Tool string
 	// Message is the message from the tool.
@@ -57,9 +87,14 @@ type Message struct {
 
 // Communication is a struct that contains the communication channels for the agent.
 type Communication struct {
-	Commands chan tool.Command
-	Messages chan Message
-	Status   chan Status
+	Commands  chan tool.Command
+	Messages  chan Message
+	Status    chan Status
+	Approvals chan tool.ApprovalRequest
+	Output    chan tool.CommandOutput
+	Usage     chan Usage
+	Tools     chan ToolRun
+	Terminal  chan tool.TerminalRequest
 }
 
 // Option is a function that configures the Agent.
@@ -77,23 +112,34 @@ func New(opts ...Option) *Agent {
 		cfg:    config.New().GetConfig(),
 		logger: slog.New(slog.DiscardHandler),
 		communication: &Communication{
-			Commands: make(chan tool.Command),
-			Messages: make(chan Message),
-			Status:   make(chan Status),
+			Commands:  make(chan tool.Command),
+			Messages:  make(chan Message),
+			Status:    make(chan Status),
+			Approvals: make(chan tool.ApprovalRequest),
+			Output:    make(chan tool.CommandOutput),
+			Usage:     make(chan Usage),
+			Terminal:  make(chan tool.TerminalRequest),
 		},
+		usage: newUsageTracker(),
+		runs:  map[uint64]context.CancelCauseFunc{},
 	}
 
 	for _, opt := range opts {
 		opt(a)
 	}
 
-	if a.cfg.Anthropic.APIKey != "" {
-		c := anthropic.NewClient(option.WithAPIKey(a.cfg.Anthropic.APIKey))
-		a.client = &c
+	if a.provider == nil {
+		provider, err := llm.New(a.cfg, llm.WithLogger(a.logger))
+		if err != nil {
+			a.logger.With("error", err).Debug("LLM provider not initialized.")
+		} else {
+			a.provider = provider
+		}
 	}
 
-	a.logger.WithGroup("config").With("max_tokens", a.cfg.Anthropic.MaxTokens).With("model", a.cfg.Anthropic.Model).
-		With("temperature", a.cfg.Anthropic.Temperature).Debug("Agent initialized.")
+	model := llm.ModelFromConfig(a.cfg)
+	a.logger.WithGroup("config").With("provider", model.Provider).With("max_tokens", model.MaxTokens).
+		With("model", model.Name).With("temperature", model.Temperature).Debug("Agent initialized.")
 
 	return a
 }
@@ -119,10 +165,17 @@ func WithLogger(logger *slog.Logger) Option {
 	}
 }
 
-// WithClient sets the client for the agent.
-func WithClient(client *anthropic.Client) Option {
+// WithProvider sets the LLM provider for the agent.
+func WithProvider(provider llm.Provider) Option {
+	return func(a *Agent) {
+		a.provider = provider
+	}
+}
+
+// WithAuditLog sets the audit log every executed command is recorded in.
+func WithAuditLog(auditLog *audit.Log) Option {
 	return func(a *Agent) {
-		a.client = client
+		a.auditLog = auditLog
 	}
 }
 
@@ -133,8 +186,14 @@ func WithCommunication(communication *Communication) Option {
 	}
 }
 
-// Run runs the agent with the given task and tools.
+// Run runs the agent with the given task and tools in a new conversation.
 func (a *Agent) Run(opts *tool.RunOptions, ctx context.Context) ([]tool.Output, error) {
+	return a.Continue(NewConversation(), opts, ctx)
+}
+
+// Continue runs the agent with the given task and tools as the next message of the conversation. The conversation is
+// updated after each complete exchange with the LLM provider, so that it can be continued even if the task fails.
+func (a *Agent) Continue(conversation *Conversation, opts *tool.RunOptions, ctx context.Context) ([]tool.Output, error) {
 	if opts == nil {
 		return nil, errors.New(ErrNoRunOptions)
 	}
@@ -143,10 +202,30 @@ func (a *Agent) Run(opts *tool.RunOptions, ctx context.Context) ([]tool.Output,
 		return nil, errors.New(ErrNoTaskProvided)
 	}
 
+	if a.provider == nil {
+		return nil, errors.New(ErrNoProvider)
+	}
+
 	if ctx == nil {
 		ctx = a.ctx
 	}
 
+	ctx, cancel := a.startRun(ctx)
+	defer cancel()
+	ctx = a.withTaskUsage(ctx, opts.Caller)
+
+	if _, ok := tool.ApproverFromContext(ctx); !ok {
+		ctx = tool.WithApprover(ctx, a)
+	}
+
+	if _, ok := tool.OutputHandlerFromContext(ctx); !ok {
+		ctx = tool.WithOutputHandler(ctx, a)
+	}
+
+	if _, ok := tool.TerminalFromContext(ctx); !ok {
+		ctx = tool.WithTerminal(ctx, a)
+	}
+
 	prompt, err := assets.RenderAgentSystemPrompt(&assets.AgentSystemPromptData{
 		Shell: a.cfg.Tools.Exec.Shell,
 	})
@@ -163,118 +242,326 @@ func (a *Agent) Run(opts *tool.RunOptions, ctx context.Context) ([]tool.Output,
 	a.communication.Status <- StatusRunning
 
 	output := []tool.Output{}
-	messages := []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock(opts.Task))}
+	iterations := int64(0)
+	model := llm.ModelFromConfig(a.cfg)
+	messages := conversation.start(opts.Task)
+	conversation.save(messages)
 
 	for {
-		msg := anthropic.MessageNewParams{
-			Model:       anthropic.Model(a.cfg.Anthropic.Model),
-			MaxTokens:   a.cfg.Anthropic.MaxTokens,
-			System:      []anthropic.TextBlockParam{{Text: prompt}},
-			Messages:    messages,
-			Tools:       convertTools(opts.Tools),
-			Temperature: param.NewOpt(a.cfg.Anthropic.Temperature),
+		request := llm.Request{
+			Model:           model.Name,
+			MaxTokens:       model.MaxTokens,
+			System:          prompt,
+			Messages:        messages,
+			Tools:           convertTools(opts.Tools),
+			Temperature:     model.Temperature,
+			ParallelToolUse: a.cfg.Tools.Parallel.Enabled,
 		}
 
-		if len(opts.Tools) > 0 {
-			msg.ToolChoice = anthropic.ToolChoiceUnionParam{
-				OfAuto: &anthropic.ToolChoiceAutoParam{
-					DisableParallelToolUse: param.NewOpt(true),
-				},
+		if tool.CancelledByUser(ctx) {
+			logger.Info("Task cancelled by the user, stopping.")
+			// The tools report the cancellation in their results, so only the task itself shows a message:
+			if opts.Caller == "" {
+				a.communication.Messages <- Message{Message: messageCancelled, Timestamp: time.Now()}
 			}
+			return output, errCancelled
 		}
 
-		message, err := a.client.Messages.New(ctx, msg)
+		if reason, exceeded := a.budgetExceeded(ctx, iterations); exceeded {
+			logger.With("reason", reason).Error("Task budget exceeded, stopping.")
+			a.communication.Messages <- Message{
+				Tool:      opts.Caller,
+				Message:   fmt.Sprintf(messageBudgetExceeded, reason),
+				Timestamp: time.Now(),
+			}
+			return output, fmt.Errorf("%w: %s", errBudgetExceeded, reason)
+		}
+		iterations++
 
+		response, err := a.sendMessage(ctx, request, opts.Caller, logger)
+		if err != nil && tool.CancelledByUser(ctx) {
+			logger.Info("Task cancelled by the user while waiting for the LLM provider.")
+			return output, errCancelled
+		}
 		if err != nil {
-			// TODO(t-dabasinskas): Implement retry logic
-			logger.With("error", err).Error("Failed to send message to Anthropic API.")
+			logger.With("error", err).Error("Failed to send message to LLM provider.")
 			return nil, err
 		}
 
-		toolResults := []anthropic.ContentBlockParamUnion{}
-		for _, block := range message.Content {
-			switch block.Type {
-			case "text":
+		calls := toolCalls(response.Content, opts.Tools, logger)
+		a.executeTools(ctx, calls)
+
+		toolResults := []llm.Content{}
+		for _, call := range calls {
+			isError := false
+			resultBlockContent := ""
+
+			if errors.Is(call.err, errBudgetExceeded) {
+				return output, call.err
+			}
+			if call.err != nil {
+				logger.With("error", call.err).Error("Failed to execute tool.")
+				isError = true
+			}
+
+			toolOutput := call.output
+			if toolOutput == nil {
+				logger.With("tool_name", call.name).Warn("Tool has no output, skipping.")
+				continue
+			}
+
+			output = append(output, *toolOutput)
+
+			// Handle messages from all the tools except the Exec:
+			if toolOutput.Result != "" && toolOutput.ExecutedCommand == nil {
+				resultBlockContent = toolOutput.Result
+				isError = isError || toolOutput.IsError
 				a.communication.Messages <- Message{
 					Tool:      opts.Caller,
-					Message:   block.Text,
+					Message:   toolOutput.Result,
 					Timestamp: time.Now(),
 				}
-			case "tool_use":
-				isError := false
-				resultBlockContent := ""
-				toolInputs := map[string]any{}
-
-				if err := json.Unmarshal(block.Input, &toolInputs); err != nil {
-					logger.With("error", err).Error("Failed to unmarshal tool inputs.")
-					continue
-				}
+			}
+			logger.With("output", toolOutput).Warn(">>>>Tool result.")
+
+			// Handle messages from the Exec tool:
+			if toolOutput.ExecutedCommand != nil {
+				resultBlockContent = toolOutput.Result
+				isError = toolOutput.ExecutedCommand.ExitCode != 0
+				a.communication.Commands <- *toolOutput.ExecutedCommand
+				a.recordCommand(ctx, opts, *toolOutput.ExecutedCommand, logger)
+			}
 
-				var toolOutput *tool.Output
-				tool, ok := opts.Tools[block.Name]
-				if !ok {
-					logger.With("tool_name", block.Name).Warn("Tool not found, skipping.")
-					continue
-				}
+			toolResults = append(toolResults, llm.NewToolResult(call.id, resultBlockContent, isError))
+		}
 
-				toolOutput, err = tool.Execute(toolInputs, ctx)
-				if err != nil {
-					logger.With("error", err).Error("Failed to execute tool.")
-					isError = true
-				}
+		messages = append(messages, llm.Message{Role: llm.RoleAssistant, Content: response.Content})
+		if len(toolResults) == 0 {
+			conversation.save(messages)
+			break
+		}
 
-				if toolOutput == nil {
-					logger.With("tool_name", block.Name).Warn("Tool has no output, skipping.")
-					continue
-				}
+		messages = append(messages, llm.Message{Role: llm.RoleUser, Content: toolResults})
+		conversation.save(messages)
+	}
 
-				output = append(output, *toolOutput)
+	return output, nil
+}
 
-				// Handle messages from all the tools except the Exec:
-				if toolOutput.Result != "" && toolOutput.ExecutedCommand == nil {
-					resultBlockContent = toolOutput.Result
-					a.communication.Messages <- Message{
-						Tool:      opts.Caller,
-						Message:   toolOutput.Result,
-						Timestamp: time.Now(),
-					}
-				}
-				logger.With("output", toolOutput).Warn(">>>>Tool result.")
+// Cancel cancels the running tasks on behalf of the user. Their running commands are terminated and each task returns
+// ErrCancelled once the results of its running tools have been added to the conversation, so that it can be continued
+// with a follow-up.
+func (a *Agent) Cancel() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-				// Handle messages from the Exec tool:
-				if toolOutput.ExecutedCommand != nil {
-					resultBlockContent = toolOutput.ExecutedCommand.Output
-					isError = toolOutput.ExecutedCommand.ExitCode != 0
-					a.communication.Commands <- *toolOutput.ExecutedCommand
-				}
+	a.logger.With("tasks", len(a.runs)).Info("Cancelling the running tasks.")
+	for _, cancel := range a.runs {
+		tool.CancelByUser(cancel)
+	}
+}
+
+// startRun returns the context of a new run, which is cancelled by Cancel until the returned function is called.
+func (a *Agent) startRun(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastRun++
+	run := a.lastRun
+	a.runs[run] = cancel
+
+	return ctx, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		delete(a.runs, run)
+		cancel(nil)
+	}
+}
+
+// Approve sends the command to the approval channel and waits for the user's decision.
+func (a *Agent) Approve(ctx context.Context, cmd tool.Command) (tool.Approval, error) {
+	if a.communication.Approvals == nil {
+		return tool.Approval{}, errors.New(ErrNoApprovalChannel)
+	}
 
-				resultBlock := anthropic.NewToolResultBlock(block.ID, resultBlockContent, isError)
-				toolResults = append(toolResults, resultBlock)
+	// The request is dismissed as soon as the approval is no longer awaited:
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	request := tool.NewApprovalRequest(ctx, cmd)
+	select {
+	case a.communication.Approvals <- request:
+	case <-ctx.Done():
+		return tool.Approval{}, ctx.Err()
+	}
+
+	select {
+	case approval := <-request.Response:
+		return approval, nil
+	case <-ctx.Done():
+		return tool.Approval{}, ctx.Err()
+	}
+}
+
+// Attach sends the session of the interactive command to the terminal channel and waits until the user has finished
+// it.
+func (a *Agent) Attach(ctx context.Context, cmd tool.Command, session *tool.TerminalSession) error {
+	if a.communication.Terminal == nil {
+		return errors.New(ErrNoTerminalChannel)
+	}
+
+	request := tool.NewTerminalRequest(cmd, session)
+	select {
+	case a.communication.Terminal <- request:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-request.Response:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// HandleOutput sends the update on a running command to the output channel. Updates are dropped if there is no
+// output channel or the context is cancelled.
+func (a *Agent) HandleOutput(ctx context.Context, output tool.CommandOutput) {
+	if a.communication.Output == nil {
+		return
+	}
+
+	select {
+	case a.communication.Output <- output:
+	case <-ctx.Done():
+	}
+}
+
+// recordCommand records the executed command in the audit log, if any. A command which cannot be recorded is logged,
+// since it has already been executed.
+func (a *Agent) recordCommand(ctx context.Context, opts *tool.RunOptions, cmd tool.Command, logger *slog.Logger) {
+	if a.auditLog == nil {
+		return
+	}
+
+	if err := a.auditLog.Record(ctx, opts.Caller, opts.Task, cmd); err != nil {
+		logger.With("error", err).With("command", cmd.Command).Error("Failed to record command in the audit log.")
+	}
+}
+
+// sendMessage sends the request to the LLM provider, retrying requests that failed with a transient error.
+func (a *Agent) sendMessage(ctx context.Context, request llm.Request, caller string,
+	logger *slog.Logger) (*llm.Response, error) {
+	maxAttempts := max(a.cfg.Anthropic.Retry.MaxAttempts, 1)
+
+	for attempt := int64(1); ; attempt++ {
+		response, err := a.provider.Send(ctx, request, a.streamHandler(caller))
+		if err == nil {
+			if attempt > 1 {
+				a.communication.Status <- StatusRunning
 			}
+			a.recordUsage(ctx, response, caller, logger)
+			return response, nil
 		}
 
-		messages = append(messages, message.ToParam())
-		if len(toolResults) == 0 {
-			break
+		if attempt >= maxAttempts || !isRetryable(err) {
+			return nil, err
 		}
 
-		messages = append(messages, anthropic.NewUserMessage(toolResults...))
+		delay := a.retryDelay(err, attempt)
+		logger.With("error", err).With("attempt", attempt).With("delay", delay).
+			Warn("LLM request failed, retrying.")
+		a.communication.Status <- Status(fmt.Sprintf(StatusRetrying, attempt+1, maxAttempts))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
 	}
+}
 
-	return output, nil
+// streamHandler returns the handler sending the text blocks of a response as they are generated. Each text block is
+// sent as a message timestamped when the block started.
+func (a *Agent) streamHandler(caller string) llm.StreamHandler {
+	timestamps := map[string]time.Time{}
+
+	return func(update llm.TextUpdate) {
+		id := fmt.Sprintf("%s-%d", update.ResponseID, update.Index)
+		timestamp, ok := timestamps[id]
+		if !ok {
+			timestamp = time.Now()
+			timestamps[id] = timestamp
+		}
+
+		a.communication.Messages <- Message{
+			ID:        id,
+			Tool:      caller,
+			Message:   update.Text,
+			Timestamp: timestamp,
+		}
+	}
+}
+
+// retryDelay returns the delay before the next attempt. The delay requested by the API via the `retry-after`
+// headers takes precedence over the exponential backoff; both are capped at the maximum delay.
+func (a *Agent) retryDelay(err error, attempt int64) time.Duration {
+	retry := a.cfg.Anthropic.Retry
+	maxDelay := time.Duration(retry.MaxDelay * float64(time.Second))
+
+	var apiErr *llm.Error
+	if errors.As(err, &apiErr) && apiErr.Header != nil {
+		if delay, ok := retryAfter(apiErr.Header); ok {
+			return min(delay, maxDelay)
+		}
+	}
+
+	delay := min(retry.BaseDelay*math.Pow(2, float64(attempt-1)), retry.MaxDelay)
+	delay *= 1 + retry.Jitter*(2*rand.Float64()-1)
+
+	return time.Duration(delay * float64(time.Second))
+}
+
+// isRetryable returns true if the request failed with a rate limit, overloaded or server error.
+func isRetryable(err error) bool {
+	var apiErr *llm.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode == statusOverloaded ||
+		apiErr.StatusCode >= http.StatusInternalServerError
+}
+
+// retryAfter returns the delay requested by the `retry-after-ms` or `retry-after` headers.
+func retryAfter(header http.Header) (time.Duration, bool) {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
 }
 
-// convertTools converts the tools to the format required by the Anthropic SDK.
-func convertTools(tools map[string]tool.Tool) (anthropicTools []anthropic.ToolUnionParam) {
+// convertTools converts the tools to the format of the LLM requests.
+func convertTools(tools map[string]tool.Tool) (llmTools []llm.Tool) {
 	for _, t := range tools {
-		anthropicTools = append(anthropicTools, anthropic.ToolUnionParam{
-			OfTool: &anthropic.ToolParam{
-				Name:        t.GetName(),
-				Description: param.NewOpt(t.GetDescription()),
-				InputSchema: anthropic.ToolInputSchemaParam{
-					Properties: t.GetInputSchema().Properties,
-				},
-			},
+		llmTools = append(llmTools, llm.Tool{
+			Name:        t.GetName(),
+			Description: t.GetDescription(),
+			InputSchema: t.GetInputSchema(),
 		})
 	}
 	return
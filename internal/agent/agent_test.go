@@ -2,12 +2,20 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
-	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/datolabs-io/opsy/internal/audit"
 	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/datolabs-io/opsy/internal/llm"
 	"github.com/datolabs-io/opsy/internal/tool"
 	"github.com/invopop/jsonschema"
 	"github.com/stretchr/testify/assert"
@@ -42,7 +50,7 @@ func TestNew(t *testing.T) {
 		assert.NotNil(t, agent.cfg)
 		assert.NotNil(t, agent.logger)
 		assert.NotNil(t, agent.communication)
-		assert.Nil(t, agent.client) // No API key set
+		assert.Nil(t, agent.provider) // No API key set
 	})
 
 	t.Run("applies options", func(t *testing.T) {
@@ -65,20 +73,33 @@ func TestNew(t *testing.T) {
 		assert.Equal(t, ctx, agent.ctx)
 		assert.Equal(t, cfg, agent.cfg)
 		assert.Equal(t, comm, agent.communication)
-		assert.Nil(t, agent.client) // Agent without API key should have nil client
+		assert.Nil(t, agent.provider) // Agent without API key should have nil provider
 	})
 
-	t.Run("creates client when API key provided", func(t *testing.T) {
+	t.Run("creates provider when API key provided", func(t *testing.T) {
 		cfg := config.New().GetConfig()
 		cfg.Anthropic.APIKey = "test-key"
 		agent := New(WithConfig(cfg))
-		assert.NotNil(t, agent.client)
-		// Verify client is properly initialized by checking its type
-		assert.IsType(t, &anthropic.Client{}, agent.client)
+		require.NotNil(t, agent.provider)
+		assert.Equal(t, "Anthropic", agent.provider.Name())
+	})
+
+	t.Run("creates OpenAI-compatible provider", func(t *testing.T) {
+		cfg := config.New().GetConfig()
+		cfg.LLM.Provider = config.ProviderOpenAI
+		agent := New(WithConfig(cfg))
+		require.NotNil(t, agent.provider)
+		assert.Equal(t, "OpenAI", agent.provider.Name())
+	})
+
+	t.Run("uses provider option", func(t *testing.T) {
+		provider := llm.NewOpenAI(config.OpenAIConfiguration{})
+		agent := New(WithProvider(provider))
+		assert.Equal(t, provider, agent.provider)
 	})
 }
 
-// TestConvertTools tests tool conversion for Anthropic API
+// TestConvertTools tests tool conversion for LLM requests
 func TestConvertTools(t *testing.T) {
 	t.Run("converts single tool", func(t *testing.T) {
 		properties := orderedmap.New[string, *jsonschema.Schema]()
@@ -98,14 +119,12 @@ func TestConvertTools(t *testing.T) {
 			},
 		}
 
-		anthropicTools := convertTools(tools)
-		require.Len(t, anthropicTools, 1)
+		llmTools := convertTools(tools)
+		require.Len(t, llmTools, 1)
 
-		toolParam := anthropicTools[0].OfTool
-		require.NotNil(t, toolParam)
-		assert.Equal(t, "test", toolParam.Name)
-		assert.Equal(t, "A test tool", toolParam.Description.Value)
-		assert.NotNil(t, toolParam.InputSchema)
+		assert.Equal(t, "test", llmTools[0].Name)
+		assert.Equal(t, "A test tool", llmTools[0].Description)
+		assert.Equal(t, schema, llmTools[0].InputSchema)
 	})
 
 	t.Run("converts multiple tools", func(t *testing.T) {
@@ -132,27 +151,23 @@ func TestConvertTools(t *testing.T) {
 			},
 		}
 
-		anthropicTools := convertTools(tools)
-		require.Len(t, anthropicTools, 2)
+		llmTools := convertTools(tools)
+		require.Len(t, llmTools, 2)
 
 		// Verify both tools are present with correct values
 		foundTool1 := false
 		foundTool2 := false
 
-		for _, toolUnion := range anthropicTools {
-			toolParam := toolUnion.OfTool
-			require.NotNil(t, toolParam)
-
-			name := toolParam.Name
-			switch name {
+		for _, llmTool := range llmTools {
+			switch llmTool.Name {
 			case "tool1":
 				foundTool1 = true
-				assert.Equal(t, "First test tool", toolParam.Description.Value)
-				assert.NotNil(t, toolParam.InputSchema)
+				assert.Equal(t, "First test tool", llmTool.Description)
+				assert.NotNil(t, llmTool.InputSchema)
 			case "tool2":
 				foundTool2 = true
-				assert.Equal(t, "Second test tool", toolParam.Description.Value)
-				assert.NotNil(t, toolParam.InputSchema)
+				assert.Equal(t, "Second test tool", llmTool.Description)
+				assert.NotNil(t, llmTool.InputSchema)
 			}
 		}
 
@@ -162,8 +177,8 @@ func TestConvertTools(t *testing.T) {
 
 	t.Run("handles empty tools map", func(t *testing.T) {
 		tools := map[string]tool.Tool{}
-		anthropicTools := convertTools(tools)
-		assert.Empty(t, anthropicTools)
+		llmTools := convertTools(tools)
+		assert.Empty(t, llmTools)
 	})
 }
 
@@ -221,3 +236,582 @@ func TestCommunication(t *testing.T) {
 		assert.Equal(t, cmd, receivedCmd)
 	})
 }
+
+// TestApprove tests the command approval round trip through the communication channels
+func TestApprove(t *testing.T) {
+	cmd := tool.Command{Command: "kubectl delete pod test", WorkingDirectory: "/test/dir"}
+
+	t.Run("returns decision from approval channel", func(t *testing.T) {
+		comm := &Communication{Approvals: make(chan tool.ApprovalRequest)}
+		agent := New(WithCommunication(comm))
+
+		go func() {
+			request := <-comm.Approvals
+			assert.Equal(t, cmd, request.Command)
+			request.Response <- tool.Approval{Decision: tool.DecisionEdited, Command: "kubectl get pod test"}
+		}()
+
+		approval, err := agent.Approve(context.Background(), cmd)
+		require.NoError(t, err)
+		assert.Equal(t, tool.DecisionEdited, approval.Decision)
+		assert.Equal(t, "kubectl get pod test", approval.Command)
+	})
+
+	t.Run("returns error when context is cancelled", func(t *testing.T) {
+		comm := &Communication{Approvals: make(chan tool.ApprovalRequest)}
+		agent := New(WithCommunication(comm))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := agent.Approve(ctx, cmd)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("closes the request once the approval is no longer awaited", func(t *testing.T) {
+		comm := &Communication{Approvals: make(chan tool.ApprovalRequest, 1)}
+		agent := New(WithCommunication(comm))
+		ctx, cancel := context.WithCancel(context.Background())
+
+		errs := make(chan error)
+		go func() {
+			_, err := agent.Approve(ctx, cmd)
+			errs <- err
+		}()
+		request := <-comm.Approvals
+		assert.NotNil(t, request.Done)
+
+		cancel()
+		assert.ErrorIs(t, <-errs, context.Canceled)
+		select {
+		case <-request.Done:
+		case <-time.After(time.Second):
+			t.Fatal("the approval request was not closed")
+		}
+	})
+
+	t.Run("returns error without approval channel", func(t *testing.T) {
+		agent := New(WithCommunication(&Communication{}))
+
+		_, err := agent.Approve(context.Background(), cmd)
+		assert.EqualError(t, err, ErrNoApprovalChannel)
+	})
+}
+
+// TestAttach tests handing the terminal over for interactive commands through the terminal channel
+func TestAttach(t *testing.T) {
+	cmd := tool.Command{Command: "aws sso login", WorkingDirectory: "/test/dir"}
+
+	t.Run("waits until the terminal channel responds", func(t *testing.T) {
+		comm := &Communication{Terminal: make(chan tool.TerminalRequest)}
+		agent := New(WithCommunication(comm))
+
+		go func() {
+			request := <-comm.Terminal
+			assert.Equal(t, cmd, request.Command)
+			request.Response <- errors.New("terminal unavailable")
+		}()
+
+		err := agent.Attach(context.Background(), cmd, nil)
+		assert.EqualError(t, err, "terminal unavailable")
+	})
+
+	t.Run("returns error when context is cancelled", func(t *testing.T) {
+		comm := &Communication{Terminal: make(chan tool.TerminalRequest)}
+		agent := New(WithCommunication(comm))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := agent.Attach(ctx, cmd, nil)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("returns error without terminal channel", func(t *testing.T) {
+		agent := New(WithCommunication(&Communication{}))
+
+		err := agent.Attach(context.Background(), cmd, nil)
+		assert.EqualError(t, err, ErrNoTerminalChannel)
+	})
+}
+
+// TestHandleOutput tests forwarding of running command output to the output channel
+func TestHandleOutput(t *testing.T) {
+	output := tool.CommandOutput{Command: tool.Command{ID: "cmd-1", Command: "kubectl logs -f api"}, Line: "started"}
+
+	t.Run("sends output to output channel", func(t *testing.T) {
+		comm := &Communication{Output: make(chan tool.CommandOutput, 1)}
+		agent := New(WithCommunication(comm))
+
+		agent.HandleOutput(context.Background(), output)
+		assert.Equal(t, output, <-comm.Output)
+	})
+
+	t.Run("drops output when context is cancelled", func(t *testing.T) {
+		comm := &Communication{Output: make(chan tool.CommandOutput)}
+		agent := New(WithCommunication(comm))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		agent.HandleOutput(ctx, output)
+		assert.Empty(t, comm.Output)
+	})
+
+	t.Run("drops output without output channel", func(t *testing.T) {
+		agent := New(WithCommunication(&Communication{}))
+		assert.NotPanics(t, func() { agent.HandleOutput(context.Background(), output) })
+	})
+}
+
+// testMessageStream is a minimal streamed response of the Anthropic Messages API.
+const testMessageStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"test-model","content":[],"stop_reason":null,"usage":{"input_tokens":1,"output_tokens":0}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"do"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"ne"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"exec","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"command\": \"ls\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":2}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+// newTestRetryAgent creates an agent sending requests to a test server which fails the given number of times.
+func newTestRetryAgent(t *testing.T, failures int32, code int, header http.Header) (*Agent, *atomic.Int32, *Communication) {
+	t.Helper()
+
+	requests := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(testMessageStream))
+	}))
+	agent, comm := newTestServerAgent(t, server)
+
+	return agent, requests, comm
+}
+
+// newTestServerAgent creates an agent sending requests to the test server, retrying them without delay unless the
+// server requests one.
+func newTestServerAgent(t *testing.T, server *httptest.Server) (*Agent, *Communication) {
+	t.Helper()
+	t.Cleanup(server.Close)
+
+	provider := llm.NewAnthropic(config.AnthropicConfiguration{APIKey: "test-key"}, llm.WithBaseURL(server.URL))
+	cfg := config.New().GetConfig()
+	cfg.Anthropic.Retry = config.RetryConfiguration{MaxAttempts: 3, BaseDelay: 0, MaxDelay: 60}
+	comm := &Communication{Status: make(chan Status, 10), Messages: make(chan Message, 10)}
+
+	return New(WithProvider(provider), WithConfig(cfg), WithCommunication(comm)), comm
+}
+
+// TestSendMessage tests the retry logic of requests to the LLM provider
+func TestSendMessage(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+
+	t.Run("retries overloaded and server errors", func(t *testing.T) {
+		for _, code := range []int{http.StatusTooManyRequests, statusOverloaded, http.StatusBadGateway} {
+			agent, requests, comm := newTestRetryAgent(t, 2, code, nil)
+
+			message, err := agent.sendMessage(context.Background(), llm.Request{}, "", logger)
+			require.NoError(t, err)
+			assert.Equal(t, "done", message.Content[0].Text)
+			assert.Equal(t, int32(3), requests.Load())
+			assert.Equal(t, Status("Retrying (2/3)…"), <-comm.Status)
+			assert.Equal(t, Status("Retrying (3/3)…"), <-comm.Status)
+			assert.Equal(t, Status(StatusRunning), <-comm.Status)
+		}
+	})
+
+	t.Run("retries overloaded error events of the stream", func(t *testing.T) {
+		requests := &atomic.Int32{}
+		agent, comm := newTestServerAgent(t, httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			if requests.Add(1) == 1 {
+				_, _ = w.Write([]byte(`event: message_start
+data: {"type":"message_start","message":{"id":"msg_0","type":"message","role":"assistant","model":"test-model","content":[],"stop_reason":null,"usage":{"input_tokens":10,"output_tokens":0}}}
+
+event: error
+data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}
+
+`))
+				return
+			}
+			_, _ = w.Write([]byte(testMessageStream))
+		})))
+
+		message, err := agent.sendMessage(context.Background(), llm.Request{}, "", logger)
+		require.NoError(t, err)
+		assert.Equal(t, "done", message.Content[0].Text)
+		assert.Equal(t, int32(2), requests.Load())
+		assert.Equal(t, Status("Retrying (2/3)…"), <-comm.Status)
+	})
+
+	t.Run("returns error after max attempts", func(t *testing.T) {
+		agent, requests, _ := newTestRetryAgent(t, 5, statusOverloaded, nil)
+
+		_, err := agent.sendMessage(context.Background(), llm.Request{}, "", logger)
+		var apiErr *llm.Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, statusOverloaded, apiErr.StatusCode)
+		assert.Equal(t, int32(3), requests.Load())
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		agent, requests, comm := newTestRetryAgent(t, 1, http.StatusBadRequest, nil)
+
+		_, err := agent.sendMessage(context.Background(), llm.Request{}, "", logger)
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), requests.Load())
+		assert.Empty(t, comm.Status)
+	})
+
+	t.Run("respects retry-after header", func(t *testing.T) {
+		agent, requests, _ := newTestRetryAgent(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After-Ms": {"50"}})
+
+		started := time.Now()
+		_, err := agent.sendMessage(context.Background(), llm.Request{}, "", logger)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), requests.Load())
+		assert.GreaterOrEqual(t, time.Since(started), 50*time.Millisecond)
+	})
+
+	t.Run("stops waiting when context is cancelled", func(t *testing.T) {
+		agent, requests, _ := newTestRetryAgent(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"60"}})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := agent.sendMessage(ctx, llm.Request{}, "", logger)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+}
+
+// TestStreamHandler tests sending of the responses as they are generated
+func TestStreamHandler(t *testing.T) {
+	t.Run("sends text as it is generated", func(t *testing.T) {
+		agent, _, comm := newTestRetryAgent(t, 0, http.StatusOK, nil)
+
+		response, err := agent.sendMessage(context.Background(), llm.Request{}, "git", slog.New(slog.DiscardHandler))
+		require.NoError(t, err)
+
+		first, second := <-comm.Messages, <-comm.Messages
+		assert.Equal(t, "msg_1-0", first.ID)
+		assert.Equal(t, "do", first.Message)
+		assert.Equal(t, "git", first.Tool)
+		assert.Equal(t, first.ID, second.ID)
+		assert.Equal(t, "done", second.Message)
+		assert.Equal(t, first.Timestamp, second.Timestamp)
+		assert.Empty(t, comm.Messages)
+
+		require.Len(t, response.Content, 2)
+		assert.Equal(t, "done", response.Content[0].Text)
+		assert.Equal(t, llm.ContentToolUse, response.Content[1].Type)
+		assert.Equal(t, llm.StopToolUse, response.StopReason)
+	})
+}
+
+// requestRecorder is an LLM provider recording the requests sent to another provider.
+type requestRecorder struct {
+	llm.Provider
+	requests []llm.Request
+}
+
+func (r *requestRecorder) Send(ctx context.Context, request llm.Request, handler llm.StreamHandler) (*llm.Response, error) {
+	r.requests = append(r.requests, request)
+	return r.Provider.Send(ctx, request, handler)
+}
+
+// TestRun tests the task loop end-to-end with a replayed transcript
+func TestRun(t *testing.T) {
+	newReplayAgent := func(t *testing.T, turns []llm.Turn) (*Agent, *requestRecorder, *Communication) {
+		t.Helper()
+
+		provider := &requestRecorder{Provider: llm.NewReplay(turns)}
+		comm := &Communication{
+			Commands: make(chan tool.Command, 10),
+			Messages: make(chan Message, 10),
+			Status:   make(chan Status, 10),
+		}
+
+		return New(WithProvider(provider), WithCommunication(comm)), provider, comm
+	}
+
+	t.Run("executes tools until the model finishes", func(t *testing.T) {
+		turns, err := llm.LoadTranscript("../llm/testdata/transcript.jsonl")
+		require.NoError(t, err)
+		agent, provider, comm := newReplayAgent(t, turns)
+		command := &tool.Command{Command: "ls", ExitCode: 0, Output: "file"}
+		tools := map[string]tool.Tool{"exec": &mockTool{
+			name:        "exec",
+			description: "Executes commands.",
+			schema:      &jsonschema.Schema{},
+			output:      &tool.Output{Result: "file", ExecutedCommand: command},
+		}}
+
+		output, err := agent.Run(&tool.RunOptions{Task: "list files", Tools: tools}, context.Background())
+		require.NoError(t, err)
+		require.Len(t, output, 1)
+		assert.Equal(t, "file", output[0].Result)
+		assert.Equal(t, *command, <-comm.Commands)
+
+		require.Len(t, provider.requests, 2)
+		assert.Equal(t, "exec", provider.requests[0].Tools[0].Name)
+		history := provider.requests[1].Messages
+		require.Len(t, history, 3)
+		assert.Equal(t, llm.RoleAssistant, history[1].Role)
+		assert.Equal(t, []llm.Content{llm.NewToolResult("toolu_1", "file", false)}, history[2].Content)
+
+		messages := []string{}
+		for len(comm.Messages) > 0 {
+			messages = append(messages, (<-comm.Messages).Message)
+		}
+		assert.Equal(t, []string{"Listing the files.", "The directory contains a single file."}, messages)
+		assert.Equal(t, int64(2), agent.Usage().Total.Requests)
+	})
+
+	t.Run("records commands in the audit log", func(t *testing.T) {
+		turns, err := llm.LoadTranscript("../llm/testdata/transcript.jsonl")
+		require.NoError(t, err)
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		provider := llm.NewReplay(turns)
+		comm := &Communication{
+			Commands: make(chan tool.Command, 10),
+			Messages: make(chan Message, 10),
+			Status:   make(chan Status, 10),
+		}
+		auditLog := audit.New(audit.WithConfig(config.Configuration{Audit: config.AuditConfiguration{Path: path}}))
+		agent := New(WithProvider(provider), WithCommunication(comm), WithAuditLog(auditLog))
+		tools := map[string]tool.Tool{"exec": &mockTool{
+			name:   "exec",
+			schema: &jsonschema.Schema{},
+			output: &tool.Output{Result: "file", ExecutedCommand: &tool.Command{ID: "cmd-1", Command: "ls"}},
+		}}
+
+		ctx := audit.WithOrigin(context.Background(), audit.Origin{SessionID: "session-1", Task: "list all files"})
+		_, err = agent.Run(&tool.RunOptions{Task: "list files", Caller: "files", Tools: tools}, ctx)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var entry audit.Entry
+		require.NoError(t, json.Unmarshal(data, &entry))
+		assert.Equal(t, "cmd-1", entry.CommandID)
+		assert.Equal(t, "files", entry.Caller)
+		assert.Equal(t, "session-1", entry.SessionID)
+		assert.Equal(t, "list all files", entry.Task)
+	})
+
+	t.Run("reports failed commands as errors", func(t *testing.T) {
+		agent, provider, _ := newReplayAgent(t, []llm.Turn{
+			{Content: []llm.TurnContent{{Type: llm.ContentToolUse, ID: "toolu_1", Name: "exec", Input: map[string]any{"command": "false"}}}},
+			{Content: []llm.TurnContent{{Type: llm.ContentText, Text: "The command failed."}}},
+		})
+		tools := map[string]tool.Tool{"exec": &mockTool{
+			name:   "exec",
+			schema: &jsonschema.Schema{},
+			output: &tool.Output{Result: "", ExecutedCommand: &tool.Command{Command: "false", ExitCode: 1}},
+		}}
+
+		_, err := agent.Run(&tool.RunOptions{Task: "run false", Tools: tools}, context.Background())
+		require.NoError(t, err)
+		require.Len(t, provider.requests, 2)
+		assert.Equal(t, []llm.Content{llm.NewToolResult("toolu_1", "", true)}, provider.requests[1].Messages[2].Content)
+	})
+
+	t.Run("returns error when transcript ends", func(t *testing.T) {
+		agent, _, _ := newReplayAgent(t, nil)
+
+		_, err := agent.Run(&tool.RunOptions{Task: "list files"}, context.Background())
+		assert.ErrorContains(t, err, llm.ErrTranscriptEnd)
+	})
+
+	t.Run("continues the conversation with follow-ups", func(t *testing.T) {
+		turns, err := llm.LoadTranscript("../llm/testdata/transcript.jsonl")
+		require.NoError(t, err)
+		agent, provider, _ := newReplayAgent(t, append(turns, llm.Turn{
+			Content: []llm.TurnContent{{Type: llm.ContentText, Text: "The same file is in staging."}},
+		}))
+		tools := map[string]tool.Tool{"exec": &mockTool{
+			name:   "exec",
+			schema: &jsonschema.Schema{},
+			output: &tool.Output{Result: "file", ExecutedCommand: &tool.Command{Command: "ls"}},
+		}}
+		conversation := NewConversation()
+
+		_, err = agent.Continue(conversation, &tool.RunOptions{Task: "list files", Tools: tools}, context.Background())
+		require.NoError(t, err)
+		assert.Len(t, conversation.Messages(), 4)
+
+		_, err = agent.Continue(conversation, &tool.RunOptions{Task: "now do the same for staging", Tools: tools},
+			context.Background())
+		require.NoError(t, err)
+
+		require.Len(t, provider.requests, 3)
+		history := provider.requests[2].Messages
+		require.Len(t, history, 5)
+		assert.Equal(t, []llm.Content{llm.NewToolResult("toolu_1", "file", false)}, history[2].Content)
+		assert.Equal(t, llm.Message{Role: llm.RoleUser, Content: []llm.Content{llm.NewText("now do the same for staging")}},
+			history[4])
+		assert.Len(t, conversation.Messages(), 6)
+	})
+
+	t.Run("keeps the conversation of a failed task", func(t *testing.T) {
+		agent, _, _ := newReplayAgent(t, nil)
+		conversation := NewConversation()
+
+		_, err := agent.Continue(conversation, &tool.RunOptions{Task: "list files"}, context.Background())
+		assert.ErrorContains(t, err, llm.ErrTranscriptEnd)
+		assert.Equal(t, []llm.Message{{Role: llm.RoleUser, Content: []llm.Content{llm.NewText("list files")}}},
+			conversation.Messages())
+	})
+
+	t.Run("returns error without provider", func(t *testing.T) {
+		agent := New()
+
+		_, err := agent.Run(&tool.RunOptions{Task: "list files"}, context.Background())
+		assert.EqualError(t, err, ErrNoProvider)
+	})
+}
+
+// TestRetryDelay tests the delay between retries
+func TestRetryDelay(t *testing.T) {
+	cfg := config.New().GetConfig()
+	cfg.Anthropic.Retry = config.RetryConfiguration{MaxAttempts: 5, BaseDelay: 1, MaxDelay: 5}
+
+	t.Run("uses exponential backoff", func(t *testing.T) {
+		agent := New(WithConfig(cfg))
+		assert.Equal(t, time.Second, agent.retryDelay(assert.AnError, 1))
+		assert.Equal(t, 2*time.Second, agent.retryDelay(assert.AnError, 2))
+		assert.Equal(t, 4*time.Second, agent.retryDelay(assert.AnError, 3))
+		assert.Equal(t, 5*time.Second, agent.retryDelay(assert.AnError, 4))
+	})
+
+	t.Run("applies jitter", func(t *testing.T) {
+		cfg := cfg
+		cfg.Anthropic.Retry.Jitter = 0.5
+		agent := New(WithConfig(cfg))
+		for range 100 {
+			delay := agent.retryDelay(assert.AnError, 2)
+			assert.GreaterOrEqual(t, delay, time.Second)
+			assert.LessOrEqual(t, delay, 3*time.Second)
+		}
+	})
+
+	t.Run("caps retry-after headers at the maximum delay", func(t *testing.T) {
+		agent := New(WithConfig(cfg))
+		overloaded := func(header http.Header) error {
+			return &llm.Error{StatusCode: statusOverloaded, Header: header}
+		}
+
+		assert.Equal(t, 3*time.Second, agent.retryDelay(overloaded(http.Header{"Retry-After": {"3"}}), 1))
+		assert.Equal(t, 5*time.Second, agent.retryDelay(overloaded(http.Header{"Retry-After": {"3600"}}), 1))
+		assert.Equal(t, 5*time.Second, agent.retryDelay(overloaded(http.Header{"Retry-After-Ms": {"86400000"}}), 1))
+	})
+
+	t.Run("uses retry-after headers", func(t *testing.T) {
+		delay, ok := retryAfter(http.Header{"Retry-After-Ms": {"1500"}, "Retry-After": {"10"}})
+		assert.True(t, ok)
+		assert.Equal(t, 1500*time.Millisecond, delay)
+
+		delay, ok = retryAfter(http.Header{"Retry-After": {"10"}})
+		assert.True(t, ok)
+		assert.Equal(t, 10*time.Second, delay)
+
+		delay, ok = retryAfter(http.Header{"Retry-After": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}})
+		assert.True(t, ok)
+		assert.InDelta(t, time.Hour.Seconds(), delay.Seconds(), 2)
+
+		_, ok = retryAfter(http.Header{"Retry-After": {"invalid"}})
+		assert.False(t, ok)
+	})
+}
+
+// blockingTool is a tool which runs until its context is done, signalling when it has started.
+type blockingTool struct {
+	mockTool
+	started chan struct{}
+}
+
+func (t *blockingTool) Execute(inputs map[string]any, ctx context.Context) (*tool.Output, error) {
+	close(t.started)
+	<-ctx.Done()
+
+	return &tool.Output{Tool: t.name, Result: "stopped", IsError: true}, context.Cause(ctx)
+}
+
+// TestCancel tests the cancellation of the running tasks by the user
+func TestCancel(t *testing.T) {
+	t.Run("stops the task once the results of the running tools are recorded", func(t *testing.T) {
+		provider := &requestRecorder{Provider: llm.NewReplay([]llm.Turn{
+			{Content: []llm.TurnContent{{Type: llm.ContentToolUse, ID: "toolu_1", Name: "blocking", Input: map[string]any{}}}},
+			{Content: []llm.TurnContent{{Type: llm.ContentText, Text: "Never requested."}}},
+		})}
+		comm := &Communication{
+			Commands: make(chan tool.Command, 10),
+			Messages: make(chan Message, 10),
+			Status:   make(chan Status, 10),
+		}
+		agent := New(WithProvider(provider), WithCommunication(comm))
+		blocking := &blockingTool{mockTool: mockTool{name: "blocking", schema: &jsonschema.Schema{}}, started: make(chan struct{})}
+		go func() {
+			<-blocking.started
+			agent.Cancel()
+		}()
+
+		conversation := NewConversation()
+		output, err := agent.Continue(conversation, &tool.RunOptions{Task: "wait", Tools: map[string]tool.Tool{"blocking": blocking}},
+			context.Background())
+		assert.ErrorIs(t, err, errCancelled)
+		assert.EqualError(t, err, ErrCancelled)
+		require.Len(t, output, 1)
+		assert.Len(t, provider.requests, 1)
+
+		messages := conversation.Messages()
+		require.Len(t, messages, 3)
+		assert.Equal(t, []llm.Content{llm.NewToolResult("toolu_1", "stopped", true)}, messages[2].Content)
+		close(comm.Messages)
+		last := Message{}
+		for message := range comm.Messages {
+			last = message
+		}
+		assert.Equal(t, messageCancelled, last.Message)
+		assert.Empty(t, agent.runs)
+	})
+
+	t.Run("does not affect the following tasks", func(t *testing.T) {
+		agent := New(WithProvider(llm.NewReplay([]llm.Turn{
+			{Content: []llm.TurnContent{{Type: llm.ContentText, Text: "Done."}}},
+		})), WithCommunication(&Communication{Messages: make(chan Message, 10), Status: make(chan Status, 10)}))
+		agent.Cancel()
+
+		_, err := agent.Run(&tool.RunOptions{Task: "list files"}, context.Background())
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/datolabs-io/opsy/internal/llm"
+)
+
+// Conversation is the history of the messages exchanged with the LLM provider. Tasks continuing the conversation are
+// sent together with the whole history, including the tool calls and their results.
+type Conversation struct {
+	mu       sync.Mutex
+	messages []llm.Message
+	onSave   func(messages []llm.Message)
+}
+
+// ConversationOption is a function that configures the Conversation.
+type ConversationOption func(*Conversation)
+
+// NewConversation creates a new conversation, empty unless WithHistory is given.
+func NewConversation(opts ...ConversationOption) *Conversation {
+	c := &Conversation{messages: []llm.Message{}}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithHistory sets the messages the conversation continues, e.g. those of a resumed session.
+func WithHistory(messages []llm.Message) ConversationOption {
+	return func(c *Conversation) {
+		c.messages = slices.Clone(messages)
+	}
+}
+
+// WithSaveHandler sets the function called with the messages of the conversation every time they are updated, e.g.
+// to persist them.
+func WithSaveHandler(handler func(messages []llm.Message)) ConversationOption {
+	return func(c *Conversation) {
+		c.onSave = handler
+	}
+}
+
+// Messages returns a copy of the messages of the conversation.
+func (c *Conversation) Messages() []llm.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Clone(c.messages)
+}
+
+// start returns the messages of the conversation followed by the task. If the conversation ends with a user message,
+// e.g. the tool results of a task which failed, the task is added to it, since the roles of the messages alternate.
+func (c *Conversation) start(task string) []llm.Message {
+	messages := c.Messages()
+	text := llm.NewText(task)
+
+	if last := len(messages) - 1; last >= 0 && messages[last].Role == llm.RoleUser {
+		messages[last].Content = append(slices.Clone(messages[last].Content), text)
+		return messages
+	}
+
+	return append(messages, llm.Message{Role: llm.RoleUser, Content: []llm.Content{text}})
+}
+
+// save replaces the messages of the conversation and passes them to the save handler.
+func (c *Conversation) save(messages []llm.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages = slices.Clone(messages)
+	if c.onSave != nil {
+		c.onSave(slices.Clone(messages))
+	}
+}
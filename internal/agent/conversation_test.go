@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/datolabs-io/opsy/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConversation tests the history of a conversation.
+func TestConversation(t *testing.T) {
+	t.Run("starts with the task", func(t *testing.T) {
+		conversation := NewConversation()
+		messages := conversation.start("list files")
+		assert.Equal(t, []llm.Message{{Role: llm.RoleUser, Content: []llm.Content{llm.NewText("list files")}}}, messages)
+		assert.Empty(t, conversation.Messages())
+	})
+
+	t.Run("continues after the last answer", func(t *testing.T) {
+		conversation := NewConversation()
+		conversation.save(append(conversation.start("list files"),
+			llm.Message{Role: llm.RoleAssistant, Content: []llm.Content{llm.NewText("One file.")}}))
+
+		messages := conversation.start("now count them")
+		require.Len(t, messages, 3)
+		assert.Equal(t, llm.Message{Role: llm.RoleUser, Content: []llm.Content{llm.NewText("now count them")}}, messages[2])
+		assert.Len(t, conversation.Messages(), 2)
+	})
+
+	t.Run("adds the task to a trailing user message", func(t *testing.T) {
+		conversation := NewConversation()
+		conversation.save([]llm.Message{
+			{Role: llm.RoleUser, Content: []llm.Content{llm.NewText("list files")}},
+			{Role: llm.RoleAssistant, Content: []llm.Content{llm.NewToolUse("toolu_1", "exec", []byte(`{}`))}},
+			{Role: llm.RoleUser, Content: []llm.Content{llm.NewToolResult("toolu_1", "file", false)}},
+		})
+
+		messages := conversation.start("try again")
+		require.Len(t, messages, 3)
+		assert.Equal(t, []llm.Content{llm.NewToolResult("toolu_1", "file", false), llm.NewText("try again")}, messages[2].Content)
+		assert.Len(t, conversation.Messages()[2].Content, 1)
+	})
+
+	t.Run("returns a copy of the messages", func(t *testing.T) {
+		conversation := NewConversation()
+		conversation.save(conversation.start("list files"))
+
+		messages := conversation.Messages()
+		messages[0].Role = llm.RoleAssistant
+		assert.Equal(t, llm.RoleUser, conversation.Messages()[0].Role)
+	})
+
+	t.Run("continues the history", func(t *testing.T) {
+		history := []llm.Message{
+			{Role: llm.RoleUser, Content: []llm.Content{llm.NewText("list files")}},
+			{Role: llm.RoleAssistant, Content: []llm.Content{llm.NewText("One file.")}},
+		}
+		conversation := NewConversation(WithHistory(history))
+		assert.Equal(t, history, conversation.Messages())
+		assert.Len(t, conversation.start("now count them"), 3)
+	})
+
+	t.Run("passes saved messages to the handler", func(t *testing.T) {
+		saved := [][]llm.Message{}
+		conversation := NewConversation(WithSaveHandler(func(messages []llm.Message) {
+			saved = append(saved, messages)
+		}))
+
+		conversation.save(conversation.start("list files"))
+		require.Len(t, saved, 1)
+		assert.Equal(t, conversation.Messages(), saved[0])
+	})
+}
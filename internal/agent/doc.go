@@ -1,17 +1,19 @@
 /*
 Package agent provides functionality for executing tasks using AI-powered tools within the opsy application.
 
-The agent acts as a bridge between the user's task requests and the available tools, using the Anthropic
-Claude API to intelligently select and execute appropriate tools based on the task requirements.
+The agent acts as a bridge between the user's task requests and the available tools, using a large language
+model (via the llm package) to intelligently select and execute appropriate tools based on the task requirements.
 
 # Core Components
 
 The package consists of several key components:
 
   - Agent: The main struct that handles task execution and tool management
+  - Conversation: The history of the messages, continued by follow-up tasks
   - Communication: Channels for sending messages, commands, and status updates
   - Message: Represents a message from the agent or tool execution
   - Status: Represents the current state of the agent (Running, Finished, etc.)
+  - Usage: Token usage and estimated cost of the requests to the LLM provider
 
 # Agent Configuration
 
@@ -24,11 +26,17 @@ The agent can be configured using functional options:
 		agent.WithCommunication(comm),
 	)
 
+Unless WithProvider is given, the agent creates the LLM provider selected by the
+llm.provider configuration. Run returns ErrNoProvider if no provider could be
+created, e.g. because the Anthropic API key is missing.
+
 Available options include:
   - WithConfig: Sets the configuration for the agent
   - WithLogger: Sets the logger for the agent
   - WithContext: Sets the context for the agent
   - WithCommunication: Sets the communication channels
+  - WithProvider: Sets the LLM provider, overriding the configured one
+  - WithAuditLog: Records every executed command in the audit log
 
 # Task Execution
 
@@ -43,13 +51,30 @@ Tasks are executed using the Run method:
 
 The agent will:
 1. Parse the task and available tools
-2. Use the Anthropic API to determine which tools to use
+2. Use the LLM provider to determine which tools to use
 3. Execute the selected tools with appropriate parameters
 4. Return the combined output from all tool executions
 
 The agent supports customizing the system prompt through RunOptions.Prompt,
 which allows overriding the default behavior when needed.
 
+# Conversations
+
+Run starts a new conversation for every task. Continue runs the task as the
+next message of an existing Conversation, so that follow-up instructions are
+sent together with the whole history, including the tool calls and their
+results:
+
+	conversation := agent.NewConversation()
+	_, err := agnt.Continue(conversation, &tool.RunOptions{Task: task, Tools: tools}, ctx)
+	// ...
+	_, err = agnt.Continue(conversation, &tool.RunOptions{Task: "now do the same for staging", Tools: tools}, ctx)
+
+The conversation is updated after every complete exchange, so a task that
+failed can be continued too: a history ending with tool results gets the
+follow-up added to its last user message. The tools run their own agents in
+new conversations.
+
 # Communication
 
 The agent uses channels to communicate its progress:
@@ -57,13 +82,23 @@ The agent uses channels to communicate its progress:
   - Messages: Task progress and tool output messages
   - Commands: Commands executed by tools
   - Status: Current agent status (Running, Finished)
+  - Approvals: Commands waiting for the user's approval
+  - Output: Output of commands while they are running
+  - Usage: Running total of the token usage after each request
+  - Tools: Tools which start and finish running, except the Exec tool (optional)
+  - Terminal: Interactive commands waiting for the terminal to be handed over
 
 Example usage:
 
 	comm := &agent.Communication{
-		Commands: make(chan tool.Command),
-		Messages: make(chan agent.Message),
-		Status:   make(chan agent.Status),
+		Commands:  make(chan tool.Command),
+		Messages:  make(chan agent.Message),
+		Status:    make(chan agent.Status),
+		Approvals: make(chan tool.ApprovalRequest),
+		Output:    make(chan tool.CommandOutput),
+		Usage:     make(chan agent.Usage),
+		Tools:     make(chan agent.ToolRun),
+		Terminal:  make(chan tool.TerminalRequest),
 	}
 
 	go func() {
@@ -72,16 +107,112 @@ Example usage:
 		}
 	}()
 
+# Command Approval
+
+The agent implements tool.Approver. Each Run places the agent into the context,
+so the exec tool can request approval of a command. The request is sent to the
+Approvals channel and the agent waits for the decision on the request's Response
+channel, or until the context is cancelled. The request's Done channel is closed
+once the agent stops waiting, so that its receiver can dismiss it.
+
+The agent also implements tool.Terminal: the session of an interactive command is
+sent to the Terminal channel, whose receiver runs it in the terminal of the user,
+and the agent waits for the result on the request's Response channel.
+
+# Streaming
+
+Responses of the LLM provider are streamed. Each text block is sent to the
+Messages channel as it is generated: every update carries the whole text
+generated so far and the same Message.ID, so consumers replace the previous
+version of the message instead of appending a new one. Tools are executed once
+the response is complete.
+
+# Retries
+
+Requests to the LLM provider which fail with a rate limit (429), overloaded (529)
+or server (5xx) error are retried according to the anthropic.retry configuration.
+The delay doubles on every attempt, starting at the base delay and capped at the
+maximum delay, with a random jitter applied. A delay requested by the API via the
+`retry-after-ms` or `retry-after` headers takes precedence, but is capped at the
+maximum delay as well. While waiting, the agent
+reports a "Retrying (n/max)…" status; other errors are returned immediately.
+
+# Token Usage
+
+The input, output, cache write and cache read tokens of every response are
+recorded per tool (the caller of Run, or the agent's own Name) and in total. The
+cost is estimated from the anthropic.pricing entry of the response model, falling
+back to the configured model; models without a price have no cost. After each
+request the new total is sent to the Usage channel, and the accumulated usage is
+available from the Usage method:
+
+	report := agent.Usage()
+	logger.With("usage", report).Info("Token usage")
+
+Usage and UsageReport implement slog.LogValuer, so they are logged as groups of
+attributes.
+
+# Budget
+
+Every Run stops before sending a request once a limit of anthropic.budget is
+reached: the number of requests of the Run (max_iterations), or the tokens
+(max_total_tokens) and estimated cost (max_cost) of all the requests of the
+task, including the runs of the tools. The task starts with each top-level
+Run or Continue, so that every follow-up gets the full budget. The reason is sent to the Messages
+channel and an error wrapping ErrBudgetExceeded is returned. A Run whose tool
+exceeded the budget returns the tool's error immediately, so the whole task
+stops. A limit of 0 disables it.
+
+# Cancellation
+
+Cancel stops the tasks the agent is running on behalf of the user. The commands
+being executed are terminated, their results are recorded in the conversation, and
+no further request is sent: the agent sends a message telling the task was
+cancelled and Run returns the output so far with an error wrapping ErrCancelled.
+Since the conversation stays consistent, it can be continued with a follow-up
+instruction. A single command can be cancelled with the Cancel function of its
+tool.CommandOutput instead, in which case the model is told and the task goes on.
+
+# Command Output
+
+The agent also implements tool.OutputHandler and places itself into the context
+of each Run. The exec tool reports each running command when it starts and then
+every line of its output; the updates are sent to the Output channel. Updates are
+dropped when no Output channel is configured or the context is cancelled.
+
+# Audit Log
+
+With WithAuditLog, every command the agent or its tools execute is recorded in
+the audit log after it is sent to the Commands channel, together with the tool
+that executed it. The session and task of the entries come from the
+audit.Origin carried by the context of Run or Continue. Errors writing the audit
+log are logged and do not stop the task.
+
 # Tool Integration
 
-Tools are converted to a format compatible with the Anthropic API:
+Tools are converted to the provider-neutral llm.Tool format:
 
   - Name: Tool identifier
   - Description: Tool purpose and functionality
   - InputSchema: JSON Schema defining valid inputs
 
 The agent ensures proper conversion and validation of tools before use.
-By default, parallel tool use is disabled to ensure deterministic execution.
+
+# Parallel Tool Use
+
+By default, parallel tool use is disabled to ensure deterministic execution: the
+model calls one tool per response. With tools.parallel.enabled, the model may
+call several tools in a single response, and the agent executes them
+concurrently with a worker pool of tools.parallel.max_concurrency workers. The
+calls are started in the order of the response and no call is started once the
+task budget is exceeded. The results are sent to the channels and returned to
+the model in the order of the calls, whichever finishes first. Each run has its
+own pool, so the tools executed in parallel may run their commands in parallel
+as well.
+
+While a tool other than the Exec tool runs, a ToolRun is sent to the Tools
+channel when it starts and when it finishes, so that several running tools can
+be shown at once.
 
 # Error Handling
 
@@ -89,6 +220,11 @@ The package defines several error types:
 
   - ErrNoRunOptions: No options provided for Run
   - ErrNoTaskProvided: No task specified in options
+  - ErrNoApprovalChannel: Approval requested without an approval channel
+  - ErrNoTerminalChannel: Interactive command run without a terminal channel
+  - ErrBudgetExceeded: The task reached a limit of its budget
+  - ErrNoProvider: No LLM provider configured
+  - ErrCancelled: The task was cancelled by the user
 
 All errors are properly logged with contextual information using structured logging.
 Tool execution errors are captured and reflected in the tool results.
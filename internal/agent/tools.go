@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/datolabs-io/opsy/internal/llm"
+	"github.com/datolabs-io/opsy/internal/tool"
+)
+
+// ToolRun is a tool called by the agent, sent when it starts and again when it finishes running. The Exec tool is
+// not reported, since its commands are sent to the commands channel.
+type ToolRun struct {
+	// ID identifies the tool call.
+	ID string
+	// Tool is the display name of the tool.
+	Tool string
+	// StartedAt is the time when the tool started running.
+	StartedAt time.Time
+	// Finished is true once the tool has finished running.
+	Finished bool
+}
+
+// toolCall is a tool called by the model, with its result once it has been executed.
+type toolCall struct {
+	// id identifies the tool call in the response of the model.
+	id string
+	// name is the name of the called tool.
+	name string
+	// tool is the called tool.
+	tool tool.Tool
+	// inputs are the inputs of the tool.
+	inputs map[string]any
+	// output is the output of the tool, nil until it has been executed.
+	output *tool.Output
+	// err is the error returned by the tool.
+	err error
+}
+
+// toolCalls returns the tools called in the content of a response, in order. Calls of unknown tools and calls with
+// invalid inputs are skipped.
+func toolCalls(content []llm.Content, tools map[string]tool.Tool, logger *slog.Logger) []*toolCall {
+	calls := []*toolCall{}
+	for _, block := range content {
+		if block.Type != llm.ContentToolUse {
+			continue
+		}
+
+		inputs := map[string]any{}
+		if err := json.Unmarshal(block.ToolUse.Input, &inputs); err != nil {
+			logger.With("error", err).Error("Failed to unmarshal tool inputs.")
+			continue
+		}
+
+		t, ok := tools[block.ToolUse.Name]
+		if !ok {
+			logger.With("tool_name", block.ToolUse.Name).Warn("Tool not found, skipping.")
+			continue
+		}
+
+		calls = append(calls, &toolCall{id: block.ToolUse.ID, name: block.ToolUse.Name, tool: t, inputs: inputs})
+	}
+
+	return calls
+}
+
+// executeTools executes the tool calls, concurrently up to tools.parallel.max_concurrency if parallel tool use is
+// enabled, one after another otherwise. The calls are started in order and no call is started once a tool has
+// exceeded the budget of the task. It returns when all the started calls have finished.
+func (a *Agent) executeTools(ctx context.Context, calls []*toolCall) {
+	concurrency := 1
+	if a.cfg.Tools.Parallel.Enabled {
+		concurrency = max(a.cfg.Tools.Parallel.MaxConcurrency, 1)
+	}
+
+	workers := make(chan struct{}, concurrency)
+	exceeded := atomic.Bool{}
+	wg := sync.WaitGroup{}
+
+	for _, call := range calls {
+		workers <- struct{}{}
+		if exceeded.Load() {
+			<-workers
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+
+			a.executeTool(ctx, call)
+			if errors.Is(call.err, errBudgetExceeded) {
+				exceeded.Store(true)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// executeTool executes the tool call, reporting it to the tools channel unless it is the Exec tool.
+func (a *Agent) executeTool(ctx context.Context, call *toolCall) {
+	if call.name == tool.ExecToolName {
+		call.output, call.err = call.tool.Execute(call.inputs, ctx)
+		return
+	}
+
+	run := ToolRun{ID: call.id, Tool: call.tool.GetDisplayName(), StartedAt: time.Now()}
+	a.sendToolRun(ctx, run)
+
+	call.output, call.err = call.tool.Execute(call.inputs, ctx)
+
+	run.Finished = true
+	a.sendToolRun(ctx, run)
+}
+
+// sendToolRun sends the tool run to the tools channel. Updates are dropped if there is no tools channel or the
+// context is cancelled.
+func (a *Agent) sendToolRun(ctx context.Context, run ToolRun) {
+	if a.communication.Tools == nil {
+		return
+	}
+
+	select {
+	case a.communication.Tools <- run:
+	case <-ctx.Done():
+	}
+}
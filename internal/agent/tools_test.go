@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/datolabs-io/opsy/internal/llm"
+	"github.com/datolabs-io/opsy/internal/tool"
+	"github.com/invopop/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowTool is a tool which takes a while to execute and tracks how many of its calls run at the same time.
+type slowTool struct {
+	mockTool
+	delay   time.Duration
+	running atomic.Int32
+	peak    atomic.Int32
+	calls   atomic.Int32
+}
+
+func (t *slowTool) Execute(inputs map[string]any, ctx context.Context) (*tool.Output, error) {
+	t.calls.Add(1)
+	running := t.running.Add(1)
+	defer t.running.Add(-1)
+	for peak := t.peak.Load(); running > peak; peak = t.peak.Load() {
+		if t.peak.CompareAndSwap(peak, running) {
+			break
+		}
+	}
+
+	time.Sleep(t.delay)
+	return &tool.Output{Tool: t.displayName, Result: fmt.Sprintf("%v", inputs["task"])}, t.err
+}
+
+// parallelConfig returns the configuration with parallel tool use enabled up to the given concurrency.
+func parallelConfig(enabled bool, concurrency int) config.Configuration {
+	cfg := config.New().GetConfig()
+	cfg.Tools.Parallel = config.ParallelConfiguration{Enabled: enabled, MaxConcurrency: concurrency}
+
+	return cfg
+}
+
+// newToolCalls returns the given number of calls of the tool.
+func newToolCalls(t tool.Tool, count int) []*toolCall {
+	calls := []*toolCall{}
+	for i := range count {
+		calls = append(calls, &toolCall{id: fmt.Sprintf("toolu_%d", i), name: "slow", tool: t, inputs: map[string]any{"task": i}})
+	}
+
+	return calls
+}
+
+// TestToolCalls tests the extraction of the tool calls from a response
+func TestToolCalls(t *testing.T) {
+	tools := map[string]tool.Tool{"exec": &mockTool{name: "exec"}}
+	content := []llm.Content{
+		llm.NewText("Checking."),
+		llm.NewToolUse("toolu_1", "exec", []byte(`{"command":"ls"}`)),
+		llm.NewToolUse("toolu_2", "unknown", []byte(`{}`)),
+		llm.NewToolUse("toolu_3", "exec", []byte(`invalid`)),
+		llm.NewToolUse("toolu_4", "exec", []byte(`{"command":"pwd"}`)),
+	}
+
+	calls := toolCalls(content, tools, slog.New(slog.DiscardHandler))
+	require.Len(t, calls, 2)
+	assert.Equal(t, "toolu_1", calls[0].id)
+	assert.Equal(t, map[string]any{"command": "ls"}, calls[0].inputs)
+	assert.Equal(t, "toolu_4", calls[1].id)
+	assert.Equal(t, map[string]any{"command": "pwd"}, calls[1].inputs)
+}
+
+// TestExecuteTools tests the execution of the tool calls with the worker pool
+func TestExecuteTools(t *testing.T) {
+	t.Run("executes one tool at a time by default", func(t *testing.T) {
+		agent := New(WithConfig(parallelConfig(false, 4)), WithCommunication(&Communication{}))
+		slow := &slowTool{mockTool: mockTool{displayName: "Slow"}, delay: 10 * time.Millisecond}
+		calls := newToolCalls(slow, 3)
+
+		agent.executeTools(context.Background(), calls)
+		assert.Equal(t, int32(1), slow.peak.Load())
+		for i, call := range calls {
+			assert.Equal(t, fmt.Sprintf("%d", i), call.output.Result)
+		}
+	})
+
+	t.Run("executes the tools concurrently up to the maximum concurrency", func(t *testing.T) {
+		agent := New(WithConfig(parallelConfig(true, 2)), WithCommunication(&Communication{}))
+		slow := &slowTool{mockTool: mockTool{displayName: "Slow"}, delay: 50 * time.Millisecond}
+		calls := newToolCalls(slow, 6)
+
+		agent.executeTools(context.Background(), calls)
+		assert.Equal(t, int32(2), slow.peak.Load())
+		assert.Equal(t, int32(6), slow.calls.Load())
+		for i, call := range calls {
+			assert.Equal(t, fmt.Sprintf("%d", i), call.output.Result)
+		}
+	})
+
+	t.Run("does not start tools once the budget is exceeded", func(t *testing.T) {
+		agent := New(WithConfig(parallelConfig(false, 4)), WithCommunication(&Communication{}))
+		slow := &slowTool{mockTool: mockTool{displayName: "Slow", err: errBudgetExceeded}}
+		calls := newToolCalls(slow, 3)
+
+		agent.executeTools(context.Background(), calls)
+		assert.Equal(t, int32(1), slow.calls.Load())
+		assert.ErrorIs(t, calls[0].err, errBudgetExceeded)
+		assert.Nil(t, calls[1].output)
+		assert.Nil(t, calls[2].output)
+	})
+
+	t.Run("reports the tools running at the same time", func(t *testing.T) {
+		comm := &Communication{Tools: make(chan ToolRun, 10)}
+		agent := New(WithConfig(parallelConfig(true, 4)), WithCommunication(comm))
+		slow := &slowTool{mockTool: mockTool{displayName: "Slow"}, delay: 50 * time.Millisecond}
+		exec := &slowTool{mockTool: mockTool{name: tool.ExecToolName}}
+		calls := append(newToolCalls(slow, 2), &toolCall{id: "toolu_exec", name: tool.ExecToolName, tool: exec})
+
+		agent.executeTools(context.Background(), calls)
+		close(comm.Tools)
+
+		runs := []ToolRun{}
+		for run := range comm.Tools {
+			runs = append(runs, run)
+		}
+		require.Len(t, runs, 4)
+		assert.False(t, runs[0].Finished)
+		assert.False(t, runs[1].Finished)
+		assert.True(t, runs[2].Finished)
+		assert.True(t, runs[3].Finished)
+		assert.ElementsMatch(t, []string{"toolu_0", "toolu_1"}, []string{runs[0].ID, runs[1].ID})
+		assert.Equal(t, "Slow", runs[0].Tool)
+	})
+}
+
+// TestRun_ParallelTools tests that the results of tools executed concurrently are returned in order
+func TestRun_ParallelTools(t *testing.T) {
+	provider := &requestRecorder{Provider: llm.NewReplay([]llm.Turn{
+		{Content: []llm.TurnContent{
+			{Type: llm.ContentToolUse, ID: "toolu_1", Name: "slow", Input: map[string]any{"task": "first"}},
+			{Type: llm.ContentToolUse, ID: "toolu_2", Name: "fast", Input: map[string]any{"task": "second"}},
+		}},
+		{Content: []llm.TurnContent{{Type: llm.ContentText, Text: "Both clusters are healthy."}}},
+	})}
+	comm := &Communication{
+		Commands: make(chan tool.Command, 10),
+		Messages: make(chan Message, 10),
+		Status:   make(chan Status, 10),
+	}
+	agent := New(WithConfig(parallelConfig(true, 4)), WithProvider(provider), WithCommunication(comm))
+	tools := map[string]tool.Tool{
+		"slow": &slowTool{mockTool: mockTool{name: "slow", schema: &jsonschema.Schema{}}, delay: 50 * time.Millisecond},
+		"fast": &slowTool{mockTool: mockTool{name: "fast", schema: &jsonschema.Schema{}}},
+	}
+
+	output, err := agent.Run(&tool.RunOptions{Task: "check both clusters", Tools: tools}, context.Background())
+	require.NoError(t, err)
+	require.Len(t, output, 2)
+	assert.Equal(t, "first", output[0].Result)
+	assert.Equal(t, "second", output[1].Result)
+
+	require.Len(t, provider.requests, 2)
+	assert.True(t, provider.requests[0].ParallelToolUse)
+	assert.Equal(t, []llm.Content{
+		llm.NewToolResult("toolu_1", "first", false),
+		llm.NewToolResult("toolu_2", "second", false),
+	}, provider.requests[1].Messages[2].Content)
+}
@@ -0,0 +1,215 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/datolabs-io/opsy/internal/llm"
+)
+
+const (
+	// tokensPerMillion is the number of tokens the prices are given for.
+	tokensPerMillion = 1_000_000
+
+	// budgetIterations is the reason of a stop caused by the iterations limit.
+	budgetIterations = "reached the limit of %d iterations"
+	// budgetTokens is the reason of a stop caused by the tokens limit.
+	budgetTokens = "used %d tokens of the limit of %d tokens"
+	// budgetCost is the reason of a stop caused by the cost limit.
+	budgetCost = "cost $%.3f of the limit of $%.3f"
+	// messageBudgetExceeded is the message shown when the task is stopped by a limit of its budget.
+	messageBudgetExceeded = "Stopped: the task %s. Adjust `anthropic.budget` to allow more."
+)
+
+// errBudgetExceeded is the error returned when the task reaches a limit of its budget.
+var errBudgetExceeded = errors.New(ErrBudgetExceeded)
+
+// Usage is the token usage and the estimated cost of requests to the LLM provider.
+type Usage struct {
+	// Requests is the number of requests sent to the LLM provider.
+	Requests int64 `json:"requests"`
+	// InputTokens is the number of input tokens which were not read from or written to the prompt cache.
+	InputTokens int64 `json:"input_tokens"`
+	// OutputTokens is the number of output tokens.
+	OutputTokens int64 `json:"output_tokens"`
+	// CacheWriteTokens is the number of input tokens written to the prompt cache.
+	CacheWriteTokens int64 `json:"cache_write_tokens"`
+	// CacheReadTokens is the number of input tokens read from the prompt cache.
+	CacheReadTokens int64 `json:"cache_read_tokens"`
+	// Cost is the estimated cost in USD.
+	Cost float64 `json:"cost"`
+}
+
+// UsageReport is the token usage of the agent in total and per tool.
+type UsageReport struct {
+	// Total is the usage of all the requests.
+	Total Usage `json:"total"`
+	// Tools is the usage of the requests of each tool, keyed by the tool's display name.
+	Tools map[string]Usage `json:"tools"`
+}
+
+// taskUsageKey is the context key of the total usage of the agent when the task started.
+type taskUsageKey struct{}
+
+// usageTracker accumulates the token usage of the agent.
+type usageTracker struct {
+	mu    sync.Mutex
+	total Usage
+	tools map[string]Usage
+}
+
+// TotalInputTokens returns the number of input tokens, including the tokens read from and written to the prompt cache.
+func (u Usage) TotalInputTokens() int64 {
+	return u.InputTokens + u.CacheWriteTokens + u.CacheReadTokens
+}
+
+// LogValue returns the usage as a group of log attributes.
+func (u Usage) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int64("requests", u.Requests),
+		slog.Int64("input_tokens", u.InputTokens),
+		slog.Int64("output_tokens", u.OutputTokens),
+		slog.Int64("cache_write_tokens", u.CacheWriteTokens),
+		slog.Int64("cache_read_tokens", u.CacheReadTokens),
+		slog.Float64("cost", u.Cost),
+	)
+}
+
+// LogValue returns the usage report as a group of log attributes, with a group per tool.
+func (r UsageReport) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.Any("total", r.Total)}
+	for tool, usage := range r.Tools {
+		attrs = append(attrs, slog.Any(tool, usage))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// add adds the other usage to the usage.
+func (u *Usage) add(other Usage) {
+	u.Requests += other.Requests
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheWriteTokens += other.CacheWriteTokens
+	u.CacheReadTokens += other.CacheReadTokens
+	u.Cost += other.Cost
+}
+
+// newUsage returns the usage of a single request, priced with the given price.
+func newUsage(usage llm.Usage, price config.PriceConfiguration) Usage {
+	u := Usage{
+		Requests:         1,
+		InputTokens:      usage.InputTokens,
+		OutputTokens:     usage.OutputTokens,
+		CacheWriteTokens: usage.CacheWriteTokens,
+		CacheReadTokens:  usage.CacheReadTokens,
+	}
+	u.Cost = (float64(u.InputTokens)*price.Input + float64(u.OutputTokens)*price.Output +
+		float64(u.CacheWriteTokens)*price.CacheWrite + float64(u.CacheReadTokens)*price.CacheRead) / tokensPerMillion
+
+	return u
+}
+
+// newUsageTracker creates a new usage tracker.
+func newUsageTracker() *usageTracker {
+	return &usageTracker{tools: map[string]Usage{}}
+}
+
+// add records the usage of a request sent for the given tool and returns the new total.
+func (t *usageTracker) add(tool string, usage Usage) Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total.add(usage)
+	toolUsage := t.tools[tool]
+	toolUsage.add(usage)
+	t.tools[tool] = toolUsage
+
+	return t.total
+}
+
+// report returns a copy of the accumulated usage.
+func (t *usageTracker) report() UsageReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return UsageReport{Total: t.total, Tools: maps.Clone(t.tools)}
+}
+
+// Usage returns the token usage and the estimated cost of the requests sent by the agent.
+func (a *Agent) Usage() UsageReport {
+	return a.usage.report()
+}
+
+// recordUsage records the usage of the response and sends the new total to the usage channel.
+func (a *Agent) recordUsage(ctx context.Context, response *llm.Response, caller string, logger *slog.Logger) {
+	price, ok := a.price(response.Model)
+	if !ok {
+		logger.With("model", response.Model).Debug("No price configured for the model, cost not estimated.")
+	}
+
+	tool := caller
+	if tool == "" {
+		tool = Name
+	}
+
+	usage := newUsage(response.Usage, price)
+	total := a.usage.add(tool, usage)
+	logger.With("usage", usage).Debug("LLM request completed.")
+
+	if a.communication.Usage == nil {
+		return
+	}
+
+	select {
+	case a.communication.Usage <- total:
+	case <-ctx.Done():
+	}
+}
+
+// withTaskUsage returns a context carrying the total usage of the agent when the task starts, unless it is the run of a
+// tool within a task which already carries it. The budget of each task is measured from there, so that the follow-ups
+// of a session each get the full budget.
+func (a *Agent) withTaskUsage(ctx context.Context, caller string) context.Context {
+	if _, ok := ctx.Value(taskUsageKey{}).(Usage); ok && caller != "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, taskUsageKey{}, a.usage.report().Total)
+}
+
+// budgetExceeded returns the reason why the budget is exceeded, if the run has used all its iterations or the task has
+// used all its tokens or cost. The tokens and cost include the runs of the tools, which share the agent.
+func (a *Agent) budgetExceeded(ctx context.Context, iterations int64) (string, bool) {
+	budget := a.cfg.Anthropic.Budget
+	start, _ := ctx.Value(taskUsageKey{}).(Usage)
+	total := a.usage.report().Total
+	tokens := total.TotalInputTokens() + total.OutputTokens - start.TotalInputTokens() - start.OutputTokens
+	cost := total.Cost - start.Cost
+
+	switch {
+	case budget.MaxIterations > 0 && iterations >= budget.MaxIterations:
+		return fmt.Sprintf(budgetIterations, budget.MaxIterations), true
+	case budget.MaxTotalTokens > 0 && tokens >= budget.MaxTotalTokens:
+		return fmt.Sprintf(budgetTokens, tokens, budget.MaxTotalTokens), true
+	case budget.MaxCost > 0 && cost >= budget.MaxCost:
+		return fmt.Sprintf(budgetCost, cost, budget.MaxCost), true
+	}
+
+	return "", false
+}
+
+// price returns the price of the model of the response, falling back to the price of the configured model.
+func (a *Agent) price(model string) (config.PriceConfiguration, bool) {
+	if price, ok := a.cfg.Anthropic.Pricing[model]; ok {
+		return price, true
+	}
+
+	price, ok := a.cfg.Anthropic.Pricing[llm.ModelFromConfig(a.cfg).Name]
+	return price, ok
+}
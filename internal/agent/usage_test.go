@@ -0,0 +1,233 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/datolabs-io/opsy/internal/llm"
+	"github.com/datolabs-io/opsy/internal/tool"
+	"github.com/invopop/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewUsage tests the token usage and cost of a single request
+func TestNewUsage(t *testing.T) {
+	t.Run("estimates cost from the price", func(t *testing.T) {
+		usage := newUsage(llm.Usage{
+			InputTokens:      1_000_000,
+			OutputTokens:     100_000,
+			CacheWriteTokens: 200_000,
+			CacheReadTokens:  2_000_000,
+		}, config.PriceConfiguration{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3})
+
+		assert.Equal(t, int64(1), usage.Requests)
+		assert.Equal(t, int64(1_000_000), usage.InputTokens)
+		assert.Equal(t, int64(100_000), usage.OutputTokens)
+		assert.Equal(t, int64(200_000), usage.CacheWriteTokens)
+		assert.Equal(t, int64(2_000_000), usage.CacheReadTokens)
+		assert.Equal(t, int64(3_200_000), usage.TotalInputTokens())
+		assert.InDelta(t, 3+1.5+0.75+0.6, usage.Cost, 1e-9)
+	})
+
+	t.Run("has no cost without price", func(t *testing.T) {
+		usage := newUsage(llm.Usage{InputTokens: 100, OutputTokens: 10}, config.PriceConfiguration{})
+		assert.Equal(t, int64(100), usage.InputTokens)
+		assert.Zero(t, usage.Cost)
+	})
+}
+
+// TestUsageTracker tests the accumulation of the token usage per tool
+func TestUsageTracker(t *testing.T) {
+	tracker := newUsageTracker()
+	tracker.add("Opsy", Usage{Requests: 1, InputTokens: 10, OutputTokens: 1, Cost: 0.5})
+	tracker.add("Git", Usage{Requests: 1, InputTokens: 20, OutputTokens: 2, Cost: 0.25})
+	total := tracker.add("Git", Usage{Requests: 1, InputTokens: 30, CacheReadTokens: 5, OutputTokens: 3, Cost: 0.25})
+
+	assert.Equal(t, Usage{Requests: 3, InputTokens: 60, OutputTokens: 6, CacheReadTokens: 5, Cost: 1}, total)
+
+	report := tracker.report()
+	assert.Equal(t, total, report.Total)
+	assert.Equal(t, Usage{Requests: 2, InputTokens: 50, OutputTokens: 5, CacheReadTokens: 5, Cost: 0.5}, report.Tools["Git"])
+	assert.Equal(t, Usage{Requests: 1, InputTokens: 10, OutputTokens: 1, Cost: 0.5}, report.Tools["Opsy"])
+
+	report.Tools["Git"] = Usage{}
+	assert.Equal(t, int64(2), tracker.report().Tools["Git"].Requests, "report should be a copy")
+}
+
+// TestRecordUsage tests recording of the token usage of the LLM responses
+func TestRecordUsage(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+
+	t.Run("records usage of each request", func(t *testing.T) {
+		agent, _, comm := newTestRetryAgent(t, 0, http.StatusOK, nil)
+		agent.cfg.Anthropic.Pricing = map[string]config.PriceConfiguration{"test-model": {Input: 1_000_000, Output: 2_000_000}}
+		comm.Usage = make(chan Usage, 10)
+
+		_, err := agent.sendMessage(context.Background(), llm.Request{}, "", logger)
+		require.NoError(t, err)
+		_, err = agent.sendMessage(context.Background(), llm.Request{}, "Git", logger)
+		require.NoError(t, err)
+
+		first, second := <-comm.Usage, <-comm.Usage
+		assert.Equal(t, Usage{Requests: 1, InputTokens: 1, OutputTokens: 2, Cost: 5}, first)
+		assert.Equal(t, Usage{Requests: 2, InputTokens: 2, OutputTokens: 4, Cost: 10}, second)
+
+		report := agent.Usage()
+		assert.Equal(t, second, report.Total)
+		assert.Equal(t, first, report.Tools[Name])
+		assert.Equal(t, first, report.Tools["Git"])
+	})
+
+	t.Run("falls back to the price of the configured model", func(t *testing.T) {
+		agent, _, _ := newTestRetryAgent(t, 0, http.StatusOK, nil)
+		agent.cfg.Anthropic.Model = "test-alias"
+		agent.cfg.Anthropic.Pricing = map[string]config.PriceConfiguration{"test-alias": {Input: 1_000_000}}
+
+		_, err := agent.sendMessage(context.Background(), llm.Request{}, "", logger)
+		require.NoError(t, err)
+		assert.Equal(t, float64(1), agent.Usage().Total.Cost)
+	})
+
+	t.Run("does not record failed requests", func(t *testing.T) {
+		agent, _, _ := newTestRetryAgent(t, 5, http.StatusBadRequest, nil)
+
+		_, err := agent.sendMessage(context.Background(), llm.Request{}, "", logger)
+		assert.Error(t, err)
+		assert.Zero(t, agent.Usage().Total.Requests)
+	})
+}
+
+// TestUsageReport_LogValue tests logging of the usage report
+func TestUsageReport_LogValue(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buffer, nil))
+	report := UsageReport{
+		Total: Usage{Requests: 2, InputTokens: 30, OutputTokens: 3, Cost: 0.5},
+		Tools: map[string]Usage{"Git": {Requests: 1, InputTokens: 20, OutputTokens: 2, Cost: 0.25}},
+	}
+
+	logger.With("usage", report).Info("Task finished.")
+	assert.Contains(t, buffer.String(), "usage.total.requests=2 usage.total.input_tokens=30 usage.total.output_tokens=3")
+	assert.Contains(t, buffer.String(), "usage.total.cost=0.5")
+	assert.Contains(t, buffer.String(), "usage.Git.input_tokens=20")
+}
+
+// alternatingTool is a tool returning its output every other call, so that every second request ends the task.
+type alternatingTool struct {
+	mockTool
+	calls atomic.Int32
+}
+
+func (t *alternatingTool) Execute(inputs map[string]any, ctx context.Context) (*tool.Output, error) {
+	if t.calls.Add(1)%2 == 0 {
+		return nil, nil
+	}
+	return t.output, t.err
+}
+
+// TestBudget tests stopping the task when it reaches a limit of its budget
+func TestBudget(t *testing.T) {
+	newBudgetAgent := func(t *testing.T, budget config.BudgetConfiguration, toolErr error) (*Agent, *atomic.Int32, *Communication, *tool.RunOptions) {
+		agent, requests, comm := newTestRetryAgent(t, 0, http.StatusOK, nil)
+		agent.cfg.Anthropic.Budget = budget
+		agent.cfg.Anthropic.Pricing = map[string]config.PriceConfiguration{"test-model": {Input: 1_000_000}}
+		comm.Messages = make(chan Message, 100)
+		opts := &tool.RunOptions{
+			Task:   "list files",
+			Caller: "Git",
+			Tools: map[string]tool.Tool{"exec": &mockTool{
+				name:   "exec",
+				schema: &jsonschema.Schema{},
+				output: &tool.Output{Result: "file"},
+				err:    toolErr,
+			}},
+		}
+
+		return agent, requests, comm, opts
+	}
+
+	lastMessage := func(comm *Communication) Message {
+		var last Message
+		for len(comm.Messages) > 0 {
+			last = <-comm.Messages
+		}
+		return last
+	}
+
+	tests := []struct {
+		name     string
+		budget   config.BudgetConfiguration
+		requests int32
+		reason   string
+	}{
+		{
+			name:     "stops after max iterations",
+			budget:   config.BudgetConfiguration{MaxIterations: 3},
+			requests: 3,
+			reason:   "reached the limit of 3 iterations",
+		},
+		{
+			name:     "stops after max total tokens",
+			budget:   config.BudgetConfiguration{MaxTotalTokens: 6},
+			requests: 2,
+			reason:   "used 6 tokens of the limit of 6 tokens",
+		},
+		{
+			name:     "stops after max cost",
+			budget:   config.BudgetConfiguration{MaxCost: 2},
+			requests: 2,
+			reason:   "cost $2.000 of the limit of $2.000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent, requests, comm, opts := newBudgetAgent(t, tt.budget, nil)
+
+			output, err := agent.Run(opts, context.Background())
+			assert.ErrorIs(t, err, errBudgetExceeded)
+			assert.Contains(t, err.Error(), tt.reason)
+			assert.Equal(t, tt.requests, requests.Load())
+			assert.Len(t, output, int(tt.requests))
+
+			message := lastMessage(comm)
+			assert.Equal(t, "Git", message.Tool)
+			assert.Equal(t, "Stopped: the task "+tt.reason+". Adjust `anthropic.budget` to allow more.", message.Message)
+		})
+	}
+
+	t.Run("measures the budget of each follow-up on its own", func(t *testing.T) {
+		agent, requests, _, opts := newBudgetAgent(t, config.BudgetConfiguration{MaxTotalTokens: 6, MaxCost: 2.5}, nil)
+		opts.Caller = ""
+		opts.Tools = map[string]tool.Tool{"exec": &alternatingTool{
+			mockTool: mockTool{name: "exec", schema: &jsonschema.Schema{}, output: &tool.Output{Result: "file"}},
+		}}
+		conversation := NewConversation()
+
+		for range 2 {
+			_, err := agent.Continue(conversation, opts, context.Background())
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, int32(4), requests.Load(), "each follow-up sends 2 requests of 3 tokens")
+		total := agent.Usage().Total
+		assert.Equal(t, int64(12), total.TotalInputTokens()+total.OutputTokens)
+	})
+
+	t.Run("stops when a tool exceeds the budget", func(t *testing.T) {
+		agent, requests, comm, opts := newBudgetAgent(t, config.BudgetConfiguration{},
+			fmt.Errorf("%w: reached the limit of 1 iterations", errBudgetExceeded))
+
+		_, err := agent.Run(opts, context.Background())
+		assert.ErrorIs(t, err, errBudgetExceeded)
+		assert.Equal(t, int32(1), requests.Load())
+		assert.NotContains(t, lastMessage(comm).Message, "Stopped:", "the tool reports the budget")
+	})
+}
@@ -0,0 +1,307 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/datolabs-io/opsy/internal/tool"
+)
+
+const (
+	// ErrWriteEntry is the error returned when an entry cannot be written to the audit log.
+	ErrWriteEntry = "failed to write audit log entry"
+	// ErrReadLog is the error returned when the audit log cannot be read.
+	ErrReadLog = "failed to read audit log"
+	// ErrTampered is the error returned when an entry of the audit log does not match its hash chain.
+	ErrTampered = "audit log entry does not match the hash chain"
+
+	// maxLineSize is the maximum size of an entry of the audit log.
+	maxLineSize = 10 * 1024 * 1024
+)
+
+// Entry is an entry of the audit log, recording an executed command.
+type Entry struct {
+	// Time is the time the entry was recorded.
+	Time time.Time `json:"time"`
+	// User is the OS user running opsy.
+	User string `json:"user"`
+	// Hostname is the host running opsy.
+	Hostname string `json:"hostname"`
+	// SessionID identifies the session the command was executed in, if sessions are enabled.
+	SessionID string `json:"session_id,omitempty"`
+	// Task is the task given by the user.
+	Task string `json:"task"`
+	// Caller is the tool whose agent executed the command; empty for the main agent.
+	Caller string `json:"caller,omitempty"`
+	// CommandID identifies the command.
+	CommandID string `json:"command_id"`
+	// Command is the executed command.
+	Command string `json:"command"`
+	// WorkingDirectory is the working directory of the command.
+	WorkingDirectory string `json:"working_directory"`
+	// Host is the name of the remote host the command was executed on, empty for local commands.
+	Host string `json:"host,omitempty"`
+	// Classification is the impact classification of the command.
+	Classification tool.Classification `json:"classification,omitempty"`
+	// DryRun is true when the command was recorded instead of being executed.
+	DryRun bool `json:"dry_run,omitempty"`
+	// StartedAt is the time the command started.
+	StartedAt time.Time `json:"started_at"`
+	// CompletedAt is the time the command completed.
+	CompletedAt time.Time `json:"completed_at"`
+	// ExitCode is the exit code of the command.
+	ExitCode int `json:"exit_code"`
+	// OutputHash is the SHA-256 hash of the output of the command.
+	OutputHash string `json:"output_hash"`
+	// PrevHash is the hash of the previous entry, if the entries are hash-chained.
+	PrevHash string `json:"prev_hash,omitempty"`
+	// Hash is the hash of the entry and the previous hash, if the entries are hash-chained.
+	Hash string `json:"hash,omitempty"`
+}
+
+// Origin is the origin of the commands executed for a task.
+type Origin struct {
+	// SessionID identifies the session of the task.
+	SessionID string
+	// Task is the task given by the user.
+	Task string
+}
+
+// Log is an append-only audit log of the executed commands, with an entry per line. It is safe for concurrent use.
+type Log struct {
+	mu        sync.Mutex
+	path      string
+	hashChain bool
+	user      string
+	hostname  string
+	lastHash  string
+	loaded    bool
+	logger    *slog.Logger
+}
+
+// Option is a function that configures the Log.
+type Option func(*Log)
+
+// originKey is the key of the origin in a context.
+type originKey struct{}
+
+// New creates a new audit Log.
+func New(opts ...Option) *Log {
+	cfg := config.New().GetConfig()
+	l := &Log{
+		path:      cfg.Audit.Path,
+		hashChain: cfg.Audit.HashChain,
+		user:      currentUser(),
+		logger:    slog.New(slog.DiscardHandler),
+	}
+	l.hostname, _ = os.Hostname()
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// WithConfig sets the path of the audit log and whether its entries are hash-chained from the configuration.
+func WithConfig(cfg config.Configuration) Option {
+	return func(l *Log) {
+		l.path = cfg.Audit.Path
+		l.hashChain = cfg.Audit.HashChain
+	}
+}
+
+// WithLogger sets the logger for the Log.
+func WithLogger(logger *slog.Logger) Option {
+	return func(l *Log) {
+		l.logger = logger.With("component", "audit")
+	}
+}
+
+// WithOrigin returns a copy of the context that carries the origin of the commands executed for a task.
+func WithOrigin(ctx context.Context, origin Origin) context.Context {
+	return context.WithValue(ctx, originKey{}, origin)
+}
+
+// OriginFromContext returns the origin carried by the context, if any.
+func OriginFromContext(ctx context.Context) (Origin, bool) {
+	origin, ok := ctx.Value(originKey{}).(Origin)
+	return origin, ok
+}
+
+// Record appends an entry for the command to the audit log. The session and task are taken from the origin carried
+// by the context; the given task is used if the context carries none.
+func (l *Log) Record(ctx context.Context, caller, task string, cmd tool.Command) error {
+	origin, ok := OriginFromContext(ctx)
+	if !ok {
+		origin = Origin{Task: task}
+	}
+
+	output := sha256.Sum256([]byte(cmd.Output))
+	entry := Entry{
+		Time:             time.Now(),
+		User:             l.user,
+		Hostname:         l.hostname,
+		SessionID:        origin.SessionID,
+		Task:             origin.Task,
+		Caller:           caller,
+		CommandID:        cmd.ID,
+		Command:          cmd.Command,
+		WorkingDirectory: cmd.WorkingDirectory,
+		Host:             cmd.Host,
+		Classification:   cmd.Classification,
+		DryRun:           cmd.DryRun,
+		StartedAt:        cmd.StartedAt,
+		CompletedAt:      cmd.CompletedAt,
+		ExitCode:         cmd.ExitCode,
+		OutputHash:       hex.EncodeToString(output[:]),
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.hashChain {
+		if err := l.loadLastHash(); err != nil {
+			return fmt.Errorf("%s: %w", ErrWriteEntry, err)
+		}
+		entry.PrevHash = l.lastHash
+		entry.Hash = hashEntry(entry)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ErrWriteEntry, err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ErrWriteEntry, err)
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", ErrWriteEntry, err)
+	}
+
+	l.lastHash = entry.Hash
+	l.logger.With("command_id", cmd.ID).Debug("Command recorded in the audit log.")
+
+	return nil
+}
+
+// Verify reads the audit log and checks that every entry matches the hash chain, returning the number of entries.
+// The error of a tampered, removed or reordered entry reports its line number.
+func Verify(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	entries := 0
+	prevHash := ""
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, fmt.Errorf("%s: line %d: %w", ErrReadLog, line, err)
+		}
+
+		if entry.Hash == "" || entry.PrevHash != prevHash || hashEntry(entry) != entry.Hash {
+			return entries, fmt.Errorf("%s: line %d", ErrTampered, line)
+		}
+
+		prevHash = entry.Hash
+		entries++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return entries, fmt.Errorf("%s: %w", ErrReadLog, err)
+	}
+
+	return entries, nil
+}
+
+// VerifyFile verifies the audit log at the given path.
+func VerifyFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", ErrReadLog, err)
+	}
+	defer file.Close()
+
+	return Verify(file)
+}
+
+// loadLastHash reads the hash of the last entry of the audit log, once, so that new entries continue its chain.
+func (l *Log) loadLastHash() error {
+	if l.loaded {
+		return nil
+	}
+
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		l.loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	last := []byte{}
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			last = append(last[:0], scanner.Bytes()...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(last) > 0 {
+		var entry Entry
+		if err := json.Unmarshal(last, &entry); err != nil {
+			return err
+		}
+		l.lastHash = entry.Hash
+	}
+	l.loaded = true
+
+	return nil
+}
+
+// hashEntry returns the SHA-256 hash of the entry without its hash, which includes the hash of the previous entry.
+func hashEntry(entry Entry) string {
+	entry.Hash = ""
+	data, _ := json.Marshal(entry)
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// currentUser returns the name of the OS user running opsy.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
@@ -0,0 +1,185 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/datolabs-io/opsy/internal/tool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLog creates an audit log in a temporary directory.
+func newTestLog(t *testing.T, hashChain bool) *Log {
+	t.Helper()
+
+	cfg := config.Configuration{Audit: config.AuditConfiguration{
+		Enabled:   true,
+		Path:      filepath.Join(t.TempDir(), "audit.jsonl"),
+		HashChain: hashChain,
+	}}
+	return New(WithConfig(cfg))
+}
+
+// readEntries reads the entries of the audit log.
+func readEntries(t *testing.T, path string) []Entry {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// testCommand returns an executed command.
+func testCommand(id string) tool.Command {
+	return tool.Command{
+		ID:               id,
+		Command:          "kubectl get pods",
+		WorkingDirectory: "/srv",
+		ExitCode:         1,
+		Output:           "error: no context",
+		Classification:   tool.ClassificationReadOnly,
+		StartedAt:        time.Now().Add(-time.Second),
+		CompletedAt:      time.Now(),
+	}
+}
+
+// TestRecord tests recording commands in the audit log.
+func TestRecord(t *testing.T) {
+	t.Run("records the command and its origin", func(t *testing.T) {
+		log := newTestLog(t, false)
+		ctx := WithOrigin(context.Background(), Origin{SessionID: "session-1", Task: "check the pods"})
+
+		require.NoError(t, log.Record(ctx, "kubernetes", "list the pods", testCommand("cmd-1")))
+
+		info, err := os.Stat(log.path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		entries := readEntries(t, log.path)
+		require.Len(t, entries, 1)
+		entry := entries[0]
+		output := sha256.Sum256([]byte("error: no context"))
+		assert.Equal(t, "session-1", entry.SessionID)
+		assert.Equal(t, "check the pods", entry.Task)
+		assert.Equal(t, "kubernetes", entry.Caller)
+		assert.Equal(t, "cmd-1", entry.CommandID)
+		assert.Equal(t, "kubectl get pods", entry.Command)
+		assert.Equal(t, "/srv", entry.WorkingDirectory)
+		assert.Equal(t, 1, entry.ExitCode)
+		assert.Equal(t, tool.ClassificationReadOnly, entry.Classification)
+		assert.Equal(t, hex.EncodeToString(output[:]), entry.OutputHash)
+		assert.Equal(t, log.user, entry.User)
+		assert.Equal(t, log.hostname, entry.Hostname)
+		assert.NotEmpty(t, entry.User)
+		assert.Empty(t, entry.Hash)
+		assert.Empty(t, entry.PrevHash)
+	})
+
+	t.Run("uses the task without origin", func(t *testing.T) {
+		log := newTestLog(t, false)
+		require.NoError(t, log.Record(context.Background(), "", "list the pods", testCommand("cmd-1")))
+
+		entries := readEntries(t, log.path)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "list the pods", entries[0].Task)
+		assert.Empty(t, entries[0].SessionID)
+	})
+
+	t.Run("chains the entries across logs", func(t *testing.T) {
+		log := newTestLog(t, true)
+		require.NoError(t, log.Record(context.Background(), "", "task", testCommand("cmd-1")))
+		require.NoError(t, log.Record(context.Background(), "", "task", testCommand("cmd-2")))
+
+		reopened := New(WithConfig(config.Configuration{Audit: config.AuditConfiguration{Path: log.path, HashChain: true}}))
+		require.NoError(t, reopened.Record(context.Background(), "", "task", testCommand("cmd-3")))
+
+		entries := readEntries(t, log.path)
+		require.Len(t, entries, 3)
+		assert.Empty(t, entries[0].PrevHash)
+		assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+		assert.Equal(t, entries[1].Hash, entries[2].PrevHash)
+
+		count, err := VerifyFile(log.path)
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+	})
+
+	t.Run("returns error when the log cannot be written", func(t *testing.T) {
+		log := New(WithConfig(config.Configuration{Audit: config.AuditConfiguration{
+			Path: filepath.Join(t.TempDir(), "missing", "audit.jsonl"),
+		}}))
+		err := log.Record(context.Background(), "", "task", testCommand("cmd-1"))
+		assert.ErrorContains(t, err, ErrWriteEntry)
+	})
+}
+
+// TestVerify tests detecting tampering with the audit log.
+func TestVerify(t *testing.T) {
+	newChainedLog := func(t *testing.T) []string {
+		t.Helper()
+
+		log := newTestLog(t, true)
+		for _, id := range []string{"cmd-1", "cmd-2", "cmd-3"} {
+			require.NoError(t, log.Record(context.Background(), "", "task", testCommand(id)))
+		}
+
+		data, err := os.ReadFile(log.path)
+		require.NoError(t, err)
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+
+	t.Run("accepts an intact log", func(t *testing.T) {
+		count, err := Verify(strings.NewReader(strings.Join(newChainedLog(t), "\n")))
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+	})
+
+	t.Run("detects a modified entry", func(t *testing.T) {
+		lines := newChainedLog(t)
+		lines[1] = strings.Replace(lines[1], `"exit_code":1`, `"exit_code":0`, 1)
+
+		count, err := Verify(strings.NewReader(strings.Join(lines, "\n")))
+		assert.EqualError(t, err, ErrTampered+": line 2")
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("detects a removed entry", func(t *testing.T) {
+		lines := newChainedLog(t)
+
+		_, err := Verify(strings.NewReader(lines[0] + "\n" + lines[2]))
+		assert.EqualError(t, err, ErrTampered+": line 2")
+	})
+
+	t.Run("detects unchained entries", func(t *testing.T) {
+		log := newTestLog(t, false)
+		require.NoError(t, log.Record(context.Background(), "", "task", testCommand("cmd-1")))
+
+		_, err := VerifyFile(log.path)
+		assert.EqualError(t, err, ErrTampered+": line 1")
+	})
+
+	t.Run("returns error for invalid entries", func(t *testing.T) {
+		_, err := Verify(strings.NewReader("{"))
+		assert.ErrorContains(t, err, ErrReadLog+": line 1")
+	})
+}
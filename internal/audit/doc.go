@@ -0,0 +1,46 @@
+/*
+Package audit provides the audit log of the opsy application, recording every command executed by the agent and its
+tools.
+
+The audit log is a JSONL file, audit.path (~/.opsy/audit.jsonl by default), readable only by the user. Entries are
+only ever appended to it, so it can be shipped to a central log store or kept as a record of what opsy did.
+
+# Entries
+
+Each Entry records:
+
+  - Time, User and Hostname: When, by whom and where the command was recorded
+  - SessionID and Task: The session and the task the command was executed for
+  - Caller: The tool whose agent executed the command, empty for the main agent
+  - CommandID, Command, WorkingDirectory and Host: What was executed, and where
+  - Classification and DryRun: The impact of the command and whether it was only recorded
+  - StartedAt, CompletedAt and ExitCode: When the command ran and how it ended
+  - OutputHash: The SHA-256 hash of the output, so the output is not stored but can be matched
+
+The session and task are taken from the Origin carried by the context:
+
+	ctx = audit.WithOrigin(ctx, audit.Origin{SessionID: s.ID, Task: task})
+	log := audit.New(audit.WithConfig(cfg), audit.WithLogger(logger))
+	err := log.Record(ctx, caller, task, cmd)
+
+# Hash Chain
+
+With audit.hash_chain enabled, each entry includes PrevHash, the hash of the previous entry, and Hash, the SHA-256
+hash of the entry itself without Hash. The chain continues across runs, since the Log reads the hash of the last
+entry of the file before appending its first entry.
+
+# Verification
+
+Verify and VerifyFile read the audit log and check every entry against the chain, returning the number of valid
+entries. A modified, removed or reordered entry, or an entry without a hash, is reported with its line number, for
+the `opsy audit verify` command.
+
+# Error Handling
+
+The package defines the following errors:
+
+  - ErrWriteEntry: The entry could not be written to the audit log
+  - ErrReadLog: The audit log could not be read or decoded
+  - ErrTampered: An entry does not match the hash chain
+*/
+package audit
@@ -4,9 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -18,6 +21,12 @@ type Configuration struct {
 	UI UIConfiguration `yaml:"ui"`
 	// Logging is the configuration for the logging.
 	Logging LoggingConfiguration `yaml:"logging"`
+	// Sessions is the configuration for the persisted sessions.
+	Sessions SessionsConfiguration `yaml:"sessions"`
+	// Audit is the configuration for the audit log of the executed commands.
+	Audit AuditConfiguration `yaml:"audit"`
+	// LLM is the configuration for the large language model provider.
+	LLM LLMConfiguration `yaml:"llm"`
 	// Anthropic is the configuration for the Anthropic API.
 	Anthropic AnthropicConfiguration `yaml:"anthropic"`
 	// Tools is the configuration for the tools.
@@ -30,6 +39,38 @@ type UIConfiguration struct {
 	Theme string `yaml:"theme"`
 }
 
+// LLMConfiguration is the configuration for the large language model provider.
+type LLMConfiguration struct {
+	// Provider is the provider of the large language model, one of Providers.
+	Provider string `yaml:"provider"`
+	// OpenAI is the configuration for the OpenAI-compatible API.
+	OpenAI OpenAIConfiguration `yaml:"openai"`
+	// Replay is the configuration for replaying a recorded transcript.
+	Replay ReplayConfiguration `yaml:"replay"`
+	// Record is the path of the transcript the responses of the provider are recorded to, if set.
+	Record string `yaml:"record"`
+}
+
+// OpenAIConfiguration is the configuration for an OpenAI-compatible API, such as OpenAI, vLLM, Ollama or LM Studio.
+type OpenAIConfiguration struct {
+	// BaseURL is the base URL of the API.
+	BaseURL string `mapstructure:"base_url" yaml:"base_url"`
+	// APIKey is the API key for the API, if it requires one.
+	APIKey string `mapstructure:"api_key" yaml:"api_key"`
+	// Model is the model to use.
+	Model string `yaml:"model"`
+	// Temperature is the temperature to use.
+	Temperature float64 `yaml:"temperature"`
+	// MaxTokens is the maximum number of tokens to generate.
+	MaxTokens int64 `mapstructure:"max_tokens" yaml:"max_tokens"`
+}
+
+// ReplayConfiguration is the configuration for replaying a recorded transcript instead of calling a model.
+type ReplayConfiguration struct {
+	// Path is the path to the transcript, a JSONL or YAML file.
+	Path string `yaml:"path"`
+}
+
 // LoggingConfiguration is the configuration for the logging.
 type LoggingConfiguration struct {
 	// Path is the path to the log file.
@@ -38,12 +79,64 @@ type LoggingConfiguration struct {
 	Level string `yaml:"level"`
 }
 
+// SessionsConfiguration is the configuration for the sessions persisted so that interrupted tasks can be resumed.
+type SessionsConfiguration struct {
+	// Enabled is whether the conversation, commands and status of each run are persisted.
+	Enabled bool `yaml:"enabled"`
+	// Path is the directory the sessions are persisted to.
+	Path string `yaml:"path"`
+}
+
+// AuditConfiguration is the configuration for the audit log of the executed commands.
+type AuditConfiguration struct {
+	// Enabled is whether every executed command is recorded in the audit log.
+	Enabled bool `yaml:"enabled"`
+	// Path is the path to the audit log, a JSONL file.
+	Path string `yaml:"path"`
+	// HashChain is whether each entry includes the hash of the previous one, so that tampering can be detected.
+	HashChain bool `mapstructure:"hash_chain" yaml:"hash_chain"`
+}
+
 // ToolsConfiguration is the configuration for the tools.
 type ToolsConfiguration struct {
 	// Timeout is the maximum duration in seconds for a tool to execute.
 	Timeout int64 `yaml:"timeout"`
 	// Exec is the configuration for the exec tool.
 	Exec ExecToolConfiguration `yaml:"exec"`
+	// Parallel is the configuration for the parallel execution of the tools called by the model.
+	Parallel ParallelConfiguration `yaml:"parallel"`
+	// Overrides are the configurations of the tools loaded from their definitions, by tool name, e.g. tools.kubectl.
+	Overrides map[string]ToolConfiguration `mapstructure:",remain" yaml:",inline"`
+}
+
+// ToolConfiguration is the configuration of a tool, overriding its definition.
+type ToolConfiguration struct {
+	// Env are the environment variables of the commands of the tool, replacing the ones of its definition with the
+	// same name.
+	Env []EnvVar `yaml:"env"`
+}
+
+// EnvVar is an environment variable of the commands of a tool. Its value is either the literal Value, the value of
+// the FromEnv variable of the host environment, or the output of Command, e.g. to read a secret from a password
+// manager.
+type EnvVar struct {
+	// Name is the name of the variable.
+	Name string `yaml:"name"`
+	// Value is the literal value of the variable.
+	Value string `yaml:"value,omitempty"`
+	// FromEnv is the name of the variable of the host environment the value is read from.
+	FromEnv string `mapstructure:"from_env" yaml:"from_env,omitempty"`
+	// Command is the shell command whose output is the value, without the trailing newlines.
+	Command string `yaml:"command,omitempty"`
+}
+
+// ParallelConfiguration is the configuration for the parallel execution of the tools called by the model in a single
+// response.
+type ParallelConfiguration struct {
+	// Enabled is whether the model may call several tools in a single response, which are then executed concurrently.
+	Enabled bool `yaml:"enabled"`
+	// MaxConcurrency is the maximum number of tools executed at the same time.
+	MaxConcurrency int `mapstructure:"max_concurrency" yaml:"max_concurrency"`
 }
 
 // ExecToolConfiguration is the configuration for the exec tool.
@@ -52,6 +145,129 @@ type ExecToolConfiguration struct {
 	Timeout int64 `yaml:"timeout"`
 	// Shell is the shell to use for the exec tool.
 	Shell string `yaml:"shell"`
+	// Approval is whether commands must be approved by the user before they are executed.
+	Approval bool `yaml:"approval"`
+	// Policy is the policy applied to commands before they are executed.
+	Policy PolicyConfiguration `yaml:"policy"`
+	// DryRun is the configuration for the dry-run mode.
+	DryRun DryRunConfiguration `mapstructure:"dry_run" yaml:"dry_run"`
+	// Output is the configuration for the output of commands returned to the agent.
+	Output OutputConfiguration `yaml:"output"`
+	// Redaction is the configuration for the redaction of secrets from the output of commands.
+	Redaction RedactionConfiguration `yaml:"redaction"`
+	// Sandbox is the configuration for the sandbox the commands are executed in.
+	Sandbox SandboxConfiguration `yaml:"sandbox"`
+	// Backend is the backend the commands are executed with, one of Backends.
+	Backend string `yaml:"backend"`
+	// Container is the configuration for the container backend.
+	Container ContainerConfiguration `yaml:"container"`
+	// Hosts are the remote hosts the commands can be executed on over SSH, by name.
+	Hosts map[string]HostConfiguration `yaml:"hosts"`
+}
+
+// HostConfiguration is the configuration for a remote host the commands are executed on over SSH.
+type HostConfiguration struct {
+	// Host is the hostname or address of the host.
+	Host string `yaml:"host"`
+	// User is the user logging in to the host, the SSH default if empty.
+	User string `yaml:"user"`
+	// Port is the SSH port of the host, the SSH default if 0.
+	Port int `yaml:"port"`
+	// Key is the path to the private key used to log in to the host, the SSH default if empty.
+	Key string `yaml:"key"`
+	// Jump are the jump hosts the host is reached through, in order, either names of hosts or [user@]host[:port].
+	Jump []string `yaml:"jump"`
+}
+
+// ContainerConfiguration is the configuration for the container backend, executing the commands in a container through
+// a local container engine CLI, such as docker or podman.
+type ContainerConfiguration struct {
+	// Engine is the container engine CLI.
+	Engine string `yaml:"engine"`
+	// Image is the image of the container the commands are executed in.
+	Image string `yaml:"image"`
+	// Shell is the shell executing the commands in the container.
+	Shell string `yaml:"shell"`
+	// Mounts are the host paths, such as credential directories, mounted read-only at the same path in the container.
+	Mounts []string `yaml:"mounts"`
+	// Env are the names of the environment variables passed from the host to the container.
+	Env []string `yaml:"env"`
+	// Args are additional arguments of the run command of the container engine, such as --network.
+	Args []string `yaml:"args"`
+}
+
+// SandboxConfiguration is the configuration for the sandbox the commands are executed in, using bubblewrap. The root
+// filesystem is read-only, except for the working directory of the command and the writable paths.
+type SandboxConfiguration struct {
+	// Enabled is whether the commands are executed in the sandbox.
+	Enabled bool `yaml:"enabled"`
+	// IsolateNetwork is whether the commands are executed without network access.
+	IsolateNetwork bool `mapstructure:"isolate_network" yaml:"isolate_network"`
+	// Writable are the paths writable by the commands in addition to their working directory.
+	Writable []string `yaml:"writable"`
+	// Env are the names of the environment variables passed to the commands in addition to the basic ones, such as
+	// PATH and HOME.
+	Env []string `yaml:"env"`
+}
+
+// RedactionConfiguration is the configuration for the redaction of secrets from the output of commands, before it is
+// logged, shown or sent to the model.
+type RedactionConfiguration struct {
+	// Enabled is whether secrets are redacted from the output of commands.
+	Enabled bool `yaml:"enabled"`
+	// Patterns are the user-defined patterns redacted in addition to the built-in detectors.
+	Patterns []RedactionPattern `yaml:"patterns"`
+}
+
+// RedactionPattern is a user-defined pattern of secrets.
+type RedactionPattern struct {
+	// Name is the name shown in place of the redacted secrets.
+	Name string `yaml:"name,omitempty"`
+	// Match is the regular expression matched against each line of output. If it has capture groups, only the first
+	// group is redacted.
+	Match string `yaml:"match"`
+}
+
+// OutputConfiguration is the configuration for the output of commands returned to the agent.
+type OutputConfiguration struct {
+	// MaxBytes is the maximum number of bytes of output returned to the agent, 0 disables the limit.
+	MaxBytes int `mapstructure:"max_bytes" yaml:"max_bytes"`
+	// MaxLines is the maximum number of lines of output returned to the agent, 0 disables the limit.
+	MaxLines int `mapstructure:"max_lines" yaml:"max_lines"`
+	// Spill is whether the full output of truncated commands is saved to a file the agent can inspect.
+	Spill bool `yaml:"spill"`
+	// Path is the directory the full output of truncated commands is saved to.
+	Path string `yaml:"path"`
+}
+
+// DryRunConfiguration is the configuration for the dry-run mode.
+type DryRunConfiguration struct {
+	// Enabled is whether commands are recorded instead of being executed.
+	Enabled bool `yaml:"enabled"`
+	// RunReadOnly is whether read-only commands are still executed in dry-run mode.
+	RunReadOnly bool `mapstructure:"run_read_only" yaml:"run_read_only"`
+}
+
+// PolicyConfiguration is the configuration for the command policy.
+type PolicyConfiguration struct {
+	// ReadOnly is the action for commands that do not change any state.
+	ReadOnly string `mapstructure:"read_only" yaml:"read_only"`
+	// Mutating is the action for commands that change state.
+	Mutating string `yaml:"mutating"`
+	// Destructive is the action for commands that delete resources or rewrite history.
+	Destructive string `yaml:"destructive"`
+	// Rules are the rules matched against commands before the defaults are applied.
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+// PolicyRule is a rule matched against a command.
+type PolicyRule struct {
+	// Match is the regular expression matched against each command of a shell pipeline or list.
+	Match string `yaml:"match"`
+	// Action is the action applied to matching commands.
+	Action string `yaml:"action"`
+	// Reason is the reason reported when the rule denies or asks for a command.
+	Reason string `yaml:"reason,omitempty"`
 }
 
 // AnthropicConfiguration is the configuration for the Anthropic API.
@@ -64,6 +280,46 @@ type AnthropicConfiguration struct {
 	Temperature float64 `yaml:"temperature"`
 	// MaxTokens is the maximum number of tokens to use for the Anthropic API.
 	MaxTokens int64 `mapstructure:"max_tokens" yaml:"max_tokens"`
+	// Retry is the retry policy for failed Anthropic API requests.
+	Retry RetryConfiguration `yaml:"retry"`
+	// Pricing is the price of each model, used to estimate the cost of the tasks.
+	Pricing map[string]PriceConfiguration `yaml:"pricing"`
+	// Budget is the budget of a task, including the tasks delegated to the tools.
+	Budget BudgetConfiguration `yaml:"budget"`
+}
+
+// BudgetConfiguration is the budget of a task. A limit of 0 disables it.
+type BudgetConfiguration struct {
+	// MaxIterations is the maximum number of requests to the Anthropic API in a single run of the agent or a tool.
+	MaxIterations int64 `mapstructure:"max_iterations" yaml:"max_iterations"`
+	// MaxTotalTokens is the maximum number of input and output tokens used by the task.
+	MaxTotalTokens int64 `mapstructure:"max_total_tokens" yaml:"max_total_tokens"`
+	// MaxCost is the maximum estimated cost of the task in USD.
+	MaxCost float64 `mapstructure:"max_cost" yaml:"max_cost"`
+}
+
+// PriceConfiguration is the price of a model in USD per million tokens.
+type PriceConfiguration struct {
+	// Input is the price of input tokens.
+	Input float64 `yaml:"input"`
+	// Output is the price of output tokens.
+	Output float64 `yaml:"output"`
+	// CacheWrite is the price of input tokens written to the prompt cache.
+	CacheWrite float64 `mapstructure:"cache_write" yaml:"cache_write"`
+	// CacheRead is the price of input tokens read from the prompt cache.
+	CacheRead float64 `mapstructure:"cache_read" yaml:"cache_read"`
+}
+
+// RetryConfiguration is the retry policy for failed Anthropic API requests.
+type RetryConfiguration struct {
+	// MaxAttempts is the maximum number of attempts for a request, including the first one.
+	MaxAttempts int64 `mapstructure:"max_attempts" yaml:"max_attempts"`
+	// BaseDelay is the delay in seconds before the first retry, doubled on every following retry.
+	BaseDelay float64 `mapstructure:"base_delay" yaml:"base_delay"`
+	// MaxDelay is the maximum delay in seconds between retries, including the ones requested by the API.
+	MaxDelay float64 `mapstructure:"max_delay" yaml:"max_delay"`
+	// Jitter is the fraction of the delay randomly added or removed to spread out retries.
+	Jitter float64 `yaml:"jitter"`
 }
 
 // Configurer is an interface for managing configuration.
@@ -83,11 +339,12 @@ type Config struct {
 }
 
 const (
-	dirConfig  = ".opsy"
-	dirCache   = ".opsy/cache"
-	envPrefix  = "OPSY"
-	configFile = "config"
-	configType = "yaml"
+	dirConfig   = ".opsy"
+	dirCache    = ".opsy/cache"
+	dirSessions = ".opsy/sessions"
+	envPrefix   = "OPSY"
+	configFile  = "config"
+	configType  = "yaml"
 )
 
 var (
@@ -107,6 +364,20 @@ var (
 	ErrInvalidTemp = errors.New("anthropic temperature must be between 0 and 1")
 	// ErrInvalidMaxTokens is returned when the Anthropic max tokens are invalid.
 	ErrInvalidMaxTokens = errors.New("anthropic max tokens must be greater than 0")
+	// ErrInvalidRetry is returned when the Anthropic retry policy is invalid.
+	ErrInvalidRetry = errors.New("invalid anthropic retry policy")
+	// ErrInvalidPricing is returned when a price of the Anthropic pricing is invalid.
+	ErrInvalidPricing = errors.New("anthropic prices must not be negative")
+	// ErrInvalidBudget is returned when a limit of the Anthropic budget is invalid.
+	ErrInvalidBudget = errors.New("anthropic budget limits must not be negative")
+	// ErrInvalidProvider is returned when the LLM provider is invalid.
+	ErrInvalidProvider = errors.New("invalid llm provider")
+	// ErrInvalidOpenAI is returned when the OpenAI-compatible API configuration is invalid.
+	ErrInvalidOpenAI = errors.New("invalid llm openai configuration")
+	// ErrInvalidReplay is returned when the replay configuration is invalid.
+	ErrInvalidReplay = errors.New("llm replay path is required")
+	// ErrInvalidAudit is returned when the audit log configuration is invalid.
+	ErrInvalidAudit = errors.New("audit log path is required")
 	// ErrInvalidLogLevel is returned when the logging level is invalid.
 	ErrInvalidLogLevel = errors.New("invalid logging level")
 	// ErrInvalidTheme is returned when the theme is invalid.
@@ -119,8 +390,60 @@ var (
 	ErrValidateConfig = errors.New("invalid config")
 	// ErrInvalidShell is returned when the shell is invalid.
 	ErrInvalidShell = errors.New("invalid exec shell")
+	// ErrInvalidPolicyAction is returned when a policy action is invalid.
+	ErrInvalidPolicyAction = errors.New("invalid exec policy action")
+	// ErrInvalidPolicyRule is returned when a policy rule is invalid.
+	ErrInvalidPolicyRule = errors.New("invalid exec policy rule")
+	// ErrInvalidOutputLimits is returned when the output limits are invalid.
+	ErrInvalidOutputLimits = errors.New("exec output limits must not be negative")
+	// ErrInvalidParallel is returned when the parallel tool execution configuration is invalid.
+	ErrInvalidParallel = errors.New("tools parallel max concurrency must be greater than 0")
+	// ErrInvalidBackend is returned when the exec backend is invalid.
+	ErrInvalidBackend = errors.New("invalid exec backend")
+	// ErrInvalidContainer is returned when the container backend configuration is invalid.
+	ErrInvalidContainer = errors.New("invalid exec container configuration")
+	// ErrInvalidHost is returned when a remote host configuration is invalid.
+	ErrInvalidHost = errors.New("invalid exec host")
+	// ErrInvalidRedactionPattern is returned when a redaction pattern is invalid.
+	ErrInvalidRedactionPattern = errors.New("invalid exec redaction pattern")
+	// ErrInvalidEnv is returned when an environment variable of a tool is invalid.
+	ErrInvalidEnv = errors.New("invalid tool env")
+)
+
+// DefaultPricing is the price of the Anthropic models in USD per million tokens, used unless overridden in the
+// configuration.
+var DefaultPricing = map[string]PriceConfiguration{
+	"claude-3-5-haiku-latest":  {Input: 0.8, Output: 4, CacheWrite: 1, CacheRead: 0.08},
+	"claude-3-7-sonnet-latest": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3},
+	"claude-sonnet-4-0":        {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3},
+	"claude-opus-4-0":          {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.5},
+}
+
+const (
+	// ProviderAnthropic is the provider of the Anthropic API.
+	ProviderAnthropic = "anthropic"
+	// ProviderOpenAI is the provider of OpenAI-compatible APIs.
+	ProviderOpenAI = "openai"
+	// ProviderReplay is the provider replaying a recorded transcript.
+	ProviderReplay = "replay"
+)
+
+// Providers are the valid LLM providers.
+var Providers = []string{ProviderAnthropic, ProviderOpenAI, ProviderReplay}
+
+const (
+	// BackendHost is the backend executing the commands with the shell of the host.
+	BackendHost = "host"
+	// BackendContainer is the backend executing the commands in a container.
+	BackendContainer = "container"
 )
 
+// Backends are the valid exec backends.
+var Backends = []string{BackendHost, BackendContainer}
+
+// PolicyActions are the valid actions of the command policy.
+var PolicyActions = []string{"allow", "deny", "ask"}
+
 // New creates a new config instance.
 func New() *Config {
 	homeDir, _ := os.UserHomeDir()
@@ -147,6 +470,7 @@ func New() *Config {
 	viper.SetConfigType(configType)
 
 	_ = viper.BindEnv("anthropic.api_key", "ANTHROPIC_API_KEY")
+	_ = viper.BindEnv("llm.openai.api_key", "OPENAI_API_KEY")
 
 	return config
 }
@@ -171,6 +495,8 @@ func (c *Config) LoadConfig() error {
 		return fmt.Errorf("%w: %v", ErrUnmarshalConfig, err)
 	}
 
+	c.mergePricing()
+
 	if err := c.validate(); err != nil {
 		return fmt.Errorf("%w: %v", ErrValidateConfig, err)
 	}
@@ -178,6 +504,19 @@ func (c *Config) LoadConfig() error {
 	return nil
 }
 
+// mergePricing adds the default price of the models missing from the configured pricing.
+func (c *Config) mergePricing() {
+	if c.configuration.Anthropic.Pricing == nil {
+		c.configuration.Anthropic.Pricing = map[string]PriceConfiguration{}
+	}
+
+	for model, price := range DefaultPricing {
+		if _, ok := c.configuration.Anthropic.Pricing[model]; !ok {
+			c.configuration.Anthropic.Pricing[model] = price
+		}
+	}
+}
+
 // GetConfig returns the current configuration.
 func (c *Config) GetConfig() Configuration {
 	return c.configuration
@@ -222,8 +561,21 @@ func (c *Config) createDirs() error {
 }
 
 func (c *Config) validate() error {
-	if c.configuration.Anthropic.APIKey == "" {
-		return ErrMissingAPIKey
+	switch c.configuration.LLM.Provider {
+	case "", ProviderAnthropic:
+		if c.configuration.Anthropic.APIKey == "" {
+			return ErrMissingAPIKey
+		}
+	case ProviderOpenAI:
+		if err := validateOpenAI(c.configuration.LLM.OpenAI); err != nil {
+			return err
+		}
+	case ProviderReplay:
+		if c.configuration.LLM.Replay.Path == "" {
+			return ErrInvalidReplay
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidProvider, c.configuration.LLM.Provider)
 	}
 
 	if c.configuration.Anthropic.Temperature < 0 || c.configuration.Anthropic.Temperature > 1 {
@@ -245,6 +597,10 @@ func (c *Config) validate() error {
 		return ErrInvalidLogLevel
 	}
 
+	if c.configuration.Audit.Enabled && c.configuration.Audit.Path == "" {
+		return ErrInvalidAudit
+	}
+
 	if c.configuration.Tools.Exec.Shell == "" {
 		return ErrInvalidShell
 	} else {
@@ -253,6 +609,173 @@ func (c *Config) validate() error {
 		}
 	}
 
+	policy := c.configuration.Tools.Exec.Policy
+	for _, action := range []string{policy.ReadOnly, policy.Mutating, policy.Destructive} {
+		if action != "" && !slices.Contains(PolicyActions, action) {
+			return fmt.Errorf("%w: %q", ErrInvalidPolicyAction, action)
+		}
+	}
+
+	if err := ValidatePolicyRules(policy.Rules); err != nil {
+		return err
+	}
+
+	retry := c.configuration.Anthropic.Retry
+	switch {
+	case retry.MaxAttempts < 1:
+		return fmt.Errorf("%w: max attempts must be greater than 0", ErrInvalidRetry)
+	case retry.BaseDelay < 0 || retry.MaxDelay < retry.BaseDelay:
+		return fmt.Errorf("%w: delays must satisfy 0 <= base delay <= max delay", ErrInvalidRetry)
+	case retry.Jitter < 0 || retry.Jitter > 1:
+		return fmt.Errorf("%w: jitter must be between 0 and 1", ErrInvalidRetry)
+	}
+
+	if output := c.configuration.Tools.Exec.Output; output.MaxBytes < 0 || output.MaxLines < 0 {
+		return ErrInvalidOutputLimits
+	}
+
+	if parallel := c.configuration.Tools.Parallel; parallel.Enabled && parallel.MaxConcurrency < 1 {
+		return ErrInvalidParallel
+	}
+
+	if err := ValidateRedactionPatterns(c.configuration.Tools.Exec.Redaction.Patterns); err != nil {
+		return err
+	}
+
+	if err := validateBackend(c.configuration.Tools.Exec); err != nil {
+		return err
+	}
+
+	if err := validateHosts(c.configuration.Tools.Exec.Hosts); err != nil {
+		return err
+	}
+
+	for name, tool := range c.configuration.Tools.Overrides {
+		if err := ValidateEnv(tool.Env); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	for model, price := range c.configuration.Anthropic.Pricing {
+		if price.Input < 0 || price.Output < 0 || price.CacheWrite < 0 || price.CacheRead < 0 {
+			return fmt.Errorf("%w: %s", ErrInvalidPricing, model)
+		}
+	}
+
+	if budget := c.configuration.Anthropic.Budget; budget.MaxIterations < 0 || budget.MaxTotalTokens < 0 || budget.MaxCost < 0 {
+		return ErrInvalidBudget
+	}
+
+	return nil
+}
+
+// validateOpenAI validates the configuration of the OpenAI-compatible API.
+func validateOpenAI(cfg OpenAIConfiguration) error {
+	if u, err := url.Parse(cfg.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%w: invalid base url %q", ErrInvalidOpenAI, cfg.BaseURL)
+	}
+
+	switch {
+	case cfg.Model == "":
+		return fmt.Errorf("%w: model is required", ErrInvalidOpenAI)
+	case cfg.MaxTokens < 1:
+		return fmt.Errorf("%w: max tokens must be greater than 0", ErrInvalidOpenAI)
+	case cfg.Temperature < 0 || cfg.Temperature > 2:
+		return fmt.Errorf("%w: temperature must be between 0 and 2", ErrInvalidOpenAI)
+	}
+
+	return nil
+}
+
+// ValidatePolicyRules validates the policy rules.
+func ValidatePolicyRules(rules []PolicyRule) error {
+	for _, rule := range rules {
+		if !slices.Contains(PolicyActions, rule.Action) {
+			return fmt.Errorf("%w: %q", ErrInvalidPolicyAction, rule.Action)
+		}
+
+		if rule.Match == "" {
+			return fmt.Errorf("%w: empty match", ErrInvalidPolicyRule)
+		}
+
+		if _, err := regexp.Compile(rule.Match); err != nil {
+			return fmt.Errorf("%w: %q: %v", ErrInvalidPolicyRule, rule.Match, err)
+		}
+	}
+
+	return nil
+}
+
+// validateBackend validates the backend of the exec tool.
+func validateBackend(cfg ExecToolConfiguration) error {
+	switch cfg.Backend {
+	case "", BackendHost:
+	case BackendContainer:
+		switch {
+		case cfg.Container.Engine == "":
+			return fmt.Errorf("%w: engine is required", ErrInvalidContainer)
+		case cfg.Container.Image == "":
+			return fmt.Errorf("%w: image is required", ErrInvalidContainer)
+		case cfg.Container.Shell == "":
+			return fmt.Errorf("%w: shell is required", ErrInvalidContainer)
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidBackend, cfg.Backend)
+	}
+
+	return nil
+}
+
+// validateHosts validates the remote hosts of the exec tool.
+func validateHosts(hosts map[string]HostConfiguration) error {
+	for name, host := range hosts {
+		switch {
+		case host.Host == "":
+			return fmt.Errorf("%w: %s: host is required", ErrInvalidHost, name)
+		case host.Port < 0 || host.Port > 65535:
+			return fmt.Errorf("%w: %s: invalid port %d", ErrInvalidHost, name, host.Port)
+		case slices.Contains(host.Jump, name):
+			return fmt.Errorf("%w: %s: host cannot jump through itself", ErrInvalidHost, name)
+		}
+	}
+
+	return nil
+}
+
+// ValidateRedactionPatterns validates the user-defined redaction patterns.
+func ValidateRedactionPatterns(patterns []RedactionPattern) error {
+	for _, pattern := range patterns {
+		if pattern.Match == "" {
+			return fmt.Errorf("%w: empty match", ErrInvalidRedactionPattern)
+		}
+
+		if _, err := regexp.Compile(pattern.Match); err != nil {
+			return fmt.Errorf("%w: %q: %v", ErrInvalidRedactionPattern, pattern.Match, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateEnv validates the environment variables of a tool: each one needs a valid name and at most one source of
+// its value.
+func ValidateEnv(vars []EnvVar) error {
+	for _, v := range vars {
+		if v.Name == "" || strings.ContainsAny(v.Name, "= \t\n") {
+			return fmt.Errorf("%w: invalid name %q", ErrInvalidEnv, v.Name)
+		}
+
+		sources := 0
+		for _, source := range []string{v.Value, v.FromEnv, v.Command} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources > 1 {
+			return fmt.Errorf("%w: %s: only one of value, from_env and command can be set", ErrInvalidEnv, v.Name)
+		}
+	}
+
 	return nil
 }
 
@@ -260,10 +783,46 @@ func (c *Config) setDefaults() {
 	viper.SetDefault("ui.theme", "default")
 	viper.SetDefault("logging.path", filepath.Join(c.homePath, dirConfig, "log.log"))
 	viper.SetDefault("logging.level", "info")
+	viper.SetDefault("sessions.enabled", true)
+	viper.SetDefault("sessions.path", filepath.Join(c.homePath, dirSessions))
+	viper.SetDefault("audit.enabled", true)
+	viper.SetDefault("audit.path", filepath.Join(c.homePath, dirConfig, "audit.jsonl"))
+	viper.SetDefault("audit.hash_chain", true)
+	viper.SetDefault("llm.provider", ProviderAnthropic)
+	viper.SetDefault("llm.openai.base_url", "https://api.openai.com/v1")
+	viper.SetDefault("llm.openai.model", "gpt-4o")
+	viper.SetDefault("llm.openai.temperature", 0.7)
+	viper.SetDefault("llm.openai.max_tokens", 1024)
 	viper.SetDefault("anthropic.model", "claude-3-7-sonnet-latest")
 	viper.SetDefault("anthropic.temperature", 0.7)
 	viper.SetDefault("anthropic.max_tokens", 1024)
+	viper.SetDefault("anthropic.retry.max_attempts", 5)
+	viper.SetDefault("anthropic.retry.base_delay", 1.0)
+	viper.SetDefault("anthropic.retry.max_delay", 60.0)
+	viper.SetDefault("anthropic.retry.jitter", 0.2)
+	viper.SetDefault("anthropic.budget.max_iterations", 50)
+	viper.SetDefault("anthropic.budget.max_total_tokens", 0)
+	viper.SetDefault("anthropic.budget.max_cost", 0)
 	viper.SetDefault("tools.timeout", 120)
+	viper.SetDefault("tools.parallel.enabled", false)
+	viper.SetDefault("tools.parallel.max_concurrency", 4)
 	viper.SetDefault("tools.exec.timeout", 0)
 	viper.SetDefault("tools.exec.shell", "/bin/sh")
+	viper.SetDefault("tools.exec.approval", false)
+	viper.SetDefault("tools.exec.policy.read_only", "allow")
+	viper.SetDefault("tools.exec.policy.mutating", "allow")
+	viper.SetDefault("tools.exec.policy.destructive", "ask")
+	viper.SetDefault("tools.exec.dry_run.enabled", false)
+	viper.SetDefault("tools.exec.dry_run.run_read_only", false)
+	viper.SetDefault("tools.exec.output.max_bytes", 32768)
+	viper.SetDefault("tools.exec.output.max_lines", 1000)
+	viper.SetDefault("tools.exec.output.spill", false)
+	viper.SetDefault("tools.exec.output.path", filepath.Join(c.homePath, dirCache))
+	viper.SetDefault("tools.exec.redaction.enabled", true)
+	viper.SetDefault("tools.exec.sandbox.enabled", false)
+	viper.SetDefault("tools.exec.sandbox.isolate_network", false)
+	viper.SetDefault("tools.exec.backend", BackendHost)
+	viper.SetDefault("tools.exec.container.engine", "docker")
+	viper.SetDefault("tools.exec.container.shell", "/bin/sh")
+	viper.SetDefault("tools.exec.container.mounts", []string{filepath.Join(c.homePath, ".kube"), filepath.Join(c.homePath, ".aws")})
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -93,6 +94,7 @@ func TestNewConfigManager(t *testing.T) {
 		assert.Equal(t, int64(120), viper.GetInt64("tools.timeout"))
 		assert.Equal(t, int64(0), viper.GetInt64("tools.exec.timeout"))
 		assert.Equal(t, "/bin/sh", viper.GetString("tools.exec.shell"))
+		assert.False(t, viper.GetBool("tools.exec.approval"))
 	})
 
 	t.Run("binds environment variables", func(t *testing.T) {
@@ -131,12 +133,47 @@ func TestLoadConfig_DefaultValues(t *testing.T) {
 
 	config := manager.GetConfig()
 	assert.Equal(t, "info", config.Logging.Level)
+	assert.True(t, config.Sessions.Enabled)
+	assert.True(t, strings.HasSuffix(config.Sessions.Path, filepath.Join(".opsy", "sessions")))
+	assert.True(t, config.Audit.Enabled)
+	assert.True(t, config.Audit.HashChain)
+	assert.True(t, strings.HasSuffix(config.Audit.Path, filepath.Join(".opsy", "audit.jsonl")))
 	assert.Equal(t, "claude-3-7-sonnet-latest", config.Anthropic.Model)
 	assert.Equal(t, 0.7, config.Anthropic.Temperature)
 	assert.Equal(t, int64(1024), config.Anthropic.MaxTokens)
+	assert.Equal(t, RetryConfiguration{MaxAttempts: 5, BaseDelay: 1, MaxDelay: 60, Jitter: 0.2}, config.Anthropic.Retry)
+	assert.Equal(t, DefaultPricing, config.Anthropic.Pricing)
+	assert.Equal(t, BudgetConfiguration{MaxIterations: 50}, config.Anthropic.Budget)
+	assert.Equal(t, ProviderAnthropic, config.LLM.Provider)
+	assert.Equal(t, OpenAIConfiguration{BaseURL: "https://api.openai.com/v1", Model: "gpt-4o", Temperature: 0.7, MaxTokens: 1024},
+		config.LLM.OpenAI)
 	assert.Equal(t, int64(120), config.Tools.Timeout)
+	assert.Equal(t, ParallelConfiguration{MaxConcurrency: 4}, config.Tools.Parallel)
+	assert.Empty(t, config.Tools.Overrides)
 	assert.Equal(t, int64(0), config.Tools.Exec.Timeout)
 	assert.Equal(t, "/bin/sh", config.Tools.Exec.Shell)
+	assert.False(t, config.Tools.Exec.Approval)
+	assert.Equal(t, "allow", config.Tools.Exec.Policy.ReadOnly)
+	assert.Equal(t, "allow", config.Tools.Exec.Policy.Mutating)
+	assert.Equal(t, "ask", config.Tools.Exec.Policy.Destructive)
+	assert.Empty(t, config.Tools.Exec.Policy.Rules)
+	assert.False(t, config.Tools.Exec.DryRun.Enabled)
+	assert.False(t, config.Tools.Exec.DryRun.RunReadOnly)
+	assert.Equal(t, 32768, config.Tools.Exec.Output.MaxBytes)
+	assert.Equal(t, 1000, config.Tools.Exec.Output.MaxLines)
+	assert.False(t, config.Tools.Exec.Output.Spill)
+	assert.True(t, strings.HasSuffix(config.Tools.Exec.Output.Path, filepath.Join(".opsy", "cache")))
+	assert.True(t, config.Tools.Exec.Redaction.Enabled)
+	assert.Empty(t, config.Tools.Exec.Redaction.Patterns)
+	assert.Equal(t, SandboxConfiguration{}, config.Tools.Exec.Sandbox)
+	assert.Equal(t, BackendHost, config.Tools.Exec.Backend)
+	assert.Equal(t, "docker", config.Tools.Exec.Container.Engine)
+	assert.Empty(t, config.Tools.Exec.Container.Image)
+	assert.Equal(t, "/bin/sh", config.Tools.Exec.Container.Shell)
+	assert.Len(t, config.Tools.Exec.Container.Mounts, 2)
+	assert.True(t, strings.HasSuffix(config.Tools.Exec.Container.Mounts[0], ".kube"))
+	assert.True(t, strings.HasSuffix(config.Tools.Exec.Container.Mounts[1], ".aws"))
+	assert.Empty(t, config.Tools.Exec.Hosts)
 }
 
 // TestLoadConfig_CustomValues verifies custom configuration loading:
@@ -163,13 +200,69 @@ func TestLoadConfig_CustomValues(t *testing.T) {
 	config := manager.GetConfig()
 	assert.Equal(t, "debug", config.Logging.Level)
 	assert.Equal(t, "/custom/log/path", config.Logging.Path)
+	assert.Equal(t, SessionsConfiguration{Enabled: false, Path: "/custom/sessions"}, config.Sessions)
+	assert.Equal(t, AuditConfiguration{Enabled: true, Path: "/custom/audit.jsonl", HashChain: false}, config.Audit)
 	assert.Equal(t, "claude-3-opus", config.Anthropic.Model)
 	assert.Equal(t, 0.7, config.Anthropic.Temperature)
 	assert.Equal(t, int64(2048), config.Anthropic.MaxTokens)
+	assert.Equal(t, RetryConfiguration{MaxAttempts: 3, BaseDelay: 0.5, MaxDelay: 10, Jitter: 0}, config.Anthropic.Retry)
+	assert.Equal(t, PriceConfiguration{Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.5}, config.Anthropic.Pricing["claude-3-opus"])
+	assert.Equal(t, DefaultPricing["claude-3-7-sonnet-latest"], config.Anthropic.Pricing["claude-3-7-sonnet-latest"])
+	assert.Equal(t, BudgetConfiguration{MaxIterations: 20, MaxTotalTokens: 500000, MaxCost: 2.5}, config.Anthropic.Budget)
+	assert.Equal(t, LLMConfiguration{
+		Provider: ProviderAnthropic,
+		OpenAI: OpenAIConfiguration{
+			BaseURL:     "http://localhost:11434/v1",
+			APIKey:      "openai-key",
+			Model:       "llama3.1",
+			Temperature: 0.2,
+			MaxTokens:   4096,
+		},
+		Replay: ReplayConfiguration{Path: "/custom/transcript.jsonl"},
+		Record: "/custom/recorded.jsonl",
+	}, config.LLM)
 	assert.Equal(t, "custom_theme", config.UI.Theme)
 	assert.Equal(t, int64(180), config.Tools.Timeout)
+	assert.Equal(t, ParallelConfiguration{Enabled: true, MaxConcurrency: 6}, config.Tools.Parallel)
+	assert.Equal(t, map[string]ToolConfiguration{
+		"kubectl": {Env: []EnvVar{
+			{Name: "KUBECONFIG", Value: "~/.kube/production"},
+			{Name: "HTTPS_PROXY", FromEnv: "CORPORATE_PROXY"},
+		}},
+		"jira": {Env: []EnvVar{{Name: "JIRA_API_TOKEN", Command: "pass show jira/token"}}},
+	}, config.Tools.Overrides)
 	assert.Equal(t, int64(90), config.Tools.Exec.Timeout)
 	assert.Equal(t, "/bin/sh", config.Tools.Exec.Shell)
+	assert.True(t, config.Tools.Exec.Approval)
+	assert.Equal(t, "ask", config.Tools.Exec.Policy.Mutating)
+	assert.Equal(t, "deny", config.Tools.Exec.Policy.Destructive)
+	assert.Equal(t, []PolicyRule{{Match: "^kubectl .*--context[ =]prod", Action: "deny", Reason: "production is read-only"}}, config.Tools.Exec.Policy.Rules)
+	assert.True(t, config.Tools.Exec.DryRun.Enabled)
+	assert.True(t, config.Tools.Exec.DryRun.RunReadOnly)
+	assert.Equal(t, OutputConfiguration{MaxBytes: 1024, MaxLines: 50, Spill: true, Path: "/custom/cache/path"}, config.Tools.Exec.Output)
+	assert.Equal(t, RedactionConfiguration{
+		Enabled:  true,
+		Patterns: []RedactionPattern{{Name: "internal-token", Match: "itk_[a-z0-9]{32}"}, {Match: "password=(\\S+)"}},
+	}, config.Tools.Exec.Redaction)
+	assert.Equal(t, SandboxConfiguration{
+		Enabled:        true,
+		IsolateNetwork: true,
+		Writable:       []string{"/custom/writable"},
+		Env:            []string{"KUBECONFIG"},
+	}, config.Tools.Exec.Sandbox)
+	assert.Equal(t, BackendContainer, config.Tools.Exec.Backend)
+	assert.Equal(t, ContainerConfiguration{
+		Engine: "podman",
+		Image:  "ghcr.io/example/toolbox:1.0",
+		Shell:  "/bin/bash",
+		Mounts: []string{"/custom/.kube"},
+		Env:    []string{"AWS_PROFILE"},
+		Args:   []string{"--network=host"},
+	}, config.Tools.Exec.Container)
+	assert.Equal(t, map[string]HostConfiguration{
+		"bastion": {Host: "bastion.example.com", User: "ops", Port: 2222, Key: "/custom/.ssh/id_ed25519"},
+		"db-1":    {Host: "10.0.0.5", User: "postgres", Jump: []string{"bastion"}},
+	}, config.Tools.Exec.Hosts)
 }
 
 // TestLoadConfig_ValidationErrors verifies configuration validation:
@@ -219,6 +312,34 @@ logging:
   level: info`),
 			expectedErr: "anthropic max tokens must be greater than 0",
 		},
+		{
+			name: "invalid retry max attempts",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+  retry:
+    max_attempts: 0`),
+			expectedErr: "invalid anthropic retry policy",
+		},
+		{
+			name: "invalid retry delays",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+  retry:
+    base_delay: 10
+    max_delay: 5`),
+			expectedErr: "invalid anthropic retry policy",
+		},
+		{
+			name: "invalid retry jitter",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+  retry:
+    jitter: 1.5`),
+			expectedErr: "invalid anthropic retry policy",
+		},
 		{
 			name: "invalid log level",
 			configData: []byte(`
@@ -248,6 +369,192 @@ tools:
     shell: "/nonexistent/shell"`),
 			expectedErr: "invalid exec shell",
 		},
+		{
+			name: "invalid policy action",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+tools:
+  exec:
+    policy:
+      destructive: maybe`),
+			expectedErr: "invalid exec policy action",
+		},
+		{
+			name: "invalid policy rule action",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+tools:
+  exec:
+    policy:
+      rules:
+        - match: "^rm "
+          action: block`),
+			expectedErr: "invalid exec policy action",
+		},
+		{
+			name: "invalid policy rule match",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+tools:
+  exec:
+    policy:
+      rules:
+        - match: "("
+          action: deny`),
+			expectedErr: "invalid exec policy rule",
+		},
+		{
+			name: "empty policy rule match",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+tools:
+  exec:
+    policy:
+      rules:
+        - action: deny`),
+			expectedErr: "invalid exec policy rule",
+		},
+		{
+			name: "negative output limits",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+tools:
+  exec:
+    output:
+      max_lines: -1`),
+			expectedErr: "exec output limits must not be negative",
+		},
+		{
+			name: "invalid exec backend",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+tools:
+  exec:
+    backend: vm`),
+			expectedErr: "invalid exec backend",
+		},
+		{
+			name: "container backend without image",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+tools:
+  exec:
+    backend: container`),
+			expectedErr: "invalid exec container configuration: image is required",
+		},
+		{
+			name: "exec host without host",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+tools:
+  exec:
+    hosts:
+      db-1:
+        user: postgres`),
+			expectedErr: "invalid exec host: db-1: host is required",
+		},
+		{
+			name: "invalid redaction pattern",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+tools:
+  exec:
+    redaction:
+      patterns:
+        - match: "("`),
+			expectedErr: "invalid exec redaction pattern",
+		},
+		{
+			name: "empty redaction pattern",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+tools:
+  exec:
+    redaction:
+      patterns:
+        - name: empty`),
+			expectedErr: "invalid exec redaction pattern",
+		},
+		{
+			name: "negative price",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+  pricing:
+    claude-3-opus:
+      input: -1`),
+			expectedErr: "anthropic prices must not be negative",
+		},
+		{
+			name: "negative budget",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+  budget:
+    max_cost: -1`),
+			expectedErr: "anthropic budget limits must not be negative",
+		},
+		{
+			name: "invalid parallel max concurrency",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+tools:
+  parallel:
+    enabled: true
+    max_concurrency: 0`),
+			expectedErr: "tools parallel max concurrency must be greater than 0",
+		},
+		{
+			name: "invalid tool env",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+tools:
+  jira:
+    env:
+      - name: JIRA_API_TOKEN
+        value: token
+        command: pass show jira/token`),
+			expectedErr: "jira: invalid tool env: JIRA_API_TOKEN: only one of value, from_env and command can be set",
+		},
+		{
+			name: "invalid provider",
+			configData: []byte(`
+anthropic:
+  api_key: test-key
+llm:
+  provider: unknown`),
+			expectedErr: "invalid llm provider",
+		},
+		{
+			name: "invalid openai base url",
+			configData: []byte(`
+llm:
+  provider: openai
+  openai:
+    base_url: localhost`),
+			expectedErr: "invalid llm openai configuration",
+		},
+		{
+			name: "invalid openai temperature",
+			configData: []byte(`
+llm:
+  provider: openai
+  openai:
+    temperature: 2.5`),
+			expectedErr: "invalid llm openai configuration",
+		},
 	}
 
 	for _, tt := range tests {
@@ -460,6 +767,12 @@ func TestValidate(t *testing.T) {
 						Model:       "test-model",
 						Temperature: 0.5,
 						MaxTokens:   100,
+						Retry: RetryConfiguration{
+							MaxAttempts: 5,
+							BaseDelay:   1,
+							MaxDelay:    60,
+							Jitter:      0.2,
+						},
 					},
 					Tools: ToolsConfiguration{
 						Timeout: 120,
@@ -472,6 +785,57 @@ func TestValidate(t *testing.T) {
 			},
 			expectedErr: nil,
 		},
+		{
+			name: "openai provider without anthropic API key",
+			config: Config{
+				configuration: Configuration{
+					Logging: LoggingConfiguration{
+						Level: "info",
+					},
+					LLM: LLMConfiguration{
+						Provider: ProviderOpenAI,
+						OpenAI: OpenAIConfiguration{
+							BaseURL:     "http://localhost:8000/v1",
+							Model:       "qwen2.5-coder",
+							Temperature: 0.7,
+							MaxTokens:   1024,
+						},
+					},
+					Anthropic: AnthropicConfiguration{
+						Temperature: 0.5,
+						MaxTokens:   100,
+						Retry:       RetryConfiguration{MaxAttempts: 1},
+					},
+					Tools: ToolsConfiguration{
+						Exec: ExecToolConfiguration{
+							Shell: availableShell,
+						},
+					},
+				},
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "openai provider without model",
+			config: Config{
+				configuration: Configuration{
+					LLM: LLMConfiguration{
+						Provider: ProviderOpenAI,
+						OpenAI:   OpenAIConfiguration{BaseURL: "http://localhost:8000/v1", MaxTokens: 1024},
+					},
+				},
+			},
+			expectedErr: ErrInvalidOpenAI,
+		},
+		{
+			name: "replay provider without path",
+			config: Config{
+				configuration: Configuration{
+					LLM: LLMConfiguration{Provider: ProviderReplay},
+				},
+			},
+			expectedErr: ErrInvalidReplay,
+		},
 		{
 			name: "invalid log level",
 			config: Config{
@@ -493,6 +857,30 @@ func TestValidate(t *testing.T) {
 			},
 			expectedErr: ErrInvalidLogLevel,
 		},
+		{
+			name: "audit log without path",
+			config: Config{
+				configuration: Configuration{
+					Logging: LoggingConfiguration{
+						Level: "info",
+					},
+					Audit: AuditConfiguration{
+						Enabled: true,
+					},
+					Anthropic: AnthropicConfiguration{
+						APIKey:      "test-key",
+						Temperature: 0.5,
+						MaxTokens:   100,
+					},
+					Tools: ToolsConfiguration{
+						Exec: ExecToolConfiguration{
+							Shell: availableShell,
+						},
+					},
+				},
+			},
+			expectedErr: ErrInvalidAudit,
+		},
 		{
 			name: "temperature too high",
 			config: Config{
@@ -13,6 +13,9 @@
 //	Configuration {
 //	  UI:        UIConfiguration        // UI theme and styling
 //	  Logging:   LoggingConfiguration   // Log file path and level
+//	  Sessions:  SessionsConfiguration  // Persisted sessions for resuming interrupted tasks
+//	  Audit:     AuditConfiguration     // Audit log of the executed commands
+//	  LLM:       LLMConfiguration       // LLM provider selection and OpenAI-compatible API settings
 //	  Anthropic: AnthropicConfiguration // API settings for Anthropic
 //	  Tools:     ToolsConfiguration     // Global tool settings and exec configuration
 //	}
@@ -25,23 +28,140 @@
 //	}
 //	config := manager.GetConfig()
 //
+// Pricing:
+//
+// The price of each model (anthropic.pricing) is given in USD per million input,
+// output, cache write and cache read tokens, and is used to estimate the cost of
+// the tasks. DefaultPricing provides the price of the common models; models missing
+// from the configured pricing get their default price.
+//
+// LLM Provider:
+//
+// The llm.provider setting selects the API used by the agent: anthropic (default),
+// openai or replay. The openai provider talks to any OpenAI-compatible chat
+// completions API, such as OpenAI, Ollama or vLLM, at llm.openai.base_url. The
+// replay provider plays back the transcript at llm.replay.path, and llm.record
+// records the responses of any provider to a transcript. The retry, budget and
+// pricing settings under anthropic apply to all the providers.
+//
+// Sessions:
+//
+// Unless sessions.enabled is false, the conversation, commands and status of each
+// run are persisted under sessions.path (~/.opsy/sessions by default), so that a
+// task interrupted by Ctrl+C, a crash or an API error can be resumed.
+//
+// Audit Log:
+//
+// Unless audit.enabled is false, every executed command is appended to the JSONL
+// audit log at audit.path (~/.opsy/audit.jsonl by default). With audit.hash_chain,
+// each entry includes the hash of the previous one, so that tampering can be detected.
+//
+// Parallel Tools:
+//
+// With tools.parallel.enabled, the model may call several tools in a single
+// response and they are executed concurrently, at most
+// tools.parallel.max_concurrency (4 by default) at a time.
+//
+// Redaction:
+//
+// Unless tools.exec.redaction.enabled is false, secrets such as AWS keys, JWTs,
+// PEM blocks, Kubernetes secret data and GitHub tokens are redacted from the output
+// of commands before it is logged, shown or sent to the model. The regular
+// expressions of tools.exec.redaction.patterns are redacted as well; a pattern with
+// capture groups only redacts its first group.
+//
+// Sandbox:
+//
+// With tools.exec.sandbox.enabled, commands are executed in a bubblewrap sandbox
+// with a read-only root filesystem, where only the working directory and the
+// tools.exec.sandbox.writable paths are writable. Only basic environment variables
+// and those named in tools.exec.sandbox.env are passed to the commands, and
+// tools.exec.sandbox.isolate_network removes network access. Tools can replace the
+// sandbox in their definitions.
+//
+// Exec Backend:
+//
+// The tools.exec.backend setting selects where commands are executed: host
+// (default) runs them with the shell of the host, in the sandbox if enabled, and
+// container runs each command in a new container of tools.exec.container.image
+// through the tools.exec.container.engine CLI (docker by default). The working
+// directory is mounted into the container, and the tools.exec.container.mounts
+// (~/.kube and ~/.aws by default) are mounted read-only.
+//
+// Remote Hosts:
+//
+// The tools.exec.hosts setting names the remote hosts the agent can execute
+// commands on over SSH, each with its host, user, port, private key and jump
+// hosts. Jump hosts may be names of other hosts.
+//
+// Tool Environment:
+//
+// The tools.<name>.env setting sets environment variables of the commands of the
+// named tool, in addition to and replacing the env of its definition. Each variable
+// has a name and a literal value, the name of a variable of the host environment
+// (from_env), or a command printing the value (command), such as a secret manager:
+//
+//	tools:
+//	  jira:
+//	    env:
+//	      - name: JIRA_API_TOKEN
+//	        command: pass show jira/token
+//
 // Environment Variables:
 //   - ANTHROPIC_API_KEY: API key for Anthropic
+//   - OPENAI_API_KEY: API key for the OpenAI-compatible API
+//   - OPSY_LLM_PROVIDER: LLM provider (anthropic, openai, replay)
+//   - OPSY_LLM_OPENAI_BASE_URL: Base URL of the OpenAI-compatible API
+//   - OPSY_LLM_OPENAI_MODEL: Model name of the OpenAI-compatible API
+//   - OPSY_LLM_OPENAI_TEMPERATURE: Temperature value of the OpenAI-compatible API
+//   - OPSY_LLM_OPENAI_MAX_TOKENS: Maximum tokens for completion of the OpenAI-compatible API
+//   - OPSY_LLM_REPLAY_PATH: Path to the transcript replayed by the replay provider
+//   - OPSY_LLM_RECORD: Path to the transcript the responses are recorded to
 //   - OPSY_UI_THEME: UI theme name
 //   - OPSY_LOGGING_LEVEL: Log level (debug, info, warn, error)
+//   - OPSY_SESSIONS_ENABLED: Whether the sessions are persisted
+//   - OPSY_SESSIONS_PATH: Directory the sessions are persisted to
+//   - OPSY_AUDIT_ENABLED: Whether the executed commands are recorded in the audit log
+//   - OPSY_AUDIT_PATH: Path to the audit log
+//   - OPSY_AUDIT_HASH_CHAIN: Whether the entries of the audit log are hash-chained
 //   - OPSY_ANTHROPIC_MODEL: Model name
 //   - OPSY_ANTHROPIC_TEMPERATURE: Temperature value
 //   - OPSY_ANTHROPIC_MAX_TOKENS: Maximum tokens for completion
+//   - OPSY_ANTHROPIC_RETRY_MAX_ATTEMPTS: Maximum attempts for a request to the Anthropic API
+//   - OPSY_ANTHROPIC_RETRY_BASE_DELAY: Delay in seconds before the first retry
+//   - OPSY_ANTHROPIC_RETRY_MAX_DELAY: Maximum delay in seconds between retries
+//   - OPSY_ANTHROPIC_RETRY_JITTER: Fraction of the delay randomly added or removed
+//   - OPSY_ANTHROPIC_BUDGET_MAX_ITERATIONS: Maximum requests in a single run of the agent or a tool
+//   - OPSY_ANTHROPIC_BUDGET_MAX_TOTAL_TOKENS: Maximum input and output tokens used by a task
+//   - OPSY_ANTHROPIC_BUDGET_MAX_COST: Maximum estimated cost of a task in USD
 //   - OPSY_TOOLS_TIMEOUT: Global timeout for tools in seconds
 //   - OPSY_TOOLS_EXEC_TIMEOUT: Timeout for exec tool in seconds
 //   - OPSY_TOOLS_EXEC_SHELL: Shell to use for command execution
+//   - OPSY_TOOLS_EXEC_APPROVAL: Whether commands must be approved before execution
+//   - OPSY_TOOLS_EXEC_POLICY_READ_ONLY: Action for read-only commands (allow, deny, ask)
+//   - OPSY_TOOLS_EXEC_POLICY_MUTATING: Action for mutating commands (allow, deny, ask)
+//   - OPSY_TOOLS_EXEC_POLICY_DESTRUCTIVE: Action for destructive commands (allow, deny, ask)
+//   - OPSY_TOOLS_EXEC_DRY_RUN_ENABLED: Whether commands are recorded instead of executed
+//   - OPSY_TOOLS_EXEC_DRY_RUN_RUN_READ_ONLY: Whether read-only commands still run in dry-run mode
+//   - OPSY_TOOLS_EXEC_OUTPUT_MAX_BYTES: Maximum bytes of command output returned to the agent
+//   - OPSY_TOOLS_EXEC_OUTPUT_MAX_LINES: Maximum lines of command output returned to the agent
+//   - OPSY_TOOLS_EXEC_OUTPUT_SPILL: Whether the full output of truncated commands is saved to a file
+//   - OPSY_TOOLS_EXEC_OUTPUT_PATH: Directory the full output of truncated commands is saved to
+//   - OPSY_TOOLS_EXEC_REDACTION_ENABLED: Whether secrets are redacted from the output of commands
+//   - OPSY_TOOLS_EXEC_SANDBOX_ENABLED: Whether commands are executed in a bubblewrap sandbox
+//   - OPSY_TOOLS_EXEC_SANDBOX_ISOLATE_NETWORK: Whether sandboxed commands are executed without network access
+//   - OPSY_TOOLS_EXEC_BACKEND: Backend the commands are executed with (host, container)
+//   - OPSY_TOOLS_EXEC_CONTAINER_ENGINE: Container engine CLI of the container backend
+//   - OPSY_TOOLS_EXEC_CONTAINER_IMAGE: Image of the container the commands are executed in
 //
 // Directory Structure:
 //
 //	~/.opsy/
 //	├── config.yaml  // Configuration file
 //	├── log.log     // Default log file
+//	├── audit.jsonl // Default audit log of the executed commands
 //	├── cache/      // Cache directory for temporary files
+//	├── sessions/   // Persisted sessions, one directory per session
 //	└── tools/      // Tool-specific data and configurations
 //
 // The package uses the following error constants for error handling:
@@ -53,22 +173,41 @@
 //   - ErrUnmarshalConfig: Returned when config parsing fails
 //   - ErrValidateConfig: Returned when configuration validation fails
 //   - ErrMissingAPIKey: Returned when Anthropic API key is missing
+//   - ErrInvalidProvider: Returned when the LLM provider is not anthropic or openai
+//   - ErrInvalidOpenAI: Returned when the OpenAI-compatible API settings are invalid
+//   - ErrInvalidReplay: Returned when the replay provider has no transcript path
 //   - ErrInvalidTemp: Returned when temperature is not between 0 and 1
 //   - ErrInvalidMaxTokens: Returned when max tokens is not positive
+//   - ErrInvalidRetry: Returned when the retry policy is invalid
+//   - ErrInvalidPricing: Returned when a model price is negative
+//   - ErrInvalidBudget: Returned when a budget limit is negative
 //   - ErrInvalidLogLevel: Returned when log level is invalid
 //   - ErrInvalidTheme: Returned when UI theme is invalid
 //   - ErrInvalidShell: Returned when exec shell is invalid or not found
+//   - ErrInvalidPolicyAction: Returned when a policy action is not allow, deny or ask
+//   - ErrInvalidPolicyRule: Returned when a policy rule has no match or an invalid regular expression
+//   - ErrInvalidOutputLimits: Returned when an exec output limit is negative
+//   - ErrInvalidBackend: Returned when the exec backend is not host or container
+//   - ErrInvalidContainer: Returned when the container backend has no engine, image or shell
+//   - ErrInvalidHost: Returned when a remote host has no host, an invalid port or jumps through itself
+//   - ErrInvalidRedactionPattern: Returned when a redaction pattern has no match or an invalid regular expression
+//   - ErrInvalidEnv: Returned when a tool env variable has an invalid name or several values
 //   - ErrOpenLogFile: Returned when log file cannot be opened
 //
 // Validation:
 //
 // The package performs extensive validation of the configuration:
-//   - Anthropic API key must be provided
+//   - LLM provider must be anthropic, openai or replay
+//   - Anthropic API key must be provided when the anthropic provider is selected
+//   - OpenAI-compatible base URL must be an absolute URL and the model must be set
+//   - Replay transcript path must be set when the replay provider is selected
 //   - Temperature must be between 0 and 1
 //   - Max tokens must be positive
 //   - Log level must be one of: debug, info, warn, error
 //   - UI theme must be a valid theme name
 //   - Exec shell must be a valid and executable shell path
+//   - Redaction patterns must be valid regular expressions
+//   - Exec backend must be host or container, and the container backend needs an image
 //
 // Thread Safety:
 //
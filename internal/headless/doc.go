@@ -0,0 +1,40 @@
+/*
+Package headless provides the non-interactive mode of the opsy application, which runs a task without the terminal
+user interface so that Opsy can be called from cron jobs, CI pipelines and other scripts.
+
+# Printer
+
+The Printer consumes the communication channels of the agent and prints the progress of the task to stdout as it
+happens:
+
+	printer := headless.New(headless.WithFormat(headless.FormatJSON))
+	go printer.Run(ctx, communication)
+
+Available options include:
+  - WithWriter: Sets the writer the events are printed to (default: stdout)
+  - WithFormat: Sets the output format (default: FormatText)
+  - WithLogger: Sets the logger for the printer
+
+Streamed messages are printed once, with their final text. Since nobody can approve commands in headless mode,
+approval requests are rejected and reported; commands that must run unattended should be allowed by the
+tools.exec.policy configuration. Interactive commands are rejected and reported as well, since there is no terminal
+to hand over to the user.
+
+# Output Formats
+
+  - FormatText: Human-readable lines, e.g. "[Opsy] message", "$ command [directory] (exit 0)" and "  | output"
+  - FormatJSON: One JSON object per line, with a type of message, command, output, status, approval or interactive
+
+# Summary
+
+When the task finishes, PrintSummary prints the Summary of the task: its final status and error, the outputs of
+all the tools, all the executed commands and the token usage. In the JSON format, the summary is the last line,
+with the summary type. The command line exits with a non-zero status when the task finishes with an error.
+
+# Error Handling
+
+The package defines:
+
+  - ErrInvalidFormat: The output format is not text or json
+*/
+package headless
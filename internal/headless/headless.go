@@ -0,0 +1,320 @@
+package headless
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/datolabs-io/opsy/internal/agent"
+	"github.com/datolabs-io/opsy/internal/tool"
+)
+
+// Format is the output format of the headless mode.
+type Format string
+
+// EventType is the type of an event printed while the task runs.
+type EventType string
+
+const (
+	// ErrInvalidFormat is the error returned when the output format is not supported.
+	ErrInvalidFormat = "invalid output format"
+
+	// FormatText prints the events and the summary as human-readable text.
+	FormatText Format = "text"
+	// FormatJSON prints the events and the summary as JSON, one object per line.
+	FormatJSON Format = "json"
+
+	// EventMessage is the event of a message from the agent or a tool.
+	EventMessage EventType = "message"
+	// EventCommand is the event of a completed command.
+	EventCommand EventType = "command"
+	// EventOutput is the event of a line of output of a running command.
+	EventOutput EventType = "output"
+	// EventStatus is the event of a change of the status of the agent.
+	EventStatus EventType = "status"
+	// EventApproval is the event of a command rejected because it requires approval.
+	EventApproval EventType = "approval"
+	// EventInteractive is the event of an interactive command rejected because there is no terminal to hand over.
+	EventInteractive EventType = "interactive"
+	// EventSummary is the type of the summary printed when the task finishes.
+	EventSummary EventType = "summary"
+
+	// messageApprovalRejected is the message of a command rejected because it requires approval.
+	messageApprovalRejected = "rejected: commands cannot be approved in headless mode; allow them in tools.exec.policy"
+	// messageInteractiveRejected is the message of an interactive command rejected in headless mode.
+	messageInteractiveRejected = "rejected: interactive commands cannot be run in headless mode"
+)
+
+// Formats are the supported output formats.
+var Formats = []Format{FormatText, FormatJSON}
+
+// Event is an event printed while the task runs.
+type Event struct {
+	// Type is the type of the event.
+	Type EventType `json:"type"`
+	// Time is the time of the event.
+	Time time.Time `json:"time"`
+	// Tool is the name of the tool which sent the message.
+	Tool string `json:"tool,omitempty"`
+	// Message is the message of a message or approval event.
+	Message string `json:"message,omitempty"`
+	// Status is the status of a status event.
+	Status string `json:"status,omitempty"`
+	// Command is the command of a command, output or approval event.
+	Command *tool.Command `json:"command,omitempty"`
+	// Line is the line of an output event.
+	Line string `json:"line,omitempty"`
+	// Stream is the stream of the line of an output event.
+	Stream tool.Stream `json:"stream,omitempty"`
+}
+
+// Summary is the machine-readable result of the task, printed when it finishes.
+type Summary struct {
+	// Type is always EventSummary, so that the summary can be told apart from the events.
+	Type EventType `json:"type"`
+	// Task is the task that was run.
+	Task string `json:"task"`
+	// Status is the final status of the agent, either Finished or Error.
+	Status string `json:"status"`
+	// Error is the error the task failed with, if any.
+	Error string `json:"error,omitempty"`
+	// Outputs are the outputs of the tools executed by the agent.
+	Outputs []tool.Output `json:"outputs"`
+	// Commands are all the commands executed by the agent and its tools, in order.
+	Commands []tool.Command `json:"commands"`
+	// Usage is the token usage and estimated cost of the task.
+	Usage agent.UsageReport `json:"usage"`
+}
+
+// Printer prints the progress of a task without the TUI.
+type Printer struct {
+	writer   io.Writer
+	format   Format
+	logger   *slog.Logger
+	pending  []agent.Message
+	commands []tool.Command
+}
+
+// Option is a function that configures the Printer.
+type Option func(*Printer)
+
+// New creates a new Printer.
+func New(opts ...Option) *Printer {
+	p := &Printer{
+		writer:   os.Stdout,
+		format:   FormatText,
+		logger:   slog.New(slog.DiscardHandler),
+		commands: []tool.Command{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// WithWriter sets the writer the Printer prints to.
+func WithWriter(writer io.Writer) Option {
+	return func(p *Printer) {
+		p.writer = writer
+	}
+}
+
+// WithFormat sets the output format of the Printer.
+func WithFormat(format Format) Option {
+	return func(p *Printer) {
+		p.format = format
+	}
+}
+
+// WithLogger sets the logger for the Printer.
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Printer) {
+		p.logger = logger.With("component", "headless")
+	}
+}
+
+// ParseFormat returns the output format with the given name.
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == name {
+			return format, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s: %q", ErrInvalidFormat, name)
+}
+
+// Run prints the events received from the communication channels until the context is cancelled. Approval requests
+// and interactive commands are rejected, since there is nobody to approve or run them.
+func (p *Printer) Run(ctx context.Context, communication *agent.Communication) {
+	for {
+		select {
+		case <-ctx.Done():
+			p.flush()
+			return
+		case msg := <-communication.Messages:
+			p.hold(msg)
+		case cmd := <-communication.Commands:
+			p.flush()
+			p.commands = append(p.commands, cmd)
+			p.print(Event{Type: EventCommand, Time: cmd.CompletedAt, Command: &cmd})
+		case output := <-communication.Output:
+			if output.Started {
+				continue
+			}
+			p.flush()
+			// The command is not complete yet, so only the fields identifying it are printed:
+			cmd := tool.Command{ID: output.Command.ID, Command: output.Command.Command}
+			p.print(Event{Type: EventOutput, Time: time.Now(), Command: &cmd, Line: output.Line, Stream: output.Stream})
+		case status := <-communication.Status:
+			p.flush()
+			p.print(Event{Type: EventStatus, Time: time.Now(), Status: string(status)})
+		case request := <-communication.Approvals:
+			p.flush()
+			p.logger.With("command", request.Command.Command).Warn("Command requires approval, rejected in headless mode.")
+			p.print(Event{Type: EventApproval, Time: time.Now(), Command: &request.Command, Message: messageApprovalRejected})
+			request.Response <- tool.Approval{Decision: tool.DecisionRejected}
+		case request := <-communication.Terminal:
+			p.flush()
+			p.logger.With("command", request.Command.Command).Warn("Interactive command rejected in headless mode.")
+			p.print(Event{Type: EventInteractive, Time: time.Now(), Command: &request.Command,
+				Message: messageInteractiveRejected})
+			request.Response <- errors.New(messageInteractiveRejected)
+		case <-communication.Usage:
+		case <-communication.Tools:
+		}
+	}
+}
+
+// Commands returns the commands received by Run. It must not be called while Run is running.
+func (p *Printer) Commands() []tool.Command {
+	return p.commands
+}
+
+// PrintSummary prints the summary of the task.
+func (p *Printer) PrintSummary(summary Summary) {
+	summary.Type = EventSummary
+	if summary.Outputs == nil {
+		summary.Outputs = []tool.Output{}
+	}
+	if summary.Commands == nil {
+		summary.Commands = []tool.Command{}
+	}
+
+	if p.format == FormatJSON {
+		p.printJSON(summary)
+		return
+	}
+
+	fmt.Fprintln(p.writer)
+	fmt.Fprintf(p.writer, "Status: %s\n", summary.Status)
+	if summary.Error != "" {
+		fmt.Fprintf(p.writer, "Error: %s\n", summary.Error)
+	}
+	fmt.Fprintf(p.writer, "Commands: %d\n", len(summary.Commands))
+	for i, cmd := range summary.Commands {
+		fmt.Fprintf(p.writer, "%3d. %s\n", i+1, commandLine(cmd))
+	}
+	total := summary.Usage.Total
+	fmt.Fprintf(p.writer, "Tokens: %d in / %d out | Cost: $%.3f\n", total.TotalInputTokens(), total.OutputTokens, total.Cost)
+}
+
+// hold holds the message back until its last streamed version has been received, which is once the agent or tool
+// which sent it sends another message or any other event is received. The messages of tools running in parallel are
+// held back separately, so that their streamed versions are not interleaved; the messages started earlier are
+// printed first.
+func (p *Printer) hold(msg agent.Message) {
+	for i, pending := range p.pending {
+		if pending.Tool != msg.Tool {
+			continue
+		}
+		if pending.ID == msg.ID {
+			p.pending[i] = msg
+			return
+		}
+
+		earlier := p.pending[:i+1]
+		p.pending = append([]agent.Message{}, p.pending[i+1:]...)
+		for _, earlierMsg := range earlier {
+			p.printMessage(earlierMsg)
+		}
+		break
+	}
+
+	if msg.ID == "" {
+		p.flush()
+		p.printMessage(msg)
+		return
+	}
+	p.pending = append(p.pending, msg)
+}
+
+// flush prints the pending messages, in the order they started.
+func (p *Printer) flush() {
+	pending := p.pending
+	p.pending = nil
+	for _, msg := range pending {
+		p.printMessage(msg)
+	}
+}
+
+// printMessage prints the message, unless it is empty.
+func (p *Printer) printMessage(msg agent.Message) {
+	if strings.TrimSpace(msg.Message) == "" {
+		return
+	}
+
+	name := msg.Tool
+	if name == "" {
+		name = agent.Name
+	}
+	p.print(Event{Type: EventMessage, Time: msg.Timestamp, Tool: name, Message: msg.Message})
+}
+
+// print prints the event in the output format.
+func (p *Printer) print(event Event) {
+	if p.format == FormatJSON {
+		p.printJSON(event)
+		return
+	}
+
+	switch event.Type {
+	case EventMessage:
+		fmt.Fprintf(p.writer, "[%s] %s\n", event.Tool, event.Message)
+	case EventCommand:
+		fmt.Fprintf(p.writer, "%s\n", commandLine(*event.Command))
+	case EventOutput:
+		fmt.Fprintf(p.writer, "  | %s\n", event.Line)
+	case EventStatus:
+		fmt.Fprintf(p.writer, "Status: %s\n", event.Status)
+	case EventApproval, EventInteractive:
+		fmt.Fprintf(p.writer, "$ %s (%s)\n", event.Command.Command, event.Message)
+	}
+}
+
+// printJSON prints the value as a line of JSON.
+func (p *Printer) printJSON(value any) {
+	encoder := json.NewEncoder(p.writer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		p.logger.With("error", err).Error("Failed to encode event.")
+	}
+}
+
+// commandLine returns the command with its working directory and result.
+func commandLine(cmd tool.Command) string {
+	line := fmt.Sprintf("$ %s [%s]", cmd.Command, cmd.Location())
+	if cmd.DryRun {
+		return line + " (dry run)"
+	}
+
+	return fmt.Sprintf("%s (exit %d)", line, cmd.ExitCode)
+}
@@ -0,0 +1,207 @@
+package headless
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datolabs-io/opsy/internal/agent"
+	"github.com/datolabs-io/opsy/internal/tool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCommunication creates unbuffered communication channels, as used by the agent.
+func newTestCommunication() *agent.Communication {
+	return &agent.Communication{
+		Commands:  make(chan tool.Command),
+		Messages:  make(chan agent.Message),
+		Status:    make(chan agent.Status),
+		Approvals: make(chan tool.ApprovalRequest),
+		Output:    make(chan tool.CommandOutput),
+		Usage:     make(chan agent.Usage),
+		Tools:     make(chan agent.ToolRun),
+		Terminal:  make(chan tool.TerminalRequest),
+	}
+}
+
+// runPrinter runs the printer while the events are sent and returns once it has stopped.
+func runPrinter(printer *Printer, send func(comm *agent.Communication)) {
+	comm := newTestCommunication()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		printer.Run(ctx, comm)
+		close(done)
+	}()
+
+	send(comm)
+	cancel()
+	<-done
+}
+
+// sendTestEvents sends a streamed message, a running command, its completion and a status.
+func sendTestEvents(comm *agent.Communication) {
+	cmd := tool.Command{ID: "cmd-1", Command: "ls", WorkingDirectory: "/tmp", ExitCode: 0, CompletedAt: time.Now()}
+
+	comm.Status <- agent.StatusRunning
+	comm.Messages <- agent.Message{ID: "msg_1-0", Message: "Listing"}
+	comm.Messages <- agent.Message{ID: "msg_1-0", Message: "Listing the files."}
+	comm.Output <- tool.CommandOutput{Command: cmd, Started: true}
+	comm.Output <- tool.CommandOutput{Command: cmd, Line: "file", Stream: tool.StreamStdout}
+	comm.Commands <- cmd
+	comm.Usage <- agent.Usage{Requests: 1}
+	comm.Messages <- agent.Message{Tool: "Git", Message: "Done."}
+}
+
+// TestParseFormat tests parsing of the output formats
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("json")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, format)
+
+	_, err = ParseFormat("yaml")
+	assert.ErrorContains(t, err, ErrInvalidFormat)
+}
+
+// TestPrinter_Run tests printing of the events as they happen
+func TestPrinter_Run(t *testing.T) {
+	t.Run("prints text", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		printer := New(WithWriter(buffer))
+		runPrinter(printer, sendTestEvents)
+
+		assert.Equal(t, strings.Join([]string{
+			"Status: Running",
+			"[Opsy] Listing the files.",
+			"  | file",
+			"$ ls [/tmp] (exit 0)",
+			"[Git] Done.",
+		}, "\n")+"\n", buffer.String())
+		require.Len(t, printer.Commands(), 1)
+		assert.Equal(t, "ls", printer.Commands()[0].Command)
+	})
+
+	t.Run("prints JSON lines", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		runPrinter(New(WithWriter(buffer), WithFormat(FormatJSON)), sendTestEvents)
+
+		lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		require.Len(t, lines, 5)
+
+		events := make([]Event, len(lines))
+		for i, line := range lines {
+			require.NoError(t, json.Unmarshal([]byte(line), &events[i]))
+		}
+
+		assert.Equal(t, EventStatus, events[0].Type)
+		assert.Equal(t, "Running", events[0].Status)
+		assert.Equal(t, EventMessage, events[1].Type)
+		assert.Equal(t, "Opsy", events[1].Tool)
+		assert.Equal(t, "Listing the files.", events[1].Message)
+		assert.Equal(t, EventOutput, events[2].Type)
+		assert.Equal(t, "file", events[2].Line)
+		assert.Equal(t, tool.StreamStdout, events[2].Stream)
+		assert.Equal(t, EventCommand, events[3].Type)
+		assert.Equal(t, "ls", events[3].Command.Command)
+		assert.Contains(t, lines[3], `"working_directory":"/tmp"`)
+		assert.Equal(t, "Git", events[4].Tool)
+	})
+
+	t.Run("prints the streamed messages of parallel tools once", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		runPrinter(New(WithWriter(buffer)), func(comm *agent.Communication) {
+			comm.Tools <- agent.ToolRun{ID: "toolu_1", Tool: "Kubernetes"}
+			comm.Messages <- agent.Message{ID: "msg_1-0", Tool: "Kubernetes", Message: "Checking"}
+			comm.Messages <- agent.Message{ID: "msg_2-0", Tool: "AWS", Message: "Listing"}
+			comm.Messages <- agent.Message{ID: "msg_1-0", Tool: "Kubernetes", Message: "Checking the pods."}
+			comm.Messages <- agent.Message{ID: "msg_2-0", Tool: "AWS", Message: "Listing the instances."}
+			comm.Messages <- agent.Message{ID: "msg_3-0", Tool: "Kubernetes", Message: "All pods are running."}
+			comm.Messages <- agent.Message{ID: "msg_4-0", Tool: "AWS", Message: "All instances are running."}
+		})
+
+		assert.Equal(t, strings.Join([]string{
+			"[Kubernetes] Checking the pods.",
+			"[AWS] Listing the instances.",
+			"[Kubernetes] All pods are running.",
+			"[AWS] All instances are running.",
+		}, "\n")+"\n", buffer.String())
+	})
+
+	t.Run("rejects approval requests", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		var approval tool.Approval
+		runPrinter(New(WithWriter(buffer)), func(comm *agent.Communication) {
+			request := tool.NewApprovalRequest(context.Background(), tool.Command{Command: "rm -rf /tmp/test"})
+			comm.Approvals <- request
+			approval = <-request.Response
+		})
+
+		assert.Equal(t, tool.DecisionRejected, approval.Decision)
+		assert.Contains(t, buffer.String(), "$ rm -rf /tmp/test (rejected: commands cannot be approved in headless mode")
+	})
+
+	t.Run("rejects interactive commands", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		var err error
+		runPrinter(New(WithWriter(buffer), WithFormat(FormatJSON)), func(comm *agent.Communication) {
+			request := tool.NewTerminalRequest(tool.Command{Command: "aws sso login"}, nil)
+			comm.Terminal <- request
+			err = <-request.Response
+		})
+
+		assert.EqualError(t, err, messageInteractiveRejected)
+		assert.Contains(t, buffer.String(), `"type":"interactive"`)
+		assert.Contains(t, buffer.String(), `"message":"rejected: interactive commands cannot be run in headless mode"`)
+	})
+}
+
+// TestPrinter_PrintSummary tests printing of the summary of the task
+func TestPrinter_PrintSummary(t *testing.T) {
+	summary := Summary{
+		Task:     "list files",
+		Status:   agent.StatusError,
+		Error:    "task budget exceeded",
+		Outputs:  []tool.Output{{Tool: "exec", Result: "file"}},
+		Commands: []tool.Command{{Command: "ls", WorkingDirectory: "/tmp", DryRun: true}},
+		Usage:    agent.UsageReport{Total: agent.Usage{Requests: 2, InputTokens: 1200, OutputTokens: 30, Cost: 0.5}},
+	}
+
+	t.Run("prints text", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		New(WithWriter(buffer)).PrintSummary(summary)
+
+		assert.Equal(t, strings.Join([]string{
+			"",
+			"Status: Error",
+			"Error: task budget exceeded",
+			"Commands: 1",
+			"  1. $ ls [/tmp] (dry run)",
+			"Tokens: 1200 in / 30 out | Cost: $0.500",
+		}, "\n")+"\n", buffer.String())
+	})
+
+	t.Run("prints JSON", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		New(WithWriter(buffer), WithFormat(FormatJSON)).PrintSummary(summary)
+
+		var printed map[string]any
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &printed))
+		assert.Equal(t, "summary", printed["type"])
+		assert.Equal(t, "list files", printed["task"])
+		assert.Equal(t, "Error", printed["status"])
+		assert.Equal(t, "task budget exceeded", printed["error"])
+		assert.Len(t, printed["outputs"], 1)
+		assert.Len(t, printed["commands"], 1)
+		assert.Equal(t, float64(1200), printed["usage"].(map[string]any)["total"].(map[string]any)["input_tokens"])
+	})
+
+	t.Run("prints empty lists", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		New(WithWriter(buffer), WithFormat(FormatJSON)).PrintSummary(Summary{Status: agent.StatusFinished})
+		assert.Contains(t, buffer.String(), `"outputs":[],"commands":[]`)
+	})
+}
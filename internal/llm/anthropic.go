@@ -0,0 +1,210 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/anthropics/anthropic-sdk-go/packages/param"
+	"github.com/datolabs-io/opsy/internal/config"
+)
+
+const (
+	// anthropicName is the display name of the Anthropic provider.
+	anthropicName = "Anthropic"
+	// anthropicStreamErrorPrefix is the prefix of the errors of the SDK for the error events of a streamed response.
+	anthropicStreamErrorPrefix = "received error while streaming: "
+)
+
+// anthropicErrorStatusCodes are the HTTP status codes of the error types of the Anthropic API, used for the errors
+// received as events of a streamed response, after the response status.
+var anthropicErrorStatusCodes = map[string]int{
+	"invalid_request_error": http.StatusBadRequest,
+	"authentication_error":  http.StatusUnauthorized,
+	"permission_error":      http.StatusForbidden,
+	"not_found_error":       http.StatusNotFound,
+	"request_too_large":     http.StatusRequestEntityTooLarge,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"api_error":             http.StatusInternalServerError,
+	"overloaded_error":      529,
+}
+
+// anthropicProvider is the provider of the Anthropic API.
+type anthropicProvider struct {
+	client *anthropic.Client
+	logger *slog.Logger
+}
+
+// NewAnthropic creates a new provider of the Anthropic API.
+func NewAnthropic(cfg config.AnthropicConfiguration, opts ...Option) *anthropicProvider {
+	o := newOptions(opts...)
+
+	// Retries are handled by the agent so that they can be reported to the user:
+	requestOptions := []option.RequestOption{
+		option.WithAPIKey(cfg.APIKey),
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(o.httpClient),
+	}
+	if o.baseURL != "" {
+		requestOptions = append(requestOptions, option.WithBaseURL(o.baseURL))
+	}
+
+	client := anthropic.NewClient(requestOptions...)
+
+	return &anthropicProvider{client: &client, logger: o.logger}
+}
+
+// Name returns the display name of the provider.
+func (p *anthropicProvider) Name() string {
+	return anthropicName
+}
+
+// Send streams the response of the Anthropic API, passing the text blocks to the handler as they are generated.
+func (p *anthropicProvider) Send(ctx context.Context, request Request, handler StreamHandler) (*Response, error) {
+	stream := p.client.Messages.NewStreaming(ctx, anthropicRequest(request))
+	defer func() { _ = stream.Close() }()
+
+	message := &anthropic.Message{}
+	for stream.Next() {
+		event := stream.Current()
+		if err := message.Accumulate(event); err != nil {
+			return nil, err
+		}
+
+		delta, ok := event.AsAny().(anthropic.ContentBlockDeltaEvent)
+		if !ok || handler == nil {
+			continue
+		}
+
+		if _, ok := delta.Delta.AsAny().(anthropic.TextDelta); ok {
+			handler(TextUpdate{
+				ResponseID: message.ID,
+				Index:      int(delta.Index),
+				Text:       message.Content[len(message.Content)-1].Text,
+			})
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		var apiErr *anthropic.Error
+		if errors.As(err, &apiErr) && apiErr.Response != nil {
+			return nil, &Error{StatusCode: apiErr.StatusCode, Header: apiErr.Response.Header, Err: err}
+		}
+		if statusCode, ok := anthropicStreamErrorStatusCode(err); ok {
+			return nil, &Error{StatusCode: statusCode, Err: err}
+		}
+		return nil, err
+	}
+
+	return anthropicResponse(message), nil
+}
+
+// anthropicStreamErrorStatusCode returns the HTTP status code of the type of an error event of a streamed response,
+// such as overloaded_error, so that it is retried like the same error returned before the response is streamed.
+func anthropicStreamErrorStatusCode(err error) (int, bool) {
+	data, ok := strings.CutPrefix(err.Error(), anthropicStreamErrorPrefix)
+	if !ok {
+		return 0, false
+	}
+
+	var event struct {
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return 0, false
+	}
+
+	statusCode, ok := anthropicErrorStatusCodes[event.Error.Type]
+	return statusCode, ok
+}
+
+// anthropicRequest converts the request to the format of the Anthropic API.
+func anthropicRequest(request Request) anthropic.MessageNewParams {
+	params := anthropic.MessageNewParams{
+		Model:       anthropic.Model(request.Model),
+		MaxTokens:   request.MaxTokens,
+		System:      []anthropic.TextBlockParam{{Text: request.System}},
+		Temperature: param.NewOpt(request.Temperature),
+	}
+
+	for _, message := range request.Messages {
+		blocks := []anthropic.ContentBlockParamUnion{}
+		for _, content := range message.Content {
+			switch content.Type {
+			case ContentText:
+				if content.Text != "" {
+					blocks = append(blocks, anthropic.NewTextBlock(content.Text))
+				}
+			case ContentToolUse:
+				blocks = append(blocks, anthropic.NewToolUseBlock(content.ToolUse.ID, content.ToolUse.Input, content.ToolUse.Name))
+			case ContentToolResult:
+				blocks = append(blocks, anthropic.NewToolResultBlock(content.ToolResult.ToolUseID, content.ToolResult.Content,
+					content.ToolResult.IsError))
+			}
+		}
+
+		if message.Role == RoleAssistant {
+			params.Messages = append(params.Messages, anthropic.NewAssistantMessage(blocks...))
+		} else {
+			params.Messages = append(params.Messages, anthropic.NewUserMessage(blocks...))
+		}
+	}
+
+	for _, tool := range request.Tools {
+		inputSchema := anthropic.ToolInputSchemaParam{}
+		if tool.InputSchema != nil {
+			inputSchema.Properties = tool.InputSchema.Properties
+		}
+
+		params.Tools = append(params.Tools, anthropic.ToolUnionParam{
+			OfTool: &anthropic.ToolParam{
+				Name:        tool.Name,
+				Description: param.NewOpt(tool.Description),
+				InputSchema: inputSchema,
+			},
+		})
+	}
+
+	if len(request.Tools) > 0 {
+		params.ToolChoice = anthropic.ToolChoiceUnionParam{
+			OfAuto: &anthropic.ToolChoiceAutoParam{
+				DisableParallelToolUse: param.NewOpt(!request.ParallelToolUse),
+			},
+		}
+	}
+
+	return params
+}
+
+// anthropicResponse converts the message of the Anthropic API to a response.
+func anthropicResponse(message *anthropic.Message) *Response {
+	response := &Response{
+		ID:         message.ID,
+		Model:      string(message.Model),
+		StopReason: StopReason(message.StopReason),
+		Usage: Usage{
+			InputTokens:      message.Usage.InputTokens,
+			OutputTokens:     message.Usage.OutputTokens,
+			CacheWriteTokens: message.Usage.CacheCreationInputTokens,
+			CacheReadTokens:  message.Usage.CacheReadInputTokens,
+		},
+	}
+
+	for _, block := range message.Content {
+		switch block.Type {
+		case "text":
+			response.Content = append(response.Content, NewText(block.Text))
+		case "tool_use":
+			response.Content = append(response.Content, NewToolUse(block.ID, block.Name, json.RawMessage(block.Input)))
+		}
+	}
+
+	return response
+}
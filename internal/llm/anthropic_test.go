@@ -0,0 +1,192 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/invopop/jsonschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+)
+
+// testAnthropicStream is a minimal streamed response of the Anthropic Messages API.
+const testAnthropicStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"test-model","content":[],"stop_reason":null,"usage":{"input_tokens":10,"cache_creation_input_tokens":3,"cache_read_input_tokens":4,"output_tokens":0}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"do"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"ne"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"exec","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"command\": \"ls\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":2}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+// testRequest returns a request with a conversation covering all the content types.
+func testRequest() Request {
+	properties := orderedmap.New[string, *jsonschema.Schema]()
+	properties.Set("command", &jsonschema.Schema{Type: "string"})
+
+	return Request{
+		Model:       "test-model",
+		System:      "You are a test.",
+		MaxTokens:   1024,
+		Temperature: 0.5,
+		Messages: []Message{
+			{Role: RoleUser, Content: []Content{NewText("list files")}},
+			{Role: RoleAssistant, Content: []Content{NewText("Listing."), NewToolUse("call_1", "exec", json.RawMessage(`{"command":"ls"}`))}},
+			{Role: RoleUser, Content: []Content{NewToolResult("call_1", "file", false)}},
+		},
+		Tools: []Tool{{Name: "exec", Description: "Executes commands.", InputSchema: &jsonschema.Schema{Type: "object", Properties: properties}}},
+	}
+}
+
+// TestAnthropicProvider_Send tests sending requests to the Anthropic API
+func TestAnthropicProvider_Send(t *testing.T) {
+	t.Run("streams response", func(t *testing.T) {
+		var body map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(data, &body)
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(testAnthropicStream))
+		}))
+		defer server.Close()
+
+		provider := NewAnthropic(config.AnthropicConfiguration{APIKey: "test-key"}, WithBaseURL(server.URL))
+		updates := []TextUpdate{}
+		response, err := provider.Send(context.Background(), testRequest(), func(update TextUpdate) {
+			updates = append(updates, update)
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []TextUpdate{{ResponseID: "msg_1", Index: 0, Text: "do"}, {ResponseID: "msg_1", Index: 0, Text: "done"}}, updates)
+		assert.Equal(t, "msg_1", response.ID)
+		assert.Equal(t, "test-model", response.Model)
+		assert.Equal(t, StopToolUse, response.StopReason)
+		assert.Equal(t, Usage{InputTokens: 10, OutputTokens: 2, CacheWriteTokens: 3, CacheReadTokens: 4}, response.Usage)
+		require.Len(t, response.Content, 2)
+		assert.Equal(t, NewText("done"), response.Content[0])
+		assert.Equal(t, "exec", response.Content[1].ToolUse.Name)
+		assert.JSONEq(t, `{"command": "ls"}`, string(response.Content[1].ToolUse.Input))
+
+		assert.Equal(t, "test-model", body["model"])
+		assert.Len(t, body["messages"], 3)
+		assert.Equal(t, map[string]any{"type": "auto", "disable_parallel_tool_use": true}, body["tool_choice"])
+	})
+
+	t.Run("returns API errors", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "5")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"Rate limited"}}`))
+		}))
+		defer server.Close()
+
+		provider := NewAnthropic(config.AnthropicConfiguration{APIKey: "test-key"}, WithBaseURL(server.URL))
+		_, err := provider.Send(context.Background(), testRequest(), nil)
+
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+		assert.Equal(t, "5", apiErr.Header.Get("Retry-After"))
+	})
+
+	t.Run("returns error events of the stream as API errors", func(t *testing.T) {
+		tests := []struct {
+			errorType  string
+			statusCode int
+		}{
+			{errorType: "overloaded_error", statusCode: 529},
+			{errorType: "api_error", statusCode: http.StatusInternalServerError},
+			{errorType: "invalid_request_error", statusCode: http.StatusBadRequest},
+		}
+
+		for _, tt := range tests {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = w.Write([]byte(testAnthropicStreamError(tt.errorType)))
+			}))
+			defer server.Close()
+
+			provider := NewAnthropic(config.AnthropicConfiguration{APIKey: "test-key"}, WithBaseURL(server.URL))
+			_, err := provider.Send(context.Background(), testRequest(), nil)
+
+			var apiErr *Error
+			require.ErrorAs(t, err, &apiErr, tt.errorType)
+			assert.Equal(t, tt.statusCode, apiErr.StatusCode, tt.errorType)
+			assert.ErrorContains(t, err, tt.errorType)
+		}
+	})
+
+	t.Run("returns unknown error events of the stream as is", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(testAnthropicStreamError("unknown_error")))
+		}))
+		defer server.Close()
+
+		provider := NewAnthropic(config.AnthropicConfiguration{APIKey: "test-key"}, WithBaseURL(server.URL))
+		_, err := provider.Send(context.Background(), testRequest(), nil)
+
+		var apiErr *Error
+		assert.ErrorContains(t, err, "unknown_error")
+		assert.False(t, errors.As(err, &apiErr))
+	})
+}
+
+// testAnthropicStreamError returns a streamed response of the Anthropic Messages API interrupted by an error event.
+func testAnthropicStreamError(errorType string) string {
+	return `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"test-model","content":[],"stop_reason":null,"usage":{"input_tokens":10,"output_tokens":0}}}
+
+event: error
+data: {"type":"error","error":{"type":"` + errorType + `","message":"Stream failed"}}
+
+`
+}
+
+// TestAnthropicRequest tests the conversion of the requests to the format of the Anthropic API
+func TestAnthropicRequest(t *testing.T) {
+	t.Run("allows parallel tool use", func(t *testing.T) {
+		request := testRequest()
+		request.ParallelToolUse = true
+		params := anthropicRequest(request)
+		assert.False(t, params.ToolChoice.OfAuto.DisableParallelToolUse.Value)
+	})
+
+	t.Run("skips tool choice without tools", func(t *testing.T) {
+		request := testRequest()
+		request.Tools = nil
+		params := anthropicRequest(request)
+		assert.Nil(t, params.ToolChoice.OfAuto)
+	})
+}
@@ -0,0 +1,91 @@
+/*
+Package llm provides the providers of the large language models used by the agent of the opsy application.
+
+The agent talks to a Provider through a provider-neutral conversation model, so that the task loop, tool calls,
+retries and token usage work the same regardless of the API behind the model.
+
+# Providers
+
+The provider is selected by the llm.provider configuration:
+
+  - anthropic (default): The Anthropic Messages API, configured by the anthropic section
+  - openai: Any OpenAI-compatible chat completions API, such as OpenAI, Ollama or vLLM, configured by the
+    llm.openai section
+  - replay: A recorded transcript played back without network access, configured by the llm.replay section
+
+Providers are created with New, which returns an error if the Anthropic provider is selected without an API key:
+
+	provider, err := llm.New(cfg, llm.WithLogger(logger))
+
+NewAnthropic, NewOpenAI and NewReplay create a specific provider. Available options include:
+
+  - WithBaseURL: Overrides the base URL of the API
+  - WithHTTPClient: Sets the HTTP client used to send the requests
+  - WithLogger: Sets the logger for the provider
+
+ModelFromConfig returns the display name of the selected provider together with its model, max tokens and
+temperature, for building requests and showing them in the UI.
+
+# Conversation Model
+
+A Request holds the system prompt, the Messages of the conversation and the Tools the model may call. Each
+Message has a Role and a list of Content blocks:
+
+  - ContentText: Text written by the user or the model
+  - ContentToolUse: A tool call requested by the model, with its JSON encoded input
+  - ContentToolResult: The result of a tool call, sent back in a user message
+
+The OpenAI-compatible provider converts tool results to messages with the tool role and sends the arguments of
+tool calls as strings. Parallel tool calls are disabled unless Request.ParallelToolUse is set.
+
+# Streaming
+
+Send streams the response and passes the text generated so far to the StreamHandler as a TextUpdate, keyed by
+the response ID and the index of the content block. The complete Response is returned once the stream ends,
+with its StopReason and the token Usage of the request. For OpenAI-compatible APIs, the prompt tokens read from
+the cache are reported as cache read tokens.
+
+# Replay and Recording
+
+A transcript is a list of Turns, the responses of the model in the order they were received, each with its text
+and tool_use content blocks and optionally its ID, model, stop reason and token usage. LoadTranscript reads JSONL
+files, with a turn per line, and YAML files (.yaml or .yml) with a list of turns:
+
+	# transcript.yaml
+	- content:
+	    - type: text
+	      text: Listing the files.
+	    - type: tool_use
+	      name: exec
+	      input:
+	        command: ls
+	- content:
+	    - type: text
+	      text: The directory contains a single file.
+
+The replay provider ignores the requests and answers each of them with the next turn, returning an error wrapping
+ErrTranscriptEnd once all the turns were replayed. Missing IDs and stop reasons are derived from the position and
+content of the turn, so transcripts can be written by hand for tests and demos.
+
+When llm.record is set, New wraps the provider in a recorder which appends every response to the JSONL transcript
+at that path, so that real sessions can be replayed later, e.g. to reproduce a bug report. A response which cannot
+be recorded is logged and still returned.
+
+# Error Handling
+
+Errors returned by the API with an HTTP status are wrapped in Error, which carries the status code and the
+response headers so that the caller can decide whether and when to retry. Errors the Anthropic API sends as
+events of a streamed response, such as overloaded_error, are wrapped as well, with the status code of their type
+(529 for overloaded_error, 500 for api_error), so they are retried like the same errors sent before streaming.
+An OpenAI-compatible stream which is cut off, or ends without data: [DONE] or a finish reason, is wrapped with
+the 502 status code, so it is retried as well.
+Providers do not retry requests themselves. The package also defines:
+
+  - ErrMissingAPIKey: The selected provider requires an API key
+  - ErrUnknownProvider: The configured provider is not supported
+  - ErrStream: The streamed response could not be read
+  - ErrReadTranscript: The transcript could not be read
+  - ErrTranscriptEnd: The replayed transcript has no more turns
+  - ErrRecord: A response could not be recorded
+*/
+package llm
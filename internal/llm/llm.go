@@ -0,0 +1,316 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/invopop/jsonschema"
+)
+
+// Provider is the interface for the providers of large language models.
+type Provider interface {
+	// Name returns the display name of the provider.
+	Name() string
+	// Send sends the request and returns the complete response. The text of the response is passed to the handler as it
+	// is generated, unless the handler is nil.
+	Send(ctx context.Context, request Request, handler StreamHandler) (*Response, error)
+}
+
+// StreamHandler handles the text of a response as it is generated.
+type StreamHandler func(update TextUpdate)
+
+// Role is the role of the author of a message.
+type Role string
+
+// ContentType is the type of a content block.
+type ContentType string
+
+// StopReason is the reason why the model stopped generating the response.
+type StopReason string
+
+const (
+	// ErrMissingAPIKey is the error returned when the provider requires an API key but none is configured.
+	ErrMissingAPIKey = "missing API key"
+	// ErrUnknownProvider is the error returned when the configured provider is not supported.
+	ErrUnknownProvider = "unknown provider"
+	// ErrStream is the error returned when the streamed response cannot be read.
+	ErrStream = "failed to read streamed response"
+	// ErrReadTranscript is the error returned when the transcript cannot be read.
+	ErrReadTranscript = "failed to read transcript"
+	// ErrTranscriptEnd is the error returned when the replayed transcript has no more turns.
+	ErrTranscriptEnd = "transcript has no more turns"
+	// ErrRecord is the error returned when a response cannot be recorded.
+	ErrRecord = "failed to record response"
+
+	// RoleUser is the role of the messages of the user, including the tool results.
+	RoleUser Role = "user"
+	// RoleAssistant is the role of the messages generated by the model.
+	RoleAssistant Role = "assistant"
+
+	// ContentText is a text content block.
+	ContentText ContentType = "text"
+	// ContentToolUse is a content block requesting a tool call.
+	ContentToolUse ContentType = "tool_use"
+	// ContentToolResult is a content block with the result of a tool call.
+	ContentToolResult ContentType = "tool_result"
+
+	// StopEndTurn is the stop reason of a complete response.
+	StopEndTurn StopReason = "end_turn"
+	// StopToolUse is the stop reason of a response requesting tool calls.
+	StopToolUse StopReason = "tool_use"
+	// StopMaxTokens is the stop reason of a response cut off by the maximum number of tokens.
+	StopMaxTokens StopReason = "max_tokens"
+)
+
+// Request is a request to generate the next message of a conversation.
+type Request struct {
+	// Model is the model to use.
+	Model string
+	// System is the system prompt.
+	System string
+	// Messages are the messages of the conversation.
+	Messages []Message
+	// Tools are the tools the model may call.
+	Tools []Tool
+	// MaxTokens is the maximum number of tokens to generate.
+	MaxTokens int64
+	// Temperature is the temperature to use.
+	Temperature float64
+	// ParallelToolUse is whether the model may call several tools in a single response.
+	ParallelToolUse bool
+}
+
+// Response is a message generated by the model.
+type Response struct {
+	// ID identifies the response.
+	ID string
+	// Model is the model which generated the response.
+	Model string
+	// Content is the content of the response.
+	Content []Content
+	// StopReason is the reason why the model stopped generating the response.
+	StopReason StopReason
+	// Usage is the token usage of the request.
+	Usage Usage
+}
+
+// Message is a message of a conversation.
+type Message struct {
+	// Role is the role of the author of the message.
+	Role Role `json:"role"`
+	// Content is the content of the message.
+	Content []Content `json:"content"`
+}
+
+// Content is a content block of a message.
+type Content struct {
+	// Type is the type of the content block.
+	Type ContentType `json:"type"`
+	// Text is the text of a text content block.
+	Text string `json:"text,omitempty"`
+	// ToolUse is the tool call of a tool use content block.
+	ToolUse *ToolUse `json:"tool_use,omitempty"`
+	// ToolResult is the result of a tool result content block.
+	ToolResult *ToolResult `json:"tool_result,omitempty"`
+}
+
+// ToolUse is a tool call requested by the model.
+type ToolUse struct {
+	// ID identifies the tool call.
+	ID string `json:"id"`
+	// Name is the name of the tool.
+	Name string `json:"name"`
+	// Input is the JSON encoded input of the tool.
+	Input json.RawMessage `json:"input"`
+}
+
+// ToolResult is the result of a tool call.
+type ToolResult struct {
+	// ToolUseID is the ID of the tool call.
+	ToolUseID string `json:"tool_use_id"`
+	// Content is the result of the tool.
+	Content string `json:"content"`
+	// IsError is true if the tool call failed.
+	IsError bool `json:"is_error,omitempty"`
+}
+
+// Tool is a tool the model may call.
+type Tool struct {
+	// Name is the name of the tool.
+	Name string
+	// Description is the description of the tool.
+	Description string
+	// InputSchema is the schema of the input of the tool.
+	InputSchema *jsonschema.Schema
+}
+
+// Usage is the token usage of a request.
+type Usage struct {
+	// InputTokens is the number of input tokens which were not read from or written to the prompt cache.
+	InputTokens int64 `json:"input_tokens,omitempty" yaml:"input_tokens,omitempty"`
+	// OutputTokens is the number of output tokens.
+	OutputTokens int64 `json:"output_tokens,omitempty" yaml:"output_tokens,omitempty"`
+	// CacheWriteTokens is the number of input tokens written to the prompt cache.
+	CacheWriteTokens int64 `json:"cache_write_tokens,omitempty" yaml:"cache_write_tokens,omitempty"`
+	// CacheReadTokens is the number of input tokens read from the prompt cache.
+	CacheReadTokens int64 `json:"cache_read_tokens,omitempty" yaml:"cache_read_tokens,omitempty"`
+}
+
+// TextUpdate is the text of a content block of a response being generated.
+type TextUpdate struct {
+	// ResponseID identifies the response.
+	ResponseID string
+	// Index is the index of the content block in the response.
+	Index int
+	// Text is the text of the content block generated so far.
+	Text string
+}
+
+// Error is an error returned by the API of a provider.
+type Error struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Header is the header of the response.
+	Header http.Header
+	// Err is the underlying error.
+	Err error
+}
+
+// Model is the model of the selected provider and its generation parameters.
+type Model struct {
+	// Provider is the display name of the provider.
+	Provider string
+	// Name is the name of the model.
+	Name string
+	// MaxTokens is the maximum number of tokens to generate.
+	MaxTokens int64
+	// Temperature is the temperature to use.
+	Temperature float64
+}
+
+// Option is a function that configures a provider.
+type Option func(*options)
+
+// options are the options shared by the providers.
+type options struct {
+	baseURL    string
+	httpClient *http.Client
+	logger     *slog.Logger
+}
+
+// Error returns the error message.
+func (e *Error) Error() string {
+	return fmt.Sprintf("status %d: %v", e.StatusCode, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New creates the provider selected in the configuration. If a record path is configured, the responses of the
+// provider are recorded to it.
+func New(cfg config.Configuration, opts ...Option) (Provider, error) {
+	var provider Provider
+
+	switch cfg.LLM.Provider {
+	case "", config.ProviderAnthropic:
+		if cfg.Anthropic.APIKey == "" {
+			return nil, fmt.Errorf("%s: %s", ErrMissingAPIKey, config.ProviderAnthropic)
+		}
+		provider = NewAnthropic(cfg.Anthropic, opts...)
+	case config.ProviderOpenAI:
+		provider = NewOpenAI(cfg.LLM.OpenAI, opts...)
+	case config.ProviderReplay:
+		turns, err := LoadTranscript(cfg.LLM.Replay.Path)
+		if err != nil {
+			return nil, err
+		}
+		provider = NewReplay(turns, opts...)
+	default:
+		return nil, fmt.Errorf("%s: %s", ErrUnknownProvider, cfg.LLM.Provider)
+	}
+
+	if cfg.LLM.Record != "" {
+		provider = NewRecorder(provider, cfg.LLM.Record, opts...)
+	}
+
+	return provider, nil
+}
+
+// ModelFromConfig returns the model of the provider selected in the configuration.
+func ModelFromConfig(cfg config.Configuration) Model {
+	switch cfg.LLM.Provider {
+	case config.ProviderOpenAI:
+		return Model{
+			Provider:    openAIName,
+			Name:        cfg.LLM.OpenAI.Model,
+			MaxTokens:   cfg.LLM.OpenAI.MaxTokens,
+			Temperature: cfg.LLM.OpenAI.Temperature,
+		}
+	case config.ProviderReplay:
+		return Model{Provider: replayName, Name: filepath.Base(cfg.LLM.Replay.Path)}
+	}
+
+	return Model{
+		Provider:    anthropicName,
+		Name:        cfg.Anthropic.Model,
+		MaxTokens:   cfg.Anthropic.MaxTokens,
+		Temperature: cfg.Anthropic.Temperature,
+	}
+}
+
+// WithBaseURL sets the base URL of the API, overriding the configured one.
+func WithBaseURL(url string) Option {
+	return func(o *options) {
+		o.baseURL = url
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to send the requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithLogger sets the logger for the provider.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger.With("component", "llm")
+	}
+}
+
+// newOptions returns the options with the defaults applied.
+func newOptions(opts ...Option) *options {
+	o := &options{
+		httpClient: http.DefaultClient,
+		logger:     slog.New(slog.DiscardHandler),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// NewText returns a text content block.
+func NewText(text string) Content {
+	return Content{Type: ContentText, Text: text}
+}
+
+// NewToolUse returns a tool use content block.
+func NewToolUse(id, name string, input json.RawMessage) Content {
+	return Content{Type: ContentToolUse, ToolUse: &ToolUse{ID: id, Name: name, Input: input}}
+}
+
+// NewToolResult returns a tool result content block.
+func NewToolResult(toolUseID, content string, isError bool) Content {
+	return Content{Type: ContentToolResult, ToolResult: &ToolResult{ToolUseID: toolUseID, Content: content, IsError: isError}}
+}
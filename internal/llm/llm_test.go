@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNew tests the creation of the provider selected in the configuration
+func TestNew(t *testing.T) {
+	t.Run("creates Anthropic provider by default", func(t *testing.T) {
+		provider, err := New(config.Configuration{Anthropic: config.AnthropicConfiguration{APIKey: "test-key"}})
+		require.NoError(t, err)
+		assert.Equal(t, "Anthropic", provider.Name())
+	})
+
+	t.Run("requires Anthropic API key", func(t *testing.T) {
+		_, err := New(config.Configuration{LLM: config.LLMConfiguration{Provider: config.ProviderAnthropic}})
+		assert.ErrorContains(t, err, ErrMissingAPIKey)
+	})
+
+	t.Run("creates OpenAI-compatible provider", func(t *testing.T) {
+		provider, err := New(config.Configuration{LLM: config.LLMConfiguration{Provider: config.ProviderOpenAI}})
+		require.NoError(t, err)
+		assert.Equal(t, "OpenAI", provider.Name())
+	})
+
+	t.Run("returns error for unknown provider", func(t *testing.T) {
+		_, err := New(config.Configuration{LLM: config.LLMConfiguration{Provider: "unknown"}})
+		assert.ErrorContains(t, err, ErrUnknownProvider)
+	})
+}
+
+// TestModelFromConfig tests the model of the provider selected in the configuration
+func TestModelFromConfig(t *testing.T) {
+	cfg := config.Configuration{
+		Anthropic: config.AnthropicConfiguration{Model: "claude", MaxTokens: 1024, Temperature: 0.5},
+		LLM: config.LLMConfiguration{
+			OpenAI: config.OpenAIConfiguration{Model: "llama3.1", MaxTokens: 4096, Temperature: 0.2},
+		},
+	}
+
+	assert.Equal(t, Model{Provider: "Anthropic", Name: "claude", MaxTokens: 1024, Temperature: 0.5}, ModelFromConfig(cfg))
+
+	cfg.LLM.Provider = config.ProviderOpenAI
+	assert.Equal(t, Model{Provider: "OpenAI", Name: "llama3.1", MaxTokens: 4096, Temperature: 0.2}, ModelFromConfig(cfg))
+}
+
+// TestError tests the errors returned by the APIs of the providers
+func TestError(t *testing.T) {
+	cause := errors.New("overloaded")
+	err := &Error{StatusCode: http.StatusServiceUnavailable, Err: cause}
+
+	assert.EqualError(t, err, "status 503: overloaded")
+	assert.ErrorIs(t, err, cause)
+}
@@ -0,0 +1,369 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/datolabs-io/opsy/internal/config"
+)
+
+const (
+	// openAIName is the display name of the OpenAI-compatible provider.
+	openAIName = "OpenAI"
+	// openAIChatPath is the path of the chat completions endpoint.
+	openAIChatPath = "/chat/completions"
+	// openAIDataPrefix is the prefix of the data lines of the streamed response.
+	openAIDataPrefix = "data:"
+	// openAIDone is the data of the last event of the streamed response.
+	openAIDone = "[DONE]"
+	// openAIIncompleteStream is the reason of the error returned when the stream ends before the response is complete.
+	openAIIncompleteStream = "the stream ended before the response was complete"
+)
+
+// openAIProvider is the provider of OpenAI-compatible chat completions APIs, such as OpenAI, Ollama or vLLM.
+type openAIProvider struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	logger     *slog.Logger
+}
+
+// openAIRequest is the request body of the chat completions endpoint.
+type openAIRequest struct {
+	Model             string              `json:"model"`
+	Messages          []openAIMessage     `json:"messages"`
+	Tools             []openAITool        `json:"tools,omitempty"`
+	ParallelToolCalls *bool               `json:"parallel_tool_calls,omitempty"`
+	MaxTokens         int64               `json:"max_tokens,omitempty"`
+	Temperature       float64             `json:"temperature"`
+	Stream            bool                `json:"stream"`
+	StreamOptions     openAIStreamOptions `json:"stream_options"`
+}
+
+// openAIStreamOptions are the options of the streamed response.
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openAIMessage is a message of the chat completions API.
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// openAITool is a tool of the chat completions API.
+type openAITool struct {
+	Type     string             `json:"type"`
+	Function openAIToolFunction `json:"function"`
+}
+
+// openAIToolFunction is the function of a tool of the chat completions API.
+type openAIToolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters"`
+}
+
+// openAIToolCall is a tool call of the chat completions API.
+type openAIToolCall struct {
+	Index    int                    `json:"index"`
+	ID       string                 `json:"id,omitempty"`
+	Type     string                 `json:"type,omitempty"`
+	Function openAIToolCallFunction `json:"function"`
+}
+
+// openAIToolCallFunction is the function of a tool call, with the JSON encoded arguments.
+type openAIToolCallFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// openAIChunk is an event of the streamed response.
+type openAIChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content   string           `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens        int64 `json:"prompt_tokens"`
+		CompletionTokens    int64 `json:"completion_tokens"`
+		PromptTokensDetails struct {
+			CachedTokens int64 `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// openAIStopReasons maps the finish reasons of the chat completions API to the stop reasons.
+var openAIStopReasons = map[string]StopReason{
+	"stop":       StopEndTurn,
+	"tool_calls": StopToolUse,
+	"length":     StopMaxTokens,
+}
+
+// NewOpenAI creates a new provider of an OpenAI-compatible chat completions API.
+func NewOpenAI(cfg config.OpenAIConfiguration, opts ...Option) *openAIProvider {
+	o := newOptions(opts...)
+
+	baseURL := cfg.BaseURL
+	if o.baseURL != "" {
+		baseURL = o.baseURL
+	}
+
+	return &openAIProvider{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     cfg.APIKey,
+		httpClient: o.httpClient,
+		logger:     o.logger,
+	}
+}
+
+// Name returns the display name of the provider.
+func (p *openAIProvider) Name() string {
+	return openAIName
+}
+
+// Send streams the response of the chat completions API, passing the text to the handler as it is generated.
+func (p *openAIProvider) Send(ctx context.Context, request Request, handler StreamHandler) (*Response, error) {
+	body, err := json.Marshal(openAIRequestBody(request))
+	if err != nil {
+		return nil, err
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+openAIChatPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("Accept", "text/event-stream")
+	if p.apiKey != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	httpResponse, err := p.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = httpResponse.Body.Close() }()
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(httpResponse.Body, 64*1024))
+		return nil, &Error{
+			StatusCode: httpResponse.StatusCode,
+			Header:     httpResponse.Header,
+			Err:        errors.New(strings.TrimSpace(string(message))),
+		}
+	}
+
+	return p.readStream(httpResponse.Body, handler)
+}
+
+// readStream reads the server-sent events of the streamed response and accumulates them into a response.
+func (p *openAIProvider) readStream(body io.Reader, handler StreamHandler) (*Response, error) {
+	response := &Response{}
+	text := strings.Builder{}
+	toolCalls := map[int]*openAIToolCall{}
+	complete := false
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, openAIDataPrefix) {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, openAIDataPrefix))
+		if data == openAIDone {
+			complete = true
+			break
+		}
+
+		var chunk openAIChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("%s: %w", ErrStream, err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("%s: %s", ErrStream, chunk.Error.Message)
+		}
+
+		if chunk.ID != "" {
+			response.ID = chunk.ID
+		}
+		if chunk.Model != "" {
+			response.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			cached := chunk.Usage.PromptTokensDetails.CachedTokens
+			response.Usage = Usage{
+				InputTokens:     chunk.Usage.PromptTokens - cached,
+				OutputTokens:    chunk.Usage.CompletionTokens,
+				CacheReadTokens: cached,
+			}
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != "" {
+				response.StopReason = openAIStopReason(choice.FinishReason)
+				complete = true
+			}
+
+			if choice.Delta.Content != "" {
+				text.WriteString(choice.Delta.Content)
+				if handler != nil {
+					handler(TextUpdate{ResponseID: response.ID, Index: 0, Text: text.String()})
+				}
+			}
+
+			for _, delta := range choice.Delta.ToolCalls {
+				call, ok := toolCalls[delta.Index]
+				if !ok {
+					call = &openAIToolCall{Index: delta.Index}
+					toolCalls[delta.Index] = call
+				}
+				if delta.ID != "" {
+					call.ID = delta.ID
+				}
+				if delta.Function.Name != "" {
+					call.Function.Name = delta.Function.Name
+				}
+				call.Function.Arguments += delta.Function.Arguments
+			}
+		}
+	}
+
+	// A stream cut off by the server or a proxy is reported as a bad gateway, so that the request is retried:
+	if err := scanner.Err(); err != nil {
+		return nil, &Error{StatusCode: http.StatusBadGateway, Err: fmt.Errorf("%s: %w", ErrStream, err)}
+	}
+	if !complete {
+		return nil, &Error{StatusCode: http.StatusBadGateway, Err: fmt.Errorf("%s: %s", ErrStream, openAIIncompleteStream)}
+	}
+
+	if text.Len() > 0 {
+		response.Content = append(response.Content, NewText(text.String()))
+	}
+
+	indexes := make([]int, 0, len(toolCalls))
+	for index := range toolCalls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	for _, index := range indexes {
+		call := toolCalls[index]
+		arguments := call.Function.Arguments
+		if arguments == "" {
+			arguments = "{}"
+		}
+		response.Content = append(response.Content, NewToolUse(call.ID, call.Function.Name, json.RawMessage(arguments)))
+	}
+
+	p.logger.With("id", response.ID, "stop_reason", response.StopReason).Debug("Chat completion received.")
+
+	return response, nil
+}
+
+// openAIRequestBody converts the request to the format of the chat completions API.
+func openAIRequestBody(request Request) openAIRequest {
+	body := openAIRequest{
+		Model:         request.Model,
+		MaxTokens:     request.MaxTokens,
+		Temperature:   request.Temperature,
+		Stream:        true,
+		StreamOptions: openAIStreamOptions{IncludeUsage: true},
+	}
+
+	if request.System != "" {
+		body.Messages = append(body.Messages, openAIMessage{Role: "system", Content: request.System})
+	}
+
+	for _, message := range request.Messages {
+		body.Messages = append(body.Messages, openAIMessages(message)...)
+	}
+
+	for _, tool := range request.Tools {
+		body.Tools = append(body.Tools, openAITool{
+			Type: "function",
+			Function: openAIToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+
+	if len(request.Tools) > 0 && !request.ParallelToolUse {
+		parallel := false
+		body.ParallelToolCalls = &parallel
+	}
+
+	return body
+}
+
+// openAIMessages converts the message to the messages of the chat completions API. The tool results are sent as
+// separate messages with the tool role.
+func openAIMessages(message Message) []openAIMessage {
+	messages := []openAIMessage{}
+	converted := openAIMessage{Role: string(message.Role)}
+	text := []string{}
+
+	for _, content := range message.Content {
+		switch content.Type {
+		case ContentText:
+			if content.Text != "" {
+				text = append(text, content.Text)
+			}
+		case ContentToolUse:
+			converted.ToolCalls = append(converted.ToolCalls, openAIToolCall{
+				Index: len(converted.ToolCalls),
+				ID:    content.ToolUse.ID,
+				Type:  "function",
+				Function: openAIToolCallFunction{
+					Name:      content.ToolUse.Name,
+					Arguments: string(content.ToolUse.Input),
+				},
+			})
+		case ContentToolResult:
+			messages = append(messages, openAIMessage{
+				Role:       "tool",
+				Content:    content.ToolResult.Content,
+				ToolCallID: content.ToolResult.ToolUseID,
+			})
+		}
+	}
+
+	converted.Content = strings.Join(text, "\n")
+	if converted.Content != "" || len(converted.ToolCalls) > 0 {
+		messages = append(messages, converted)
+	}
+
+	return messages
+}
+
+// openAIStopReason maps the finish reason of the chat completions API to a stop reason.
+func openAIStopReason(reason string) StopReason {
+	if stopReason, ok := openAIStopReasons[reason]; ok {
+		return stopReason
+	}
+
+	return StopReason(reason)
+}
@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOpenAIStream is a minimal streamed response of the chat completions API.
+const testOpenAIStream = `data: {"id":"chatcmpl_1","model":"test-model","choices":[{"index":0,"delta":{"role":"assistant","content":"do"}}]}
+
+data: {"id":"chatcmpl_1","model":"test-model","choices":[{"index":0,"delta":{"content":"ne"}}]}
+
+data: {"id":"chatcmpl_1","model":"test-model","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"exec","arguments":""}}]}}]}
+
+data: {"id":"chatcmpl_1","model":"test-model","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"command\":"}}]}}]}
+
+data: {"id":"chatcmpl_1","model":"test-model","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":" \"ls\"}"}}]}}]}
+
+data: {"id":"chatcmpl_1","model":"test-model","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+
+data: {"id":"chatcmpl_1","model":"test-model","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":2,"prompt_tokens_details":{"cached_tokens":4}}}
+
+data: [DONE]
+
+`
+
+// newTestOpenAIServer creates a test server responding with the given status and body, recording the request.
+func newTestOpenAIServer(t *testing.T, status int, response string) (*httptest.Server, *http.Request, map[string]any) {
+	t.Helper()
+
+	request := &http.Request{}
+	body := map[string]any{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*request = *r
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, request, body
+}
+
+// TestOpenAIProvider_Send tests sending requests to OpenAI-compatible APIs
+func TestOpenAIProvider_Send(t *testing.T) {
+	t.Run("streams response", func(t *testing.T) {
+		server, request, _ := newTestOpenAIServer(t, http.StatusOK, testOpenAIStream)
+		provider := NewOpenAI(config.OpenAIConfiguration{BaseURL: server.URL + "/v1/", APIKey: "test-key"})
+
+		updates := []TextUpdate{}
+		response, err := provider.Send(context.Background(), testRequest(), func(update TextUpdate) {
+			updates = append(updates, update)
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "/v1/chat/completions", request.URL.Path)
+		assert.Equal(t, "Bearer test-key", request.Header.Get("Authorization"))
+
+		assert.Equal(t, []TextUpdate{{ResponseID: "chatcmpl_1", Index: 0, Text: "do"}, {ResponseID: "chatcmpl_1", Index: 0, Text: "done"}}, updates)
+		assert.Equal(t, "chatcmpl_1", response.ID)
+		assert.Equal(t, "test-model", response.Model)
+		assert.Equal(t, StopToolUse, response.StopReason)
+		assert.Equal(t, Usage{InputTokens: 6, OutputTokens: 2, CacheReadTokens: 4}, response.Usage)
+		require.Len(t, response.Content, 2)
+		assert.Equal(t, NewText("done"), response.Content[0])
+		assert.Equal(t, "call_1", response.Content[1].ToolUse.ID)
+		assert.Equal(t, "exec", response.Content[1].ToolUse.Name)
+		assert.JSONEq(t, `{"command": "ls"}`, string(response.Content[1].ToolUse.Input))
+	})
+
+	t.Run("converts request", func(t *testing.T) {
+		server, _, body := newTestOpenAIServer(t, http.StatusOK, "data: [DONE]\n\n")
+		provider := NewOpenAI(config.OpenAIConfiguration{BaseURL: server.URL})
+
+		_, err := provider.Send(context.Background(), testRequest(), nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, "test-model", body["model"])
+		assert.Equal(t, true, body["stream"])
+		assert.Equal(t, false, body["parallel_tool_calls"])
+		assert.Equal(t, 0.5, body["temperature"])
+
+		messages := body["messages"].([]any)
+		require.Len(t, messages, 4)
+		assert.Equal(t, map[string]any{"role": "system", "content": "You are a test."}, messages[0])
+		assert.Equal(t, map[string]any{"role": "user", "content": "list files"}, messages[1])
+		assert.Equal(t, "assistant", messages[2].(map[string]any)["role"])
+		assert.Equal(t, "Listing.", messages[2].(map[string]any)["content"])
+		assert.Len(t, messages[2].(map[string]any)["tool_calls"], 1)
+		assert.Equal(t, map[string]any{"role": "tool", "content": "file", "tool_call_id": "call_1"}, messages[3])
+
+		tools := body["tools"].([]any)
+		require.Len(t, tools, 1)
+		function := tools[0].(map[string]any)["function"].(map[string]any)
+		assert.Equal(t, "exec", function["name"])
+		assert.Equal(t, "object", function["parameters"].(map[string]any)["type"])
+	})
+
+	t.Run("returns API errors", func(t *testing.T) {
+		server, request, _ := newTestOpenAIServer(t, http.StatusServiceUnavailable, `{"error":{"message":"model is loading"}}`)
+		provider := NewOpenAI(config.OpenAIConfiguration{BaseURL: server.URL})
+
+		_, err := provider.Send(context.Background(), testRequest(), nil)
+
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+		assert.Contains(t, apiErr.Error(), "model is loading")
+		assert.Empty(t, request.Header.Get("Authorization"))
+	})
+
+	t.Run("returns stream errors", func(t *testing.T) {
+		server, _, _ := newTestOpenAIServer(t, http.StatusOK, "data: {\"error\":{\"message\":\"context length exceeded\"}}\n\n")
+		provider := NewOpenAI(config.OpenAIConfiguration{BaseURL: server.URL})
+
+		_, err := provider.Send(context.Background(), testRequest(), nil)
+		assert.ErrorContains(t, err, ErrStream)
+		assert.ErrorContains(t, err, "context length exceeded")
+	})
+
+	t.Run("returns retryable errors of truncated streams", func(t *testing.T) {
+		truncated := testOpenAIStream[:strings.Index(testOpenAIStream, `data: {"id":"chatcmpl_1","model":"test-model","choices":[{"index":0,"delta":{},"finish_reason"`)]
+		server, _, _ := newTestOpenAIServer(t, http.StatusOK, truncated)
+		provider := NewOpenAI(config.OpenAIConfiguration{BaseURL: server.URL})
+
+		_, err := provider.Send(context.Background(), testRequest(), nil)
+
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+		assert.ErrorContains(t, err, ErrStream)
+		assert.ErrorContains(t, err, "the stream ended before the response was complete")
+	})
+
+	t.Run("accepts streams ending with a finish reason", func(t *testing.T) {
+		server, _, _ := newTestOpenAIServer(t, http.StatusOK, testOpenAIStream[:strings.Index(testOpenAIStream, "data: [DONE]")])
+		provider := NewOpenAI(config.OpenAIConfiguration{BaseURL: server.URL})
+
+		response, err := provider.Send(context.Background(), testRequest(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, StopToolUse, response.StopReason)
+	})
+}
+
+// TestOpenAIStopReason tests mapping of the finish reasons
+func TestOpenAIStopReason(t *testing.T) {
+	assert.Equal(t, StopEndTurn, openAIStopReason("stop"))
+	assert.Equal(t, StopToolUse, openAIStopReason("tool_calls"))
+	assert.Equal(t, StopMaxTokens, openAIStopReason("length"))
+	assert.Equal(t, StopReason("content_filter"), openAIStopReason("content_filter"))
+}
@@ -0,0 +1,282 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// replayName is the display name of the replay provider.
+const replayName = "Replay"
+
+// Turn is a response of the model recorded in a transcript.
+type Turn struct {
+	// ID identifies the response; defaults to the position of the turn in the transcript.
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+	// Model is the model which generated the response.
+	Model string `json:"model,omitempty" yaml:"model,omitempty"`
+	// StopReason is the reason why the model stopped; defaults to tool_use if the turn calls tools, end_turn otherwise.
+	StopReason StopReason `json:"stop_reason,omitempty" yaml:"stop_reason,omitempty"`
+	// Content is the content of the response.
+	Content []TurnContent `json:"content" yaml:"content"`
+	// Usage is the token usage of the request.
+	Usage Usage `json:"usage,omitzero" yaml:"usage,omitempty"`
+}
+
+// TurnContent is a content block of a recorded response.
+type TurnContent struct {
+	// Type is the type of the content block, text or tool_use.
+	Type ContentType `json:"type" yaml:"type"`
+	// Text is the text of a text content block.
+	Text string `json:"text,omitempty" yaml:"text,omitempty"`
+	// ID identifies the tool call of a tool use content block.
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+	// Name is the name of the tool of a tool use content block.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Input is the input of the tool of a tool use content block.
+	Input map[string]any `json:"input,omitempty" yaml:"input,omitempty"`
+}
+
+// replayProvider is the provider replaying the turns of a transcript, one per request.
+type replayProvider struct {
+	mu     sync.Mutex
+	turns  []Turn
+	next   int
+	logger *slog.Logger
+}
+
+// recorder is the provider recording the responses of another provider to a transcript.
+type recorder struct {
+	mu       sync.Mutex
+	provider Provider
+	path     string
+	logger   *slog.Logger
+}
+
+// NewReplay creates a new provider replaying the given turns. The requests are ignored: each request is answered with
+// the next turn.
+func NewReplay(turns []Turn, opts ...Option) *replayProvider {
+	o := newOptions(opts...)
+
+	return &replayProvider{turns: turns, logger: o.logger}
+}
+
+// LoadTranscript loads the turns of a transcript. Files with a .yaml or .yml extension contain a YAML list of turns;
+// other files contain a JSON encoded turn per line.
+func LoadTranscript(path string) ([]Turn, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrReadTranscript, err)
+	}
+
+	turns := []Turn{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &turns); err != nil {
+			return nil, fmt.Errorf("%s: %w", ErrReadTranscript, err)
+		}
+	default:
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+
+			var turn Turn
+			if err := json.Unmarshal(scanner.Bytes(), &turn); err != nil {
+				return nil, fmt.Errorf("%s: line %d: %w", ErrReadTranscript, line, err)
+			}
+			turns = append(turns, turn)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", ErrReadTranscript, err)
+		}
+	}
+
+	return turns, nil
+}
+
+// Name returns the display name of the provider.
+func (p *replayProvider) Name() string {
+	return replayName
+}
+
+// Send returns the next turn of the transcript, passing its text blocks to the handler.
+func (p *replayProvider) Send(ctx context.Context, _ Request, handler StreamHandler) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if p.next >= len(p.turns) {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("%s: replayed %d turns", ErrTranscriptEnd, len(p.turns))
+	}
+	index := p.next
+	p.next++
+	p.mu.Unlock()
+
+	response, err := p.turns[index].response(index)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, content := range response.Content {
+		if content.Type == ContentText && handler != nil {
+			handler(TextUpdate{ResponseID: response.ID, Index: i, Text: content.Text})
+		}
+	}
+
+	p.logger.With("turn", index+1).With("id", response.ID).Debug("Transcript turn replayed.")
+
+	return response, nil
+}
+
+// NewRecorder creates a new provider sending the requests to the given provider and appending its responses to the
+// JSONL transcript at the given path.
+func NewRecorder(provider Provider, path string, opts ...Option) *recorder {
+	o := newOptions(opts...)
+
+	return &recorder{provider: provider, path: path, logger: o.logger}
+}
+
+// Name returns the display name of the recorded provider.
+func (r *recorder) Name() string {
+	return r.provider.Name()
+}
+
+// Send sends the request to the recorded provider and records its response. A response which cannot be recorded is
+// still returned, so that a failing recording does not stop the task.
+func (r *recorder) Send(ctx context.Context, request Request, handler StreamHandler) (*Response, error) {
+	response, err := r.provider.Send(ctx, request, handler)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.record(response); err != nil {
+		r.logger.With("error", err).With("path", r.path).Warn("Failed to record response.")
+	}
+
+	return response, nil
+}
+
+// record appends the response to the transcript.
+func (r *recorder) record(response *Response) error {
+	turn, err := newTurn(response)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ErrRecord, err)
+	}
+
+	line, err := json.Marshal(turn)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ErrRecord, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ErrRecord, err)
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", ErrRecord, err)
+	}
+
+	return nil
+}
+
+// newTurn converts the response to a turn of a transcript.
+func newTurn(response *Response) (Turn, error) {
+	turn := Turn{
+		ID:         response.ID,
+		Model:      response.Model,
+		StopReason: response.StopReason,
+		Content:    []TurnContent{},
+		Usage:      response.Usage,
+	}
+
+	for _, content := range response.Content {
+		switch content.Type {
+		case ContentText:
+			turn.Content = append(turn.Content, TurnContent{Type: ContentText, Text: content.Text})
+		case ContentToolUse:
+			input := map[string]any{}
+			if len(content.ToolUse.Input) > 0 {
+				if err := json.Unmarshal(content.ToolUse.Input, &input); err != nil {
+					return Turn{}, err
+				}
+			}
+			turn.Content = append(turn.Content, TurnContent{
+				Type:  ContentToolUse,
+				ID:    content.ToolUse.ID,
+				Name:  content.ToolUse.Name,
+				Input: input,
+			})
+		}
+	}
+
+	return turn, nil
+}
+
+// response converts the turn at the given position of the transcript to a response.
+func (t Turn) response(index int) (*Response, error) {
+	response := &Response{
+		ID:         t.ID,
+		Model:      t.Model,
+		StopReason: t.StopReason,
+		Usage:      t.Usage,
+	}
+	if response.ID == "" {
+		response.ID = fmt.Sprintf("replay_%d", index+1)
+	}
+
+	for i, content := range t.Content {
+		switch content.Type {
+		case ContentText:
+			response.Content = append(response.Content, NewText(content.Text))
+		case ContentToolUse:
+			input, err := json.Marshal(content.Input)
+			if err != nil {
+				return nil, fmt.Errorf("%s: turn %d: %w", ErrReadTranscript, index+1, err)
+			}
+			if content.Input == nil {
+				input = []byte("{}")
+			}
+
+			id := content.ID
+			if id == "" {
+				id = fmt.Sprintf("%s_tool_%d", response.ID, i)
+			}
+			response.Content = append(response.Content, NewToolUse(id, content.Name, input))
+		default:
+			return nil, fmt.Errorf("%s: turn %d: unsupported content type %q", ErrReadTranscript, index+1, content.Type)
+		}
+	}
+
+	if response.StopReason == "" {
+		response.StopReason = StopEndTurn
+		for _, content := range response.Content {
+			if content.Type == ContentToolUse {
+				response.StopReason = StopToolUse
+			}
+		}
+	}
+
+	return response, nil
+}
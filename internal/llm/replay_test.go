@@ -0,0 +1,179 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoadTranscript tests loading of the JSONL and YAML transcripts
+func TestLoadTranscript(t *testing.T) {
+	for _, path := range []string{"testdata/transcript.jsonl", "testdata/transcript.yaml"} {
+		t.Run(filepath.Ext(path), func(t *testing.T) {
+			turns, err := LoadTranscript(path)
+			require.NoError(t, err)
+			require.Len(t, turns, 2)
+
+			assert.Equal(t, "claude-3-7-sonnet-latest", turns[0].Model)
+			require.Len(t, turns[0].Content, 2)
+			assert.Equal(t, TurnContent{Type: ContentText, Text: "Listing the files."}, turns[0].Content[0])
+			assert.Equal(t, "exec", turns[0].Content[1].Name)
+			assert.Equal(t, map[string]any{"command": "ls"}, turns[0].Content[1].Input)
+			assert.Equal(t, "The directory contains a single file.", turns[1].Content[0].Text)
+		})
+	}
+
+	t.Run("returns error for missing file", func(t *testing.T) {
+		_, err := LoadTranscript(filepath.Join(t.TempDir(), "missing.jsonl"))
+		assert.ErrorContains(t, err, ErrReadTranscript)
+	})
+
+	t.Run("returns error for invalid line", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "invalid.jsonl")
+		require.NoError(t, os.WriteFile(path, []byte("{\"content\":[]}\nnot json\n"), 0600))
+
+		_, err := LoadTranscript(path)
+		assert.ErrorContains(t, err, "line 2")
+	})
+}
+
+// TestReplayProvider_Send tests replaying of the transcript turns
+func TestReplayProvider_Send(t *testing.T) {
+	t.Run("replays turns in order", func(t *testing.T) {
+		turns, err := LoadTranscript("testdata/transcript.yaml")
+		require.NoError(t, err)
+		provider := NewReplay(turns)
+
+		updates := []TextUpdate{}
+		handler := func(update TextUpdate) { updates = append(updates, update) }
+
+		first, err := provider.Send(context.Background(), Request{}, handler)
+		require.NoError(t, err)
+		assert.Equal(t, "replay_1", first.ID)
+		assert.Equal(t, StopToolUse, first.StopReason)
+		require.Len(t, first.Content, 2)
+		assert.Equal(t, "replay_1_tool_1", first.Content[1].ToolUse.ID)
+		assert.JSONEq(t, `{"command":"ls"}`, string(first.Content[1].ToolUse.Input))
+
+		second, err := provider.Send(context.Background(), Request{}, handler)
+		require.NoError(t, err)
+		assert.Equal(t, StopEndTurn, second.StopReason)
+
+		assert.Equal(t, []TextUpdate{
+			{ResponseID: "replay_1", Index: 0, Text: "Listing the files."},
+			{ResponseID: "replay_2", Index: 0, Text: "The directory contains a single file."},
+		}, updates)
+
+		_, err = provider.Send(context.Background(), Request{}, handler)
+		assert.ErrorContains(t, err, ErrTranscriptEnd)
+	})
+
+	t.Run("returns error for unsupported content", func(t *testing.T) {
+		provider := NewReplay([]Turn{{Content: []TurnContent{{Type: ContentToolResult}}}})
+
+		_, err := provider.Send(context.Background(), Request{}, nil)
+		assert.ErrorContains(t, err, "unsupported content type")
+	})
+
+	t.Run("returns error when context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := NewReplay([]Turn{{}}).Send(ctx, Request{}, nil)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+// TestRecorder tests recording of the responses into a replayable transcript
+func TestRecorder(t *testing.T) {
+	source, err := LoadTranscript("testdata/transcript.jsonl")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "recorded.jsonl")
+	recorder := NewRecorder(NewReplay(source), path)
+	assert.Equal(t, "Replay", recorder.Name())
+
+	responses := []*Response{}
+	for range source {
+		response, err := recorder.Send(context.Background(), Request{}, nil)
+		require.NoError(t, err)
+		responses = append(responses, response)
+	}
+
+	_, err = recorder.Send(context.Background(), Request{}, nil)
+	assert.ErrorContains(t, err, ErrTranscriptEnd)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	recorded, err := LoadTranscript(path)
+	require.NoError(t, err)
+	require.Len(t, recorded, len(source))
+
+	replay := NewReplay(recorded)
+	for _, expected := range responses {
+		response, err := replay.Send(context.Background(), Request{}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, expected.ID, response.ID)
+		assert.Equal(t, expected.Model, response.Model)
+		assert.Equal(t, expected.StopReason, response.StopReason)
+		assert.Equal(t, expected.Usage, response.Usage)
+		require.Len(t, response.Content, len(expected.Content))
+		for i, content := range expected.Content {
+			if content.Type == ContentToolUse {
+				assert.Equal(t, content.ToolUse.ID, response.Content[i].ToolUse.ID)
+				assert.JSONEq(t, string(content.ToolUse.Input), string(response.Content[i].ToolUse.Input))
+				continue
+			}
+			assert.Equal(t, content, response.Content[i])
+		}
+	}
+}
+
+// TestNew_Replay tests the creation of the replay and recording providers from the configuration
+func TestNew_Replay(t *testing.T) {
+	t.Run("creates replay provider", func(t *testing.T) {
+		cfg := config.Configuration{LLM: config.LLMConfiguration{
+			Provider: config.ProviderReplay,
+			Replay:   config.ReplayConfiguration{Path: "testdata/transcript.jsonl"},
+		}}
+
+		provider, err := New(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "Replay", provider.Name())
+		assert.Equal(t, Model{Provider: "Replay", Name: "transcript.jsonl"}, ModelFromConfig(cfg))
+	})
+
+	t.Run("returns error for missing transcript", func(t *testing.T) {
+		_, err := New(config.Configuration{LLM: config.LLMConfiguration{
+			Provider: config.ProviderReplay,
+			Replay:   config.ReplayConfiguration{Path: filepath.Join(t.TempDir(), "missing.jsonl")},
+		}})
+		assert.ErrorContains(t, err, ErrReadTranscript)
+	})
+
+	t.Run("records responses", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "recorded.jsonl")
+		provider, err := New(config.Configuration{LLM: config.LLMConfiguration{
+			Provider: config.ProviderReplay,
+			Replay:   config.ReplayConfiguration{Path: "testdata/transcript.jsonl"},
+			Record:   path,
+		}})
+		require.NoError(t, err)
+
+		_, err = provider.Send(context.Background(), Request{}, nil)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var turn Turn
+		require.NoError(t, json.Unmarshal(data, &turn))
+		assert.Equal(t, "msg_1", turn.ID)
+	})
+}
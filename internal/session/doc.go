@@ -0,0 +1,53 @@
+/*
+Package session provides the persisted sessions of the opsy application, so that a task interrupted by Ctrl+C, a
+crash or an API error can be resumed.
+
+A Session holds the task and its follow-up instructions, the messages of the conversation with the LLM provider,
+the outputs of the tools, the executed commands and the status of the last run. Each session is stored as
+session.json in its own directory under sessions.path (~/.opsy/sessions by default), readable only by the user,
+since the conversation may contain the output of the commands.
+
+# Store
+
+The Store creates, saves, loads and lists the sessions:
+
+	store := session.New(session.WithConfig(cfg), session.WithLogger(logger))
+	s, err := store.Create(task)
+	// ...
+	s, err = store.Load(id)
+	sessions, err := store.List()
+
+Session IDs start with the creation time, e.g. 20250101-120000-a1b2c3, followed by a random suffix. Sessions are
+saved to a temporary file which then replaces the session file, so that a crash never leaves a partially written
+session. List returns the most recently updated sessions first and skips the sessions which cannot be loaded.
+
+# Recording
+
+A Recorder saves the session after every change as the runs progress:
+
+  - Start: A run of the task or a follow-up instruction started
+  - SetMessages: The conversation was updated, e.g. from an agent.WithSaveHandler
+  - AddCommands: Commands were executed
+  - Finish: The run finished, with its outputs and error
+  - Interrupt: The running task was interrupted by the user
+
+The agent updates the conversation after every complete exchange, so a resumed session continues from the last
+completed tool call. A session whose process crashed keeps the Running status. Errors saving the session are
+logged, so that they do not stop the task, and all the methods of a nil Recorder do nothing.
+
+# Output
+
+WriteList writes a line per session with its ID, status, last update and task, and WriteDetails writes the tasks,
+commands and conversation of a session, for the `opsy sessions list` and `opsy sessions show` commands.
+
+# Error Handling
+
+The package defines the following errors:
+
+  - ErrCreateSession: The session could not be created
+  - ErrSaveSession: The session could not be saved
+  - ErrLoadSession: The session could not be read or decoded
+  - ErrSessionNotFound: There is no session with the given ID
+  - ErrListSessions: The directory of the sessions could not be read
+*/
+package session
@@ -0,0 +1,364 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datolabs-io/opsy/internal/agent"
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/datolabs-io/opsy/internal/llm"
+	"github.com/datolabs-io/opsy/internal/tool"
+)
+
+const (
+	// ErrCreateSession is the error returned when a session cannot be created.
+	ErrCreateSession = "failed to create session"
+	// ErrSaveSession is the error returned when a session cannot be saved.
+	ErrSaveSession = "failed to save session"
+	// ErrLoadSession is the error returned when a session cannot be loaded.
+	ErrLoadSession = "failed to load session"
+	// ErrSessionNotFound is the error returned when there is no session with the given ID.
+	ErrSessionNotFound = "session not found"
+	// ErrListSessions is the error returned when the sessions cannot be listed.
+	ErrListSessions = "failed to list sessions"
+
+	// StatusInterrupted is the status of a session whose run was interrupted, e.g. by Ctrl+C.
+	StatusInterrupted = "Interrupted"
+
+	// fileName is the name of the file of a session in its directory.
+	fileName = "session.json"
+	// idTimeFormat is the format of the time at the start of the session IDs.
+	idTimeFormat = "20060102-150405"
+	// maxResultLength is the maximum length of the tool results shown by WriteDetails.
+	maxResultLength = 200
+)
+
+// Session is the persisted state of the runs of a task and its follow-ups.
+type Session struct {
+	// ID identifies the session.
+	ID string `json:"id"`
+	// Task is the task which started the session.
+	Task string `json:"task"`
+	// Tasks are the task and the follow-up instructions run in the session, in order.
+	Tasks []string `json:"tasks"`
+	// Status is the status of the last run: Running, Finished, Error or Interrupted.
+	Status string `json:"status"`
+	// Error is the error the last run failed with, if any.
+	Error string `json:"error,omitempty"`
+	// CreatedAt is the time the session was created.
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt is the time the session was last saved.
+	UpdatedAt time.Time `json:"updated_at"`
+	// Messages are the messages of the conversation with the LLM provider.
+	Messages []llm.Message `json:"messages"`
+	// Outputs are the outputs of the tools executed by the agent.
+	Outputs []tool.Output `json:"outputs"`
+	// Commands are the commands executed by the agent and its tools, in order.
+	Commands []tool.Command `json:"commands"`
+}
+
+// Store persists the sessions, each in its own directory.
+type Store struct {
+	path   string
+	logger *slog.Logger
+}
+
+// Option is a function that configures the Store.
+type Option func(*Store)
+
+// Recorder updates a session as its runs progress and saves it after every change. It is safe for concurrent use.
+// All the methods of a nil Recorder do nothing, so that callers need not check whether sessions are enabled.
+type Recorder struct {
+	mu      sync.Mutex
+	store   *Store
+	session *Session
+}
+
+// New creates a new Store.
+func New(opts ...Option) *Store {
+	s := &Store{
+		path:   config.New().GetConfig().Sessions.Path,
+		logger: slog.New(slog.DiscardHandler),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// WithConfig sets the directory of the sessions from the configuration.
+func WithConfig(cfg config.Configuration) Option {
+	return func(s *Store) {
+		s.path = cfg.Sessions.Path
+	}
+}
+
+// WithLogger sets the logger for the Store.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Store) {
+		s.logger = logger.With("component", "session")
+	}
+}
+
+// Create creates and saves a new session for the task.
+func (s *Store) Create(task string) (*Session, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrCreateSession, err)
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:        fmt.Sprintf("%s-%s", now.Format(idTimeFormat), hex.EncodeToString(suffix)),
+		Task:      task,
+		Tasks:     []string{},
+		Status:    agent.StatusReady,
+		CreatedAt: now,
+		Messages:  []llm.Message{},
+		Outputs:   []tool.Output{},
+		Commands:  []tool.Command{},
+	}
+
+	if err := s.Save(session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Save saves the session, replacing the file atomically so that a crash never leaves a partially written session.
+func (s *Store) Save(session *Session) error {
+	dir := filepath.Join(s.path, session.ID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("%s: %w", ErrSaveSession, err)
+	}
+
+	session.UpdatedAt = time.Now()
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ErrSaveSession, err)
+	}
+
+	file, err := os.CreateTemp(dir, fileName+".*")
+	if err != nil {
+		return fmt.Errorf("%s: %w", ErrSaveSession, err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filepath.Join(dir, fileName))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", ErrSaveSession, err)
+	}
+
+	return nil
+}
+
+// Load loads the session with the given ID.
+func (s *Store) Load(id string) (*Session, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("%s: %q", ErrSessionNotFound, id)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.path, id, fileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %q", ErrSessionNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrLoadSession, err)
+	}
+
+	session := &Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", ErrLoadSession, id, err)
+	}
+
+	return session, nil
+}
+
+// List returns the sessions, the most recently updated first. Sessions which cannot be loaded are logged and skipped.
+func (s *Store) List() ([]*Session, error) {
+	entries, err := os.ReadDir(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []*Session{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrListSessions, err)
+	}
+
+	sessions := []*Session{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		session, err := s.Load(entry.Name())
+		if err != nil {
+			s.logger.With("error", err).With("session", entry.Name()).Warn("Failed to load session, skipping.")
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	slices.SortFunc(sessions, func(a, b *Session) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+
+	return sessions, nil
+}
+
+// NewRecorder creates a new Recorder of the session.
+func NewRecorder(store *Store, session *Session) *Recorder {
+	return &Recorder{store: store, session: session}
+}
+
+// Start records the start of a run of the task.
+func (r *Recorder) Start(task string) {
+	r.update(func(session *Session) {
+		session.Tasks = append(session.Tasks, task)
+		session.Status = agent.StatusRunning
+		session.Error = ""
+	})
+}
+
+// SetMessages records the messages of the conversation.
+func (r *Recorder) SetMessages(messages []llm.Message) {
+	r.update(func(session *Session) {
+		session.Messages = messages
+	})
+}
+
+// AddCommands records the executed commands.
+func (r *Recorder) AddCommands(commands ...tool.Command) {
+	r.update(func(session *Session) {
+		session.Commands = append(session.Commands, commands...)
+	})
+}
+
+// Finish records the end of a run with its outputs and error.
+func (r *Recorder) Finish(outputs []tool.Output, err error) {
+	r.update(func(session *Session) {
+		session.Outputs = append(session.Outputs, outputs...)
+		session.Status = agent.StatusFinished
+		if err != nil {
+			session.Status = agent.StatusError
+			session.Error = err.Error()
+		}
+	})
+}
+
+// Interrupt records that the running task was interrupted. Sessions which are not running are left unchanged.
+func (r *Recorder) Interrupt() {
+	r.update(func(session *Session) {
+		if session.Status == agent.StatusRunning {
+			session.Status = StatusInterrupted
+		}
+	})
+}
+
+// update applies the change to the session and saves it. Errors are logged, so that a failing session does not
+// stop the task.
+func (r *Recorder) update(change func(session *Session)) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	change(r.session)
+	if err := r.store.Save(r.session); err != nil {
+		r.store.logger.With("error", err).With("session", r.session.ID).Error("Failed to save session.")
+	}
+}
+
+// WriteList writes a line per session with its ID, status, last update and task.
+func WriteList(w io.Writer, sessions []*Session) {
+	if len(sessions) == 0 {
+		fmt.Fprintln(w, "No sessions.")
+		return
+	}
+
+	for _, session := range sessions {
+		fmt.Fprintf(w, "%s  %-11s  %s  %s\n", session.ID, session.Status, session.UpdatedAt.Format(time.DateTime),
+			session.Task)
+	}
+}
+
+// WriteDetails writes the status, tasks, commands and conversation of the session.
+func WriteDetails(w io.Writer, session *Session) {
+	fmt.Fprintf(w, "ID: %s\n", session.ID)
+	fmt.Fprintf(w, "Status: %s\n", session.Status)
+	if session.Error != "" {
+		fmt.Fprintf(w, "Error: %s\n", session.Error)
+	}
+	fmt.Fprintf(w, "Created: %s\n", session.CreatedAt.Format(time.DateTime))
+	fmt.Fprintf(w, "Updated: %s\n", session.UpdatedAt.Format(time.DateTime))
+
+	fmt.Fprintf(w, "\nTasks: %d\n", len(session.Tasks))
+	for i, task := range session.Tasks {
+		fmt.Fprintf(w, "%3d. %s\n", i+1, task)
+	}
+
+	fmt.Fprintf(w, "\nCommands: %d\n", len(session.Commands))
+	for i, cmd := range session.Commands {
+		fmt.Fprintf(w, "%3d. $ %s [%s] (%s)\n", i+1, cmd.Command, cmd.Location(), outcome(cmd))
+	}
+
+	fmt.Fprintf(w, "\nMessages: %d\n", len(session.Messages))
+	for _, message := range session.Messages {
+		for _, content := range message.Content {
+			switch content.Type {
+			case llm.ContentText:
+				fmt.Fprintf(w, "[%s] %s\n", message.Role, content.Text)
+			case llm.ContentToolUse:
+				fmt.Fprintf(w, "[%s] %s %s\n", message.Role, content.ToolUse.Name, content.ToolUse.Input)
+			case llm.ContentToolResult:
+				fmt.Fprintf(w, "[%s] result: %s\n", message.Role, shorten(content.ToolResult.Content))
+			}
+		}
+	}
+}
+
+// outcome returns how the command ended: its exit code, or whether it timed out or was cancelled.
+func outcome(cmd tool.Command) string {
+	switch {
+	case cmd.TimedOut:
+		return "timed out"
+	case cmd.Cancelled:
+		return "cancelled"
+	default:
+		return fmt.Sprintf("exit %d", cmd.ExitCode)
+	}
+}
+
+// shorten returns the first line of the text, cut to the maximum length of the tool results.
+func shorten(text string) string {
+	line, _, multiline := strings.Cut(strings.TrimSpace(text), "\n")
+	if runes := []rune(line); len(runes) > maxResultLength {
+		return string(runes[:maxResultLength]) + "…"
+	}
+	if multiline {
+		return line + " …"
+	}
+
+	return line
+}
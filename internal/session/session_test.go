@@ -0,0 +1,201 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/datolabs-io/opsy/internal/agent"
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/datolabs-io/opsy/internal/llm"
+	"github.com/datolabs-io/opsy/internal/tool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStore creates a store in a temporary directory.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	cfg := config.Configuration{Sessions: config.SessionsConfiguration{Enabled: true, Path: filepath.Join(t.TempDir(), "sessions")}}
+	return New(WithConfig(cfg))
+}
+
+// TestStore tests creating, saving, loading and listing sessions.
+func TestStore(t *testing.T) {
+	t.Run("creates and loads a session", func(t *testing.T) {
+		store := newTestStore(t)
+		session, err := store.Create("list files")
+		require.NoError(t, err)
+		assert.Regexp(t, `^\d{8}-\d{6}-[0-9a-f]{6}$`, session.ID)
+		assert.Equal(t, agent.StatusReady, session.Status)
+
+		info, err := os.Stat(filepath.Join(store.path, session.ID, fileName))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		loaded, err := store.Load(session.ID)
+		require.NoError(t, err)
+		assert.Equal(t, session.Task, loaded.Task)
+		assert.True(t, session.CreatedAt.Equal(loaded.CreatedAt))
+	})
+
+	t.Run("saves the conversation", func(t *testing.T) {
+		store := newTestStore(t)
+		session, err := store.Create("list files")
+		require.NoError(t, err)
+
+		session.Messages = []llm.Message{
+			{Role: llm.RoleUser, Content: []llm.Content{llm.NewText("list files")}},
+			{Role: llm.RoleAssistant, Content: []llm.Content{llm.NewToolUse("toolu_1", "exec", []byte(`{"command":"ls"}`))}},
+			{Role: llm.RoleUser, Content: []llm.Content{llm.NewToolResult("toolu_1", "file", false)}},
+		}
+		require.NoError(t, store.Save(session))
+
+		loaded, err := store.Load(session.ID)
+		require.NoError(t, err)
+		assert.Equal(t, session.Messages, loaded.Messages)
+	})
+
+	t.Run("returns error for unknown sessions", func(t *testing.T) {
+		store := newTestStore(t)
+		for _, id := range []string{"missing", "", "..", "../other", "."} {
+			_, err := store.Load(id)
+			assert.ErrorContains(t, err, ErrSessionNotFound, id)
+		}
+	})
+
+	t.Run("returns error for corrupted sessions", func(t *testing.T) {
+		store := newTestStore(t)
+		require.NoError(t, os.MkdirAll(filepath.Join(store.path, "broken"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(store.path, "broken", fileName), []byte("{"), 0600))
+
+		_, err := store.Load("broken")
+		assert.ErrorContains(t, err, ErrLoadSession)
+	})
+
+	t.Run("lists the most recent sessions first", func(t *testing.T) {
+		store := newTestStore(t)
+		sessions, err := store.List()
+		require.NoError(t, err)
+		assert.Empty(t, sessions)
+
+		first, err := store.Create("first")
+		require.NoError(t, err)
+		second, err := store.Create("second")
+		require.NoError(t, err)
+		first.UpdatedAt = time.Now()
+		time.Sleep(time.Millisecond)
+		require.NoError(t, store.Save(first))
+		require.NoError(t, os.MkdirAll(filepath.Join(store.path, "broken"), 0700))
+
+		sessions, err = store.List()
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+		assert.Equal(t, first.ID, sessions[0].ID)
+		assert.Equal(t, second.ID, sessions[1].ID)
+	})
+}
+
+// TestRecorder tests recording the progress of the runs of a session.
+func TestRecorder(t *testing.T) {
+	t.Run("records the runs", func(t *testing.T) {
+		store := newTestStore(t)
+		session, err := store.Create("list files")
+		require.NoError(t, err)
+		recorder := NewRecorder(store, session)
+
+		recorder.Start("list files")
+		loaded, err := store.Load(session.ID)
+		require.NoError(t, err)
+		assert.Equal(t, agent.StatusRunning, loaded.Status)
+
+		messages := []llm.Message{{Role: llm.RoleUser, Content: []llm.Content{llm.NewText("list files")}}}
+		recorder.SetMessages(messages)
+		recorder.AddCommands(tool.Command{Command: "ls", ExitCode: 0})
+		recorder.Finish([]tool.Output{{Tool: "exec", Result: "file"}}, nil)
+		recorder.Start("now count them")
+		recorder.Finish(nil, errors.New("boom"))
+
+		loaded, err = store.Load(session.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"list files", "now count them"}, loaded.Tasks)
+		assert.Equal(t, messages, loaded.Messages)
+		assert.Equal(t, []tool.Command{{Command: "ls"}}, loaded.Commands)
+		assert.Len(t, loaded.Outputs, 1)
+		assert.Equal(t, agent.StatusError, loaded.Status)
+		assert.Equal(t, "boom", loaded.Error)
+	})
+
+	t.Run("marks running sessions as interrupted", func(t *testing.T) {
+		store := newTestStore(t)
+		session, err := store.Create("list files")
+		require.NoError(t, err)
+		recorder := NewRecorder(store, session)
+
+		recorder.Start("list files")
+		recorder.Interrupt()
+		assert.Equal(t, StatusInterrupted, session.Status)
+
+		recorder.Finish(nil, nil)
+		recorder.Interrupt()
+		assert.Equal(t, agent.StatusFinished, session.Status)
+	})
+
+	t.Run("nil recorder does nothing", func(t *testing.T) {
+		var recorder *Recorder
+		assert.NotPanics(t, func() {
+			recorder.Start("list files")
+			recorder.SetMessages(nil)
+			recorder.AddCommands(tool.Command{})
+			recorder.Finish(nil, nil)
+			recorder.Interrupt()
+		})
+	})
+}
+
+// TestWrite tests writing the sessions for the sessions commands.
+func TestWrite(t *testing.T) {
+	session := &Session{
+		ID:        "20250101-120000-abcdef",
+		Task:      "list files",
+		Tasks:     []string{"list files"},
+		Status:    agent.StatusError,
+		Error:     "boom",
+		CreatedAt: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2025, 1, 1, 12, 5, 0, 0, time.UTC),
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: []llm.Content{llm.NewText("list files")}},
+			{Role: llm.RoleAssistant, Content: []llm.Content{llm.NewToolUse("toolu_1", "exec", []byte(`{"command":"ls"}`))}},
+			{Role: llm.RoleUser, Content: []llm.Content{llm.NewToolResult("toolu_1", "a\nb", false)}},
+		},
+		Commands: []tool.Command{
+			{Command: "ls", WorkingDirectory: "/tmp"},
+			{Command: "sleep 60", WorkingDirectory: "/tmp", ExitCode: -1, TimedOut: true},
+		},
+	}
+
+	t.Run("writes the list", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		WriteList(buf, []*Session{session})
+		assert.Equal(t, "20250101-120000-abcdef  Error        2025-01-01 12:05:00  list files\n", buf.String())
+
+		buf.Reset()
+		WriteList(buf, nil)
+		assert.Equal(t, "No sessions.\n", buf.String())
+	})
+
+	t.Run("writes the details", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		WriteDetails(buf, session)
+		assert.Contains(t, buf.String(), "Status: Error\nError: boom\n")
+		assert.Contains(t, buf.String(), "  1. $ ls [/tmp] (exit 0)\n")
+		assert.Contains(t, buf.String(), "  2. $ sleep 60 [/tmp] (timed out)\n")
+		assert.Contains(t, buf.String(), "[user] list files\n")
+		assert.Contains(t, buf.String(), `[assistant] exec {"command":"ls"}`)
+		assert.Contains(t, buf.String(), "[user] result: a …\n")
+	})
+}
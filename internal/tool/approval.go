@@ -0,0 +1,65 @@
+package tool
+
+import (
+	"context"
+)
+
+// Decision is the decision made by the user on a proposed command.
+type Decision string
+
+const (
+	// DecisionApproved is the decision when the command is approved as proposed.
+	DecisionApproved Decision = "approved"
+	// DecisionRejected is the decision when the command is rejected.
+	DecisionRejected Decision = "rejected"
+	// DecisionEdited is the decision when the command is approved after being edited.
+	DecisionEdited Decision = "edited"
+)
+
+// Approval is the answer to an approval request.
+type Approval struct {
+	// Decision is the decision made on the command.
+	Decision Decision
+	// Command is the command to execute when the decision is DecisionEdited.
+	Command string
+}
+
+// ApprovalRequest is a request to approve a command before it is executed.
+type ApprovalRequest struct {
+	// Command is the command proposed for execution.
+	Command Command
+	// Response is the channel the approval must be sent to.
+	Response chan Approval
+	// Done is closed once the approval is no longer awaited, e.g. because the task was cancelled, so that the request
+	// can be dismissed. It is nil if the request never expires.
+	Done <-chan struct{}
+}
+
+// Approver is the interface for approving commands before they are executed.
+type Approver interface {
+	// Approve blocks until a decision on the command is made.
+	Approve(ctx context.Context, cmd Command) (Approval, error)
+}
+
+// approverKey is the context key for the approver.
+type approverKey struct{}
+
+// WithApprover returns a copy of the context that carries the given approver.
+func WithApprover(ctx context.Context, approver Approver) context.Context {
+	return context.WithValue(ctx, approverKey{}, approver)
+}
+
+// ApproverFromContext returns the approver carried by the context, if any.
+func ApproverFromContext(ctx context.Context) (Approver, bool) {
+	approver, ok := ctx.Value(approverKey{}).(Approver)
+	return approver, ok
+}
+
+// NewApprovalRequest creates a new approval request for the command, awaited until the context is done.
+func NewApprovalRequest(ctx context.Context, cmd Command) ApprovalRequest {
+	return ApprovalRequest{
+		Command:  cmd,
+		Response: make(chan Approval, 1),
+		Done:     ctx.Done(),
+	}
+}
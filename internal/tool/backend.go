@@ -0,0 +1,68 @@
+package tool
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/datolabs-io/opsy/internal/config"
+)
+
+// Backend creates the processes executing the shell commands of the exec tool.
+type Backend interface {
+	// Command returns the process executing the shell command in the given working directory, with the environment
+	// variables, as NAME=value, added to its environment. It returns an error if the backend cannot execute commands,
+	// e.g. because its executable is missing.
+	Command(ctx context.Context, command, workingDirectory string, env []string) (*exec.Cmd, error)
+}
+
+// hostBackend executes the commands with the shell of the host, in the sandbox if it is enabled.
+type hostBackend struct {
+	shell   string
+	sandbox *Sandbox
+}
+
+// newBackend creates the backend selected by tools.exec.backend. The sandbox only applies to the host backend.
+func newBackend(cfg config.ExecToolConfiguration, sandbox config.SandboxConfiguration) Backend {
+	switch cfg.Backend {
+	case config.BackendContainer:
+		return NewContainerBackend(cfg.Container)
+	default:
+		return &hostBackend{shell: cfg.Shell, sandbox: NewSandbox(sandbox)}
+	}
+}
+
+// Command returns the process executing the shell command on the host. The environment variables are passed to the
+// sandbox as well.
+func (b *hostBackend) Command(ctx context.Context, command, workingDirectory string, env []string) (*exec.Cmd, error) {
+	return b.command(ctx, command, workingDirectory, env, false)
+}
+
+// terminalCommand returns the process executing the interactive shell command on the host, which keeps the
+// controlling terminal of its session in the sandbox.
+func (b *hostBackend) terminalCommand(ctx context.Context, command, workingDirectory string, env []string) (*exec.Cmd, error) {
+	return b.command(ctx, command, workingDirectory, env, true)
+}
+
+// command returns the process executing the shell command on the host, in the sandbox if it is enabled.
+func (b *hostBackend) command(ctx context.Context, command, workingDirectory string, env []string, interactive bool) (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+	if b.sandbox != nil {
+		var err error
+		sandboxCommand := b.sandbox.Command
+		if interactive {
+			sandboxCommand = b.sandbox.TerminalCommand
+		}
+		if cmd, err = sandboxCommand(ctx, b.shell, command, workingDirectory); err != nil {
+			return nil, err
+		}
+	} else {
+		cmd = exec.CommandContext(ctx, b.shell, "-c", command)
+		cmd.Dir = workingDirectory
+	}
+
+	if len(env) > 0 {
+		cmd.Env = append(cmd.Environ(), env...)
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,90 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// ErrCancelled is the cause of the contexts of the commands and tasks cancelled by the user.
+	ErrCancelled = "cancelled by the user"
+
+	// ErrTimedOut is the cause of the contexts of the commands terminated because they exceeded the timeout.
+	ErrTimedOut = "command timed out"
+
+	// terminationGracePeriod is the time a cancelled or timed out command has to exit after SIGTERM before it is killed.
+	terminationGracePeriod = 5 * time.Second
+)
+
+var (
+	// errCancelled is the cause of the contexts cancelled by the user.
+	errCancelled = errors.New(ErrCancelled)
+	// errTimedOut is the cause of the contexts of the commands which exceeded the timeout.
+	errTimedOut = errors.New(ErrTimedOut)
+	// errCommandFinished is the cause of the contexts of the commands which have returned.
+	errCommandFinished = errors.New("command finished")
+)
+
+// CancelByUser cancels the context of the cancel function on behalf of the user.
+func CancelByUser(cancel context.CancelCauseFunc) {
+	cancel(errCancelled)
+}
+
+// CancelledByUser returns true if the context, or one of its parents, was cancelled on behalf of the user.
+func CancelledByUser(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errCancelled)
+}
+
+// processGroup is the process group of a command, killed once the grace period after SIGTERM has elapsed.
+type processGroup struct {
+	mu      sync.Mutex
+	kill    *time.Timer
+	stopped bool
+}
+
+// terminateProcessGroup runs the command in its own process group, which is terminated as a whole when the context of
+// the command is done: the group is sent SIGTERM, then SIGKILL if it is still running after the grace period. The
+// command returns at the latest once twice the grace period has elapsed, even if a process keeps its output open. The
+// returned group must be stopped once the command has returned, so that SIGKILL is not sent to a group which is gone.
+func terminateProcessGroup(cmd *exec.Cmd, grace time.Duration) *processGroup {
+	g := &processGroup{}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.WaitDelay = 2 * grace
+	cmd.Cancel = func() error {
+		group := -cmd.Process.Pid
+		if err := syscall.Kill(group, syscall.SIGTERM); err != nil {
+			if errors.Is(err, syscall.ESRCH) {
+				return os.ErrProcessDone
+			}
+			return err
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if !g.stopped {
+			g.kill = time.AfterFunc(grace, func() {
+				_ = syscall.Kill(group, syscall.SIGKILL)
+			})
+		}
+
+		return nil
+	}
+
+	return g
+}
+
+// stop cancels the SIGKILL of the group, if it is scheduled. It is called once the command has returned.
+func (g *processGroup) stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.stopped = true
+	if g.kill != nil {
+		g.kill.Stop()
+	}
+}
@@ -0,0 +1,159 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cancellingOutputHandler cancels the running command once it has written the given line.
+type cancellingOutputHandler struct {
+	line   string
+	cancel func()
+}
+
+func (h *cancellingOutputHandler) HandleOutput(ctx context.Context, output CommandOutput) {
+	if output.Started {
+		h.cancel = output.Cancel
+		return
+	}
+
+	if output.Line == h.line {
+		h.cancel()
+	}
+}
+
+// TestCancelledByUser tests the cancellation of contexts on behalf of the user
+func TestCancelledByUser(t *testing.T) {
+	t.Run("detects the cancellation of the context and its children", func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		child, cancelChild := context.WithCancel(ctx)
+		defer cancelChild()
+
+		assert.False(t, CancelledByUser(ctx))
+		CancelByUser(cancel)
+		assert.True(t, CancelledByUser(ctx))
+		assert.True(t, CancelledByUser(child))
+	})
+
+	t.Run("ignores other cancellations", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+
+		assert.False(t, CancelledByUser(ctx))
+	})
+}
+
+// TestTerminateProcessGroup tests the termination of the process group of cancelled commands
+func TestTerminateProcessGroup(t *testing.T) {
+	t.Run("terminates the processes started by the command", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", "sleep 30 & echo started; wait")
+		group := terminateProcessGroup(cmd, time.Second)
+		defer group.stop()
+		stdout, err := cmd.StdoutPipe()
+		require.NoError(t, err)
+		require.NoError(t, cmd.Start())
+
+		buffer := make([]byte, len("started"))
+		_, err = stdout.Read(buffer)
+		require.NoError(t, err)
+		startedAt := time.Now()
+		cancel()
+
+		assert.Error(t, cmd.Wait())
+		assert.Less(t, time.Since(startedAt), time.Second)
+	})
+
+	t.Run("kills the processes ignoring SIGTERM after the grace period", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", "trap '' TERM; sleep 30")
+		group := terminateProcessGroup(cmd, 100*time.Millisecond)
+		defer group.stop()
+		require.NoError(t, cmd.Start())
+
+		time.Sleep(100 * time.Millisecond)
+		startedAt := time.Now()
+		cancel()
+
+		assert.Error(t, cmd.Wait())
+		elapsed := time.Since(startedAt)
+		assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond)
+		assert.Less(t, elapsed, 5*time.Second)
+	})
+
+	t.Run("stops the kill once the command has returned", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", "sleep 30")
+		group := terminateProcessGroup(cmd, time.Minute)
+		require.NoError(t, cmd.Start())
+
+		cancel()
+		assert.Error(t, cmd.Wait())
+		group.stop()
+
+		require.NotNil(t, group.kill)
+		assert.False(t, group.kill.Stop(), "the kill is already stopped")
+	})
+
+	t.Run("does not schedule the kill when the group is gone", func(t *testing.T) {
+		cmd := exec.CommandContext(context.Background(), "/bin/sh", "-c", "exit 0")
+		group := terminateProcessGroup(cmd, time.Minute)
+		require.NoError(t, cmd.Run())
+
+		assert.ErrorIs(t, cmd.Cancel(), os.ErrProcessDone)
+		assert.Nil(t, group.kill)
+	})
+}
+
+// TestExecTool_Cancel tests the cancellation of running commands by the user
+func TestExecTool_Cancel(t *testing.T) {
+	logger := newTestLogger()
+
+	t.Run("returns the output written before the command was cancelled", func(t *testing.T) {
+		handler := &cancellingOutputHandler{line: "started"}
+		inputs := map[string]any{
+			inputCommand:          "echo started; sleep 30; echo finished",
+			inputWorkingDirectory: ".",
+		}
+		startedAt := time.Now()
+		output, err := NewExecTool(logger, newTestConfig()).Execute(inputs, WithOutputHandler(context.Background(), handler))
+		require.NoError(t, err)
+		assert.Less(t, time.Since(startedAt), 5*time.Second)
+
+		assert.True(t, output.IsError)
+		assert.True(t, output.ExecutedCommand.Cancelled)
+		assert.NotZero(t, output.ExecutedCommand.ExitCode)
+		assert.Equal(t, "started", output.ExecutedCommand.Output)
+		assert.True(t, strings.HasPrefix(output.Result, "The user cancelled the command `echo started; sleep 30; echo finished`"))
+		assert.Contains(t, output.Result, "started")
+	})
+
+	t.Run("cancels the command when the task is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			CancelByUser(cancel)
+		}()
+
+		inputs := map[string]any{inputCommand: "sleep 30", inputWorkingDirectory: "."}
+		output, err := NewExecTool(logger, newTestConfig()).Execute(inputs, ctx)
+		require.NoError(t, err)
+		assert.True(t, output.ExecutedCommand.Cancelled)
+		assert.Contains(t, output.Result, "The user cancelled the command `sleep 30`")
+	})
+
+	t.Run("does not report commands which completed as cancelled", func(t *testing.T) {
+		inputs := map[string]any{inputCommand: "echo done", inputWorkingDirectory: "."}
+		output, err := NewExecTool(logger, newTestConfig()).Execute(inputs, context.Background())
+		require.NoError(t, err)
+		assert.False(t, output.ExecutedCommand.Cancelled)
+	})
+}
@@ -0,0 +1,109 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/datolabs-io/opsy/internal/config"
+)
+
+// ErrContainerEngineUnavailable is the error returned when the container engine CLI cannot be found.
+const ErrContainerEngineUnavailable = "container engine is not available"
+
+// containerCount is the number of containers created, which names them uniquely together with the process ID.
+var containerCount atomic.Int64
+
+// ContainerBackend executes the commands in a new container of the configured image for every command, through a
+// local container engine CLI such as docker or podman. The working directory is mounted at the same path and the
+// configured mounts, such as credential directories, are mounted read-only, so the commands run with a reproducible
+// toolchain without changing the host outside of the working directory.
+type ContainerBackend struct {
+	config config.ContainerConfiguration
+}
+
+// NewContainerBackend creates a new ContainerBackend.
+func NewContainerBackend(cfg config.ContainerConfiguration) *ContainerBackend {
+	return &ContainerBackend{config: cfg}
+}
+
+// Command returns the process running the shell command in a container. The environment variables are passed to the
+// container by name, so that their values, which may be secrets, do not appear in the arguments of the engine.
+// Terminating the engine client does not stop the container, so the container is stopped and removed by name when the
+// context is done before the command has finished, e.g. because it was cancelled or timed out.
+func (b *ContainerBackend) Command(ctx context.Context, command, workingDirectory string, env []string) (*exec.Cmd, error) {
+	path, err := exec.LookPath(b.config.Engine)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrContainerEngineUnavailable, err)
+	}
+
+	// The engine would create a missing working directory on the host, owned by root.
+	if _, err := os.Stat(workingDirectory); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("opsy-%d-%d", os.Getpid(), containerCount.Add(1))
+	cmd := exec.CommandContext(ctx, path, b.args(name, command, workingDirectory, env)...)
+	if len(env) > 0 {
+		cmd.Env = append(cmd.Environ(), env...)
+	}
+
+	context.AfterFunc(ctx, func() {
+		if errors.Is(context.Cause(ctx), errCommandFinished) {
+			return
+		}
+		for _, args := range removeArgs(name) {
+			_ = exec.Command(path, args...).Run()
+		}
+	})
+
+	return cmd, nil
+}
+
+// args returns the arguments of the container engine running the shell command in the named container.
+func (b *ContainerBackend) args(name, command, workingDirectory string, env []string) []string {
+	args := []string{
+		"run", "--rm", "--init", "--name", name,
+		"--user", strconv.Itoa(os.Getuid()) + ":" + strconv.Itoa(os.Getgid()),
+		"--volume", workingDirectory + ":" + workingDirectory,
+		"--workdir", workingDirectory,
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		args = append(args, "--env", "HOME="+home)
+	}
+
+	for _, mount := range b.config.Mounts {
+		mount = expandHome(mount)
+		if _, err := os.Stat(mount); err != nil {
+			continue
+		}
+		args = append(args, "--volume", mount+":"+mount+":ro")
+	}
+
+	for _, name := range b.config.Env {
+		args = append(args, "--env", name)
+	}
+	for _, v := range env {
+		name, _, _ := strings.Cut(v, "=")
+		args = append(args, "--env", name)
+	}
+
+	args = append(args, b.config.Args...)
+
+	return append(args, b.config.Image, b.config.Shell, "-c", command)
+}
+
+// removeArgs returns the arguments of the container engine removing the named container: it is stopped with the grace
+// period of the commands, then removed in case the engine left it behind once its client was terminated.
+func removeArgs(name string) [][]string {
+	return [][]string{
+		{"stop", "--time", strconv.Itoa(int(terminationGracePeriod.Seconds())), name},
+		{"rm", "--force", name},
+	}
+}
@@ -0,0 +1,123 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestContainerBackend tests building the commands executed in a container.
+func TestContainerBackend(t *testing.T) {
+	t.Run("builds the engine arguments", func(t *testing.T) {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		require.NoError(t, os.Mkdir(filepath.Join(home, ".kube"), 0700))
+
+		backend := NewContainerBackend(config.ContainerConfiguration{
+			Engine: "docker",
+			Image:  "ghcr.io/example/toolbox:1.0",
+			Shell:  "/bin/bash",
+			Mounts: []string{"~/.kube", "~/.aws"},
+			Env:    []string{"AWS_PROFILE"},
+			Args:   []string{"--network=host"},
+		})
+		user := strconv.Itoa(os.Getuid()) + ":" + strconv.Itoa(os.Getgid())
+		kube := filepath.Join(home, ".kube")
+		assert.Equal(t, []string{
+			"run", "--rm", "--init", "--name", "opsy-1",
+			"--user", user,
+			"--volume", "/srv/repo:/srv/repo",
+			"--workdir", "/srv/repo",
+			"--env", "HOME=" + home,
+			"--volume", kube + ":" + kube + ":ro",
+			"--env", "AWS_PROFILE",
+			"--network=host",
+			"ghcr.io/example/toolbox:1.0", "/bin/bash", "-c", "kubectl get pods",
+		}, backend.args("opsy-1", "kubectl get pods", "/srv/repo", nil))
+	})
+
+	t.Run("names the containers uniquely", func(t *testing.T) {
+		backend := NewContainerBackend(config.ContainerConfiguration{Engine: "sh", Image: "alpine", Shell: "/bin/sh"})
+		first, err := backend.Command(context.Background(), "true", t.TempDir(), nil)
+		require.NoError(t, err)
+		second, err := backend.Command(context.Background(), "true", t.TempDir(), nil)
+		require.NoError(t, err)
+
+		prefix := "opsy-" + strconv.Itoa(os.Getpid()) + "-"
+		assert.True(t, strings.HasPrefix(first.Args[5], prefix))
+		assert.True(t, strings.HasPrefix(second.Args[5], prefix))
+		assert.NotEqual(t, first.Args[5], second.Args[5])
+	})
+
+	t.Run("removes the container of cancelled commands", func(t *testing.T) {
+		// The fake engine records its arguments, and runs the containers until they are stopped.
+		dir := t.TempDir()
+		log := filepath.Join(dir, "log")
+		script := "#!/bin/sh\necho \"$*\" >> " + log + "\nif [ \"$1\" = run ]; then exec sleep 30; fi\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "engine"), []byte(script), 0700))
+		backend := NewContainerBackend(config.ContainerConfiguration{Engine: filepath.Join(dir, "engine"), Image: "alpine"})
+
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cmd, err := backend.Command(ctx, "sleep 30", t.TempDir(), nil)
+		require.NoError(t, err)
+		require.NoError(t, cmd.Start())
+		name := cmd.Args[5]
+		CancelByUser(cancel)
+		_ = cmd.Wait()
+
+		assert.EventuallyWithT(t, func(c *assert.CollectT) {
+			content, err := os.ReadFile(log)
+			require.NoError(c, err)
+			lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+			assert.Equal(c, []string{"stop --time 5 " + name, "rm --force " + name}, lines[1:])
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("does not remove the container of finished commands", func(t *testing.T) {
+		dir := t.TempDir()
+		log := filepath.Join(dir, "log")
+		script := "#!/bin/sh\necho \"$*\" >> " + log + "\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "engine"), []byte(script), 0700))
+		backend := NewContainerBackend(config.ContainerConfiguration{Engine: filepath.Join(dir, "engine"), Image: "alpine"})
+
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cmd, err := backend.Command(ctx, "true", t.TempDir(), nil)
+		require.NoError(t, err)
+		require.NoError(t, cmd.Run())
+		cancel(errCommandFinished)
+
+		time.Sleep(100 * time.Millisecond)
+		content, err := os.ReadFile(log)
+		require.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(string(content), "\n"), "only the run is recorded")
+	})
+
+	t.Run("passes the tool env by name", func(t *testing.T) {
+		backend := NewContainerBackend(config.ContainerConfiguration{Engine: "sh", Image: "alpine", Shell: "/bin/sh"})
+		cmd, err := backend.Command(context.Background(), "true", t.TempDir(), []string{"JIRA_API_TOKEN=secret"})
+		require.NoError(t, err)
+		assert.Contains(t, cmd.Args, "JIRA_API_TOKEN")
+		assert.NotContains(t, cmd.String(), "secret")
+		assert.Contains(t, cmd.Env, "JIRA_API_TOKEN=secret")
+	})
+
+	t.Run("returns error without the engine", func(t *testing.T) {
+		backend := NewContainerBackend(config.ContainerConfiguration{Engine: "opsy-missing-engine"})
+		_, err := backend.Command(context.Background(), "true", t.TempDir(), nil)
+		assert.ErrorContains(t, err, ErrContainerEngineUnavailable)
+	})
+
+	t.Run("returns error for a missing working directory", func(t *testing.T) {
+		backend := NewContainerBackend(config.ContainerConfiguration{Engine: "sh"})
+		_, err := backend.Command(context.Background(), "true", filepath.Join(t.TempDir(), "missing"), nil)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -13,6 +13,9 @@ Tools are defined using the Definition struct, which includes:
   - Rules: Additional rules the tool must follow
   - Inputs: Map of input parameters the tool accepts
   - Executable: Optional path to an executable the tool uses
+  - Policy: Optional policy rules applied to the commands the tool runs
+  - Interactive: Optional regular expressions matching the commands which need an interactive terminal
+  - Env: Optional environment variables of the commands the tool runs
 
 # Input Schema
 
@@ -51,9 +54,230 @@ The exec tool has specific features:
 
   - Command execution with configurable timeouts
   - Working directory resolution (absolute, relative, and ./ paths)
-  - Command output and exit code capture
+  - Command output and exit code capture, with stdout and stderr kept apart
+  - Truncation of large output returned to the agent
+  - Streaming of the output line by line while the command runs
   - Timestamp tracking for command execution
   - Process group management for proper cleanup
+  - Optional approval of each command before it is executed
+  - Policy evaluation of each command before it is executed
+  - Dry-run mode recording commands instead of executing them
+
+# Command Approval
+
+When approval is enabled (tools.exec.approval), the exec tool asks the Approver
+carried by the context before running a command:
+
+	ctx = tool.WithApprover(ctx, approver)
+
+The approver returns one of the following decisions:
+
+  - DecisionApproved: The command is executed as proposed
+  - DecisionRejected: The command is not executed and the rejection is returned to the agent
+  - DecisionEdited: The edited command is executed and the edit is reported to the agent
+
+If approval is required but no approver is available, the command is not executed
+and ErrNoApprover is returned. An ApprovalRequest created with NewApprovalRequest
+carries the Done channel of its context, closed once the approval is no longer
+awaited, so that the user interface can dismiss it.
+
+# Command Output
+
+The output of a running command is streamed to the OutputHandler carried by the
+context, if any:
+
+	ctx = tool.WithOutputHandler(ctx, handler)
+
+The handler first receives a CommandOutput with Started set, then one CommandOutput
+per line written to stdout or stderr, with Stream set to StreamStdout or StreamStderr.
+Every update carries the running Command, whose ID matches the ID of the executed
+command returned in the output. The full output is still captured and returned to the
+agent once the command completes.
+
+The executed Command records the combined Output as well as the Stdout and Stderr of
+the command. When the command writes to stderr, the result returned to the agent labels
+each stream:
+
+	<stdout>
+	...
+	</stdout>
+	<stderr>
+	...
+	</stderr>
+
+Otherwise the stdout is returned as is, so that structured output stays parseable.
+
+Results exceeding tools.exec.output.max_bytes or tools.exec.output.max_lines are
+truncated to their head and tail, with a marker telling how many bytes and lines
+were omitted, and the executed Command has Truncated set. The Output of the Command
+always keeps the full output. With tools.exec.output.spill, the full result is saved
+to a file in tools.exec.output.path, recorded as OutputFile, and the agent is told
+to inspect the file instead of running the command again.
+
+# Cancellation and Timeouts
+
+Every command runs in its own process group. The Started update carries a Cancel
+function, which stops the command on behalf of the user; cancelling the context of
+the tool with CancelByUser stops it as well. The process group is sent SIGTERM and,
+if it is still running after a grace period of 5 seconds, SIGKILL, so the processes
+started by the command are stopped too. SIGKILL is not sent once the command has
+returned, nor when the group is already gone. The executed Command has Cancelled set, and
+the agent is told that the user cancelled the command, together with the output
+written until then. CancelledByUser reports whether a context was cancelled by the
+user.
+
+Commands running longer than tools.exec.timeout, or tools.timeout if it is 0, are
+terminated the same way, so pipelines such as kubectl port-forward | grep leave no
+processes behind. The executed Command has TimedOut set, independently of its exit
+code, and the agent is told that the command timed out after the timeout, together
+with the output written until then, instead of receiving an error.
+
+# Interactive Commands
+
+Commands which need a TTY, such as kubectl exec -it, aws sso login or gh auth
+login, are run in a pseudo-terminal handed over to the user. A command is
+interactive when the agent sets the interactive input of the exec tool, or when it
+matches one of the Interactive patterns of the tool it is executed for, passed
+with WithInteractive. The exec tool creates a TerminalSession and hands it to the
+Terminal carried by the context:
+
+	ctx = tool.WithTerminal(ctx, terminal)
+
+The Terminal runs the session with the stdin and stdout of the user's terminal,
+which is put into raw mode, and returns once the command has exited; the TUI
+releases the screen meanwhile. The transcript of the terminal, without escape
+sequences, is redacted and returned to the agent with the exit code, and the
+executed Command has Interactive set. Interactive commands are not subject to the
+timeout, since the user controls them. With the sandbox enabled, they run in it
+without a new session, so that the pseudo-terminal remains their controlling
+terminal. They can only be executed locally with the host backend, on Linux and
+macOS; otherwise, or when no terminal is available, the
+command is not executed and the agent is told to find a non-interactive
+alternative.
+
+# Secret Redaction
+
+Unless tools.exec.redaction.enabled is false, the exec tool passes the output of
+every command through a Redactor before it is streamed, returned to the agent,
+recorded in the executed Command or saved to a file, so secrets never reach the
+logs, the UI, the sessions or the model. The built-in detectors cover:
+
+  - AWS access key IDs, secret access keys and session tokens
+  - JWTs, e.g. Kubernetes service account tokens
+  - PEM blocks, also when base64-encoded as in kubeconfig files
+  - The base64 values of the data of Kubernetes secrets in YAML and JSON
+  - GitHub personal access, OAuth, app and refresh tokens
+
+The regular expressions of tools.exec.redaction.patterns are redacted as well; only
+the first capture group is redacted if the pattern has one. Each secret is replaced
+by a marker naming its detector, e.g. [REDACTED:aws-access-key-id], and the body of
+a multi-line PEM block by a single marker line:
+
+	redactor, err := tool.NewRedactor(cfg.Exec.Redaction)
+	output = redactor.Redact(output)
+
+# Backends
+
+The exec tool creates the process of each command with its Backend, selected by
+tools.exec.backend:
+
+  - host: The command is executed with the shell of the host, in the Sandbox if enabled
+  - container: The ContainerBackend executes the command in a new container of the
+    configured image, through a container engine CLI such as docker or podman
+
+The ContainerBackend runs the container as the current user, with the working
+directory mounted at the same path and the configured mounts, such as ~/.kube and
+~/.aws, mounted read-only, so the commands get a reproducible toolchain without
+changing the host outside of the working directory. Each container is named
+opsy-<pid>-<n>, so that the container of a cancelled or timed out command is
+stopped with the grace period and removed by name; terminating the engine client
+alone would leave it running. If the backend cannot execute the command, e.g.
+because the container engine is missing, the command is not executed and the
+agent is told why.
+
+# Remote Hosts
+
+When tools.exec.hosts is set, the exec tool has an optional host input naming the
+host to execute the command on. The SSHBackend executes the command with the
+OpenSSH client in batch mode, with the user, port and key of the host and through
+its jump hosts, which are either names of other hosts or [user@]host[:port]. The
+named jump hosts are reached with their own user, port and key, through a
+ProxyCommand for each of them. The command runs on the host next to a watcher,
+which sends SIGTERM, then SIGKILL after the grace period, to the processes of the
+SSH session within a second of the connection being closed, so that cancelled and
+timed out commands do not keep running on the host. The host needs a POSIX login
+shell, and processes which leave the process group of the session, such as
+daemons, are not terminated. The working directory is a path on the host, the home directory by default. The
+executed Command records the Host, and Location returns where it ran, e.g.
+web-1:/var/log. Commands targeting a host which is not configured are not
+executed, and neither are the commands of a tool with environment variables, which
+the SSHBackend cannot pass to the host (ErrSSHEnvUnsupported).
+
+# Sandbox
+
+With tools.exec.sandbox.enabled, the exec tool runs each command through bubblewrap
+instead of the shell directly. The Sandbox mounts the root filesystem read-only with
+a private /tmp, binds the working directory and the writable paths read-write, and
+passes only basic environment variables, such as PATH and HOME, and the ones named in
+the configuration. With isolate_network, the command has no network access. A tool
+can replace the configured sandbox with its own (Definition.Sandbox), applied with
+WithSandbox to the exec tool of its agent:
+
+	exec := tool.NewExecTool(logger, cfg, tool.WithSandbox(*def.Sandbox))
+
+If bubblewrap is not available, sandboxed commands are not executed and the agent is
+told why, so a sandbox never silently falls back to the host.
+
+# Tool Environment
+
+A tool can set environment variables for the commands of its agent
+(Definition.Env), such as KUBECONFIG or JIRA_API_TOKEN, merged with
+tools.<name>.env of the configuration, whose variables replace the ones of the
+definition with the same name. Each variable has a literal value, the value of a
+variable of the host environment (from_env), or the output of a command executed
+with the shell of the host (command), e.g. pass show jira/token. The values are
+resolved every time the tool is executed and applied with WithEnv to its exec tool
+only, so other tools and the exec tool of the main agent never see them:
+
+	exec := tool.NewExecTool(logger, cfg, tool.WithEnv(env, secrets))
+
+The values read by commands are secrets: unless redaction is disabled, they are
+redacted from the output of the commands, shown with the name of the variable. The
+container backend passes the variables to the container by name, so their values
+do not appear in its arguments. They cannot be passed to remote hosts: the
+commands of the tool targeting a host are not executed, and the agent is told to
+run them locally instead. If a variable cannot be resolved, the tool is not
+executed.
+
+# Command Policy
+
+Before a command is executed, the exec tool evaluates it against the Policy.
+The command is split into the individual commands of its pipelines, lists and
+substitutions (including `sh -c` arguments), and each command is classified:
+
+  - ClassificationReadOnly: The command only reads state (e.g. `kubectl get`, `git log`)
+  - ClassificationMutating: The command changes state (e.g. `kubectl apply`, output redirection)
+  - ClassificationDestructive: The command deletes or overwrites state (e.g. `kubectl delete`, `git push --force`)
+
+The action of a command is taken from the first matching rule; tool rules
+(Definition.Policy) are checked before the rules of the configuration
+(tools.exec.policy.rules). Commands matching no rule get the default action of
+their classification. The most restrictive action of all commands applies:
+
+  - ActionAllow: The command is executed
+  - ActionAsk: The command is executed only after it is approved
+  - ActionDeny: The command is blocked and the reason is returned to the agent
+
+Commands edited during approval are evaluated again. If the policy cannot be
+created, every command is blocked.
+
+# Dry Run
+
+When dry-run mode is enabled (tools.exec.dry_run.enabled), the exec tool never
+starts a process. Commands allowed by the policy are returned as an ExecutedCommand
+with DryRun set, and the agent receives a synthetic result asking it to assume the
+command succeeded. Recorded commands do not require approval. With
+tools.exec.dry_run.run_read_only, read-only commands are still executed.
 
 # Example Usage
 
@@ -95,6 +319,18 @@ The package defines several error types for validation:
   - ErrToolInputMissingDescription: Input definition lacks a description
   - ErrToolExecutableNotFound: Specified executable not found
   - ErrInvalidToolInputType: Input value has wrong type
+  - ErrToolInvalidPolicy: Tool definition has invalid policy rules
+  - ErrToolInvalidInteractive: Tool definition has an invalid interactive command pattern
+  - ErrToolInvalidEnv: Tool definition has invalid environment variables
+  - ErrToolEnv: Environment variables of a tool cannot be resolved
+  - ErrNoApprover: Command requires approval but no approver is available
+  - ErrInvalidPolicy: Command policy cannot be created
+  - ErrInvalidPolicyRule: Policy rule cannot be compiled
+  - ErrSandboxUnavailable: Sandbox is enabled but bubblewrap is not available
+  - ErrContainerEngineUnavailable: Container engine CLI of the container backend not found
+  - ErrSSHUnavailable: OpenSSH client for a remote host not found
+  - ErrSSHEnvUnsupported: Environment variables of a tool cannot be passed to a remote host
+  - ErrTerminalUnsupported: Interactive commands are not supported on the platform
 
 # Thread Safety
 
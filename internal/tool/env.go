@@ -0,0 +1,103 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/datolabs-io/opsy/internal/config"
+)
+
+const (
+	// ErrToolEnv is the error returned when the environment variables of a tool cannot be resolved.
+	ErrToolEnv = "failed to resolve tool env"
+
+	// minEnvSecretLength is the minimum length of a line of a secret read by a command to be redacted.
+	minEnvSecretLength = 4
+)
+
+// mergeEnv returns the environment variables of the definition of a tool, replaced by the ones of its configuration
+// with the same name, followed by the other ones of its configuration.
+func mergeEnv(definition, override []config.EnvVar) []config.EnvVar {
+	merged := []config.EnvVar{}
+	for _, v := range definition {
+		if !slices.ContainsFunc(override, func(o config.EnvVar) bool { return o.Name == v.Name }) {
+			merged = append(merged, v)
+		}
+	}
+
+	return append(merged, override...)
+}
+
+// resolveEnv resolves the values of the environment variables, returned as NAME=value. The commands reading values
+// are executed with the shell; their values are also returned by name as secrets, to be redacted from the output of
+// the commands of the tool. It returns an error if a variable of the host environment is not set or a command fails.
+func resolveEnv(ctx context.Context, shell string, vars []config.EnvVar) ([]string, map[string]string, error) {
+	env := []string{}
+	secrets := map[string]string{}
+
+	for _, v := range vars {
+		value := v.Value
+		switch {
+		case v.FromEnv != "":
+			var ok bool
+			if value, ok = os.LookupEnv(v.FromEnv); !ok {
+				return nil, nil, fmt.Errorf("%s: host environment variable %s is not set", v.Name, v.FromEnv)
+			}
+		case v.Command != "":
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			cmd := exec.CommandContext(ctx, shell, "-c", v.Command)
+			cmd.Stdout, cmd.Stderr = stdout, stderr
+			if err := cmd.Run(); err != nil {
+				return nil, nil, fmt.Errorf("%s: command `%s` failed: %v: %s", v.Name, v.Command, err,
+					strings.TrimSpace(stderr.String()))
+			}
+			value = strings.TrimRight(stdout.String(), "\r\n")
+			secrets[v.Name] = value
+		}
+
+		env = append(env, v.Name+"="+value)
+	}
+
+	return env, secrets, nil
+}
+
+// WithEnv sets the environment variables of the commands of the tool, as NAME=value, and the secrets among their
+// values, by name, which are redacted from the output of the commands unless redaction is disabled.
+func WithEnv(env []string, secrets map[string]string) ExecOption {
+	return func(t *execTool) {
+		t.env = env
+		t.envSecrets = secrets
+	}
+}
+
+// withSecrets returns a copy of the redactor which also redacts every line of the secrets, shown by name. It returns
+// nil if redaction is disabled.
+func (r *Redactor) withSecrets(secrets map[string]string) *Redactor {
+	if r == nil || len(secrets) == 0 {
+		return r
+	}
+
+	names := []string{}
+	for name := range secrets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	redactor := &Redactor{detectors: slices.Clone(r.detectors)}
+	for _, name := range names {
+		for _, line := range strings.Split(secrets[name], "\n") {
+			if line = strings.TrimSpace(line); len(line) >= minEnvSecretLength {
+				redactor.detectors = append(redactor.detectors,
+					detector{name: name, pattern: regexp.MustCompile(regexp.QuoteMeta(line))})
+			}
+		}
+	}
+
+	return redactor
+}
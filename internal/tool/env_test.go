@@ -0,0 +1,74 @@
+package tool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMergeEnv tests overriding the env of a tool definition with the configuration.
+func TestMergeEnv(t *testing.T) {
+	definition := []config.EnvVar{{Name: "KUBECONFIG", Value: "~/.kube/config"}, {Name: "NO_PROXY", Value: "localhost"}}
+	override := []config.EnvVar{{Name: "KUBECONFIG", Value: "~/.kube/prod"}, {Name: "HTTPS_PROXY", FromEnv: "PROXY"}}
+
+	assert.Equal(t, []config.EnvVar{
+		{Name: "NO_PROXY", Value: "localhost"},
+		{Name: "KUBECONFIG", Value: "~/.kube/prod"},
+		{Name: "HTTPS_PROXY", FromEnv: "PROXY"},
+	}, mergeEnv(definition, override))
+	assert.Equal(t, definition, mergeEnv(definition, nil))
+}
+
+// TestResolveEnv tests resolving the values of the env of a tool.
+func TestResolveEnv(t *testing.T) {
+	t.Run("resolves values, host env and commands", func(t *testing.T) {
+		t.Setenv("OPSY_TEST_PROXY", "http://proxy:3128")
+
+		env, secrets, err := resolveEnv(context.Background(), "/bin/sh", []config.EnvVar{
+			{Name: "KUBECONFIG", Value: "/srv/kubeconfig"},
+			{Name: "HTTPS_PROXY", FromEnv: "OPSY_TEST_PROXY"},
+			{Name: "API_TOKEN", Command: "printf 'token-value\n\n'"},
+			{Name: "EMPTY"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"KUBECONFIG=/srv/kubeconfig",
+			"HTTPS_PROXY=http://proxy:3128",
+			"API_TOKEN=token-value",
+			"EMPTY=",
+		}, env)
+		assert.Equal(t, map[string]string{"API_TOKEN": "token-value"}, secrets)
+	})
+
+	t.Run("returns error for an unset host variable", func(t *testing.T) {
+		_, _, err := resolveEnv(context.Background(), "/bin/sh", []config.EnvVar{
+			{Name: "HTTPS_PROXY", FromEnv: "OPSY_TEST_UNSET_VARIABLE"},
+		})
+		assert.ErrorContains(t, err, "HTTPS_PROXY: host environment variable OPSY_TEST_UNSET_VARIABLE is not set")
+	})
+
+	t.Run("returns error with the output of a failed command", func(t *testing.T) {
+		_, _, err := resolveEnv(context.Background(), "/bin/sh", []config.EnvVar{
+			{Name: "API_TOKEN", Command: "echo 'entry not found' >&2; exit 1"},
+		})
+		assert.ErrorContains(t, err, "API_TOKEN: command `echo 'entry not found' >&2; exit 1` failed")
+		assert.ErrorContains(t, err, "entry not found")
+	})
+}
+
+// TestRedactorWithSecrets tests redacting the secrets of the env of a tool.
+func TestRedactorWithSecrets(t *testing.T) {
+	redactor, err := NewRedactor(config.RedactionConfiguration{Enabled: true})
+	require.NoError(t, err)
+
+	secrets := redactor.withSecrets(map[string]string{"API_TOKEN": "s3cr3t.token", "PIN": "12", "KEY": "line-one\nline-two"})
+	assert.Equal(t, "token: [REDACTED:API_TOKEN]\npin: 12\n[REDACTED:KEY] [REDACTED:KEY]",
+		secrets.Redact("token: s3cr3t.token\npin: 12\nline-one line-two"))
+	assert.Equal(t, "token: s3cr3t.token", redactor.Redact("token: s3cr3t.token"))
+
+	var disabled *Redactor
+	assert.Nil(t, disabled.withSecrets(map[string]string{"API_TOKEN": "s3cr3t.token"}))
+}
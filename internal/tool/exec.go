@@ -2,13 +2,13 @@ package tool
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/datolabs-io/opsy/internal/config"
@@ -16,34 +16,127 @@ import (
 )
 
 // ExecTool is the tool for executing commands.
-type execTool tool
+type execTool struct {
+	*tool
+	// policy is the policy applied to commands before they are executed.
+	policy *Policy
+	// policyRules are the policy rules of the tool the commands are executed for.
+	policyRules []config.PolicyRule
+	// redactor redacts secrets from the output of commands, nil if redaction is disabled.
+	redactor *Redactor
+	// sandboxConfig is the configuration of the sandbox of the tool the commands are executed for.
+	sandboxConfig config.SandboxConfiguration
+	// backend creates the processes executing the commands.
+	backend Backend
+	// remotes are the backends executing the commands on the remote hosts, by name.
+	remotes map[string]*SSHBackend
+	// env are the environment variables of the commands, as NAME=value, in addition to the ones of the backend.
+	env []string
+	// envSecrets are the secrets among the values of env, by name.
+	envSecrets map[string]string
+	// interactivePatterns are the regular expressions matching the commands which need an interactive terminal.
+	interactivePatterns []string
+	// interactive are the compiled interactivePatterns.
+	interactive []*regexp.Regexp
+	// gracePeriod is the time a cancelled or timed out command has to exit after SIGTERM before it is killed.
+	gracePeriod time.Duration
+}
+
+// ExecOption is a function that configures the exec tool.
+type ExecOption func(*execTool)
 
 // ExecToolName is the name of the exec tool.
 const ExecToolName = "exec"
 
 // Command is the command that was executed.
 type Command struct {
+	// ID identifies the command across its output updates and completion.
+	ID string `json:"id"`
 	// Command is the command that was executed.
-	Command string
+	Command string `json:"command"`
 	// WorkingDirectory is the working directory of the command.
-	WorkingDirectory string
+	WorkingDirectory string `json:"working_directory"`
 	// ExitCode is the exit code of the command.
-	ExitCode int
-	// Output is the output of the command.
-	Output string
+	ExitCode int `json:"exit_code"`
+	// Output is the combined output of the command, in the order it was written.
+	Output string `json:"output,omitempty"`
+	// Stdout is the standard output of the command.
+	Stdout string `json:"stdout,omitempty"`
+	// Stderr is the standard error of the command.
+	Stderr string `json:"stderr,omitempty"`
+	// Truncated is true when the output returned to the agent was truncated.
+	Truncated bool `json:"truncated,omitempty"`
+	// OutputFile is the file the full output was saved to when it was truncated, if any.
+	OutputFile string `json:"output_file,omitempty"`
+	// Cancelled is true when the user cancelled the command, or the task, before the command completed.
+	Cancelled bool `json:"cancelled,omitempty"`
+	// TimedOut is true when the command was terminated because it exceeded the timeout.
+	TimedOut bool `json:"timed_out,omitempty"`
+	// Interactive is true when the command was run by the user in a terminal handed over to them.
+	Interactive bool `json:"interactive,omitempty"`
+	// Host is the name of the remote host the command was executed on, empty for local commands.
+	Host string `json:"host,omitempty"`
+	// Classification is the impact classification of the command.
+	Classification Classification `json:"classification,omitempty"`
+	// DryRun is true when the command was recorded instead of being executed.
+	DryRun bool `json:"dry_run,omitempty"`
 	// StartedAt is the time the command started.
-	StartedAt time.Time
+	StartedAt time.Time `json:"started_at"`
 	// CompletedAt is the time the command completed.
-	CompletedAt time.Time
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// Location returns where the command was executed: its working directory, prefixed by the host for remote commands.
+func (c Command) Location() string {
+	if c.Host == "" {
+		return c.WorkingDirectory
+	}
+
+	return c.Host + ":" + c.WorkingDirectory
 }
 
 const (
+	// ErrNoApprover is the error returned when a command requires approval but no approver is available.
+	ErrNoApprover = "command requires approval but no approver is available"
+	// ErrInvalidPolicy is the error returned when the command policy cannot be created.
+	ErrInvalidPolicy = "invalid command policy"
+
 	// inputCommand is the input parameter for the command to execute.
 	inputCommand = "command"
+	// inputHost is the input parameter for the remote host to execute the command on.
+	inputHost = "host"
+
+	// resultCommandRejected is the result returned to the agent when the user rejects a command.
+	resultCommandRejected = "The user rejected the command `%s`. Do not execute it again unless the user asks for it."
+	// resultCommandCancelled is the result returned to the agent when the user cancels a running command.
+	resultCommandCancelled = "The user cancelled the command `%s` before it completed. Do not execute it again " +
+		"unless the user asks for it."
+	// resultCommandTimedOut is the result returned to the agent when a command is terminated after the timeout.
+	resultCommandTimedOut = "The command `%s` timed out after %s and was terminated. The output written before the " +
+		"timeout follows, if any. Use a command that completes faster, e.g. by limiting its scope, or one that does " +
+		"not wait for input or run indefinitely."
+	// resultCommandEdited is the note added to the result when the user edits a command before it is executed.
+	resultCommandEdited = "The user edited the command before execution. Executed command: `%s`"
+	// resultCommandBlocked is the result returned to the agent when the policy blocks a command.
+	resultCommandBlocked = "The command was blocked by the policy and was not executed.\nCommand: `%s`\n" +
+		"Classification: %s\nReason: %s"
+	// resultBackendUnavailable is the result returned to the agent when the backend cannot execute the command.
+	resultBackendUnavailable = "The command `%s` was not executed: %s. Ask the user to fix the configuration of " +
+		"the exec backend."
+	// resultRemoteEnv is the result returned to the agent when a command of a tool with environment variables targets
+	// a remote host.
+	resultRemoteEnv = "The command `%s` was not executed on `%s`: the environment variables %s of the tool " +
+		"cannot be passed to remote hosts. Run the command locally instead, or ask the user to run it on the host."
+	// resultUnknownHost is the result returned to the agent when the command targets a host which is not configured.
+	resultUnknownHost = "The host `%s` is not configured, the command was not executed. Use one of the hosts %s, or " +
+		"omit the host to execute the command locally."
+	// resultCommandDryRun is the result returned to the agent when a command is recorded in dry-run mode.
+	resultCommandDryRun = "Dry-run mode: the command `%s` was recorded but not executed. Assume it succeeded and " +
+		"continue with the task without relying on its output."
 )
 
 // NewExecTool creates a new exec tool.
-func NewExecTool(logger *slog.Logger, cfg *config.ToolsConfiguration) *execTool {
+func NewExecTool(logger *slog.Logger, cfg *config.ToolsConfiguration, opts ...ExecOption) *execTool {
 	definition := Definition{
 		DisplayName: "Exec",
 		Description: fmt.Sprintf("Executes the provided shell command via the `%s` shell.", cfg.Exec.Shell),
@@ -68,27 +161,89 @@ func NewExecTool(logger *slog.Logger, cfg *config.ToolsConfiguration) *execTool
 		},
 	}
 
-	return (*execTool)(New(ExecToolName, definition, logger, cfg, nil))
+	definition.Inputs[inputInteractive] = Input{
+		Description: "Set to true if the command needs an interactive terminal, e.g. to log in, to answer prompts or " +
+			"to open a shell. The terminal is handed over to the user, who runs the command, and its transcript is " +
+			"returned. Prefer non-interactive commands whenever possible.",
+		Type:     "boolean",
+		Optional: true,
+	}
+
+	if hosts := hostNames(cfg.Exec.Hosts); len(hosts) > 0 {
+		definition.Inputs[inputHost] = Input{
+			Description: fmt.Sprintf("The name of the remote host to execute the command on over SSH, one of %s. "+
+				"Omit it to execute the command locally. The working directory is a path on the host.",
+				strings.Join(hosts, ", ")),
+			Type:     "string",
+			Examples: []any{hosts[0]},
+			Optional: true,
+		}
+	}
+
+	t := &execTool{
+		tool:          New(ExecToolName, definition, logger, cfg, nil),
+		sandboxConfig: cfg.Exec.Sandbox,
+		gracePeriod:   terminationGracePeriod,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.compileInteractive()
+
+	policy, err := NewPolicy(cfg.Exec.Policy, t.policyRules)
+	if err != nil {
+		t.logger.With("error", err).Error("Failed to create command policy, all commands will be blocked.")
+	}
+	t.policy = policy
+
+	redactor, err := NewRedactor(cfg.Exec.Redaction)
+	if err != nil {
+		t.logger.With("error", err).Error("Failed to create redactor, only the built-in detectors will be used.")
+		redactor, _ = NewRedactor(config.RedactionConfiguration{Enabled: true})
+	}
+	t.redactor = redactor.withSecrets(t.envSecrets)
+	t.backend = newBackend(cfg.Exec, t.sandboxConfig)
+	t.remotes = map[string]*SSHBackend{}
+	for name := range cfg.Exec.Hosts {
+		t.remotes[name] = NewSSHBackend(name, cfg.Exec.Hosts)
+	}
+
+	return t
+}
+
+// WithPolicyRules sets the policy rules of the tool the commands are executed for.
+func WithPolicyRules(rules []config.PolicyRule) ExecOption {
+	return func(t *execTool) {
+		t.policyRules = rules
+	}
+}
+
+// WithSandbox sets the configuration of the sandbox of the tool the commands are executed for, replacing the
+// configured one.
+func WithSandbox(cfg config.SandboxConfiguration) ExecOption {
+	return func(t *execTool) {
+		t.sandboxConfig = cfg
+	}
 }
 
 // GetName returns the name of the tool.
 func (t *execTool) GetName() string {
-	return (*tool)(t).GetName()
+	return t.tool.GetName()
 }
 
 // GetDisplayName returns the display name of the tool.
 func (t *execTool) GetDisplayName() string {
-	return (*tool)(t).GetDisplayName()
+	return t.tool.GetDisplayName()
 }
 
 // GetDescription returns the description of the tool.
 func (t *execTool) GetDescription() string {
-	return (*tool)(t).GetDescription()
+	return t.tool.GetDescription()
 }
 
 // GetInputSchema returns the input schema of the tool.
 func (t *execTool) GetInputSchema() *jsonschema.Schema {
-	return (*tool)(t).GetInputSchema()
+	return t.tool.GetInputSchema()
 }
 
 // Execute executes the tool.
@@ -99,34 +254,163 @@ func (t *execTool) Execute(inputs map[string]any, ctx context.Context) (*Output,
 	}
 
 	workingDirectory := getWorkingDirectory(inputs)
-	ctx, cancel := context.WithTimeout(ctx, t.getTimeout())
+	backend := t.backend
+	note := ""
+
+	host, _ := inputs[inputHost].(string)
+	if host != "" {
+		remote, ok := t.remotes[host]
+		if !ok {
+			return t.unknownHost(host), nil
+		}
+		if len(t.env) > 0 {
+			return t.remoteEnv(command, host), nil
+		}
+		backend, workingDirectory = remote, getRemoteWorkingDirectory(inputs)
+	}
+
+	if t.policy == nil {
+		return t.blocked(command, Verdict{Classification: ClassifyCommand(command), Action: ActionDeny, Reason: ErrInvalidPolicy}), nil
+	}
+
+	verdict := t.policy.Evaluate(command)
+	if verdict.Action == ActionDeny {
+		return t.blocked(command, verdict), nil
+	}
+
+	if t.skipDryRun(verdict) {
+		return t.dryRun(command, workingDirectory, host, verdict), nil
+	}
+
+	if t.config.Exec.Approval || verdict.Action == ActionAsk {
+		approval, err := t.approve(ctx, Command{
+			Command:          command,
+			WorkingDirectory: workingDirectory,
+			Host:             host,
+			Classification:   verdict.Classification,
+		})
+		if err != nil {
+			return &Output{Tool: t.GetName(), Result: err.Error(), IsError: true}, err
+		}
+
+		switch approval.Decision {
+		case DecisionRejected:
+			return &Output{
+				Tool:    t.GetName(),
+				Result:  fmt.Sprintf(resultCommandRejected, command),
+				IsError: true,
+			}, nil
+		case DecisionEdited:
+			command = approval.Command
+			note = fmt.Sprintf(resultCommandEdited, command)
+			if verdict = t.policy.Evaluate(command); verdict.Action == ActionDeny {
+				return t.blocked(command, verdict), nil
+			}
+			if t.skipDryRun(verdict) {
+				return t.dryRun(command, workingDirectory, host, verdict), nil
+			}
+		}
+	}
+
+	if t.isInteractive(command, inputs) {
+		output := t.executeInteractive(ctx, backend, Command{
+			ID:               newCommandID(),
+			Command:          command,
+			WorkingDirectory: workingDirectory,
+			Host:             host,
+			Classification:   verdict.Classification,
+			StartedAt:        time.Now(),
+		})
+		if note != "" {
+			output.Result = strings.TrimSpace(note + "\n\n" + output.Result)
+		}
+
+		return output, nil
+	}
+
+	timeout := t.getTimeout()
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, errTimedOut)
 	defer cancel()
+	ctx, cancelCommand := context.WithCancelCause(ctx)
+	defer cancelCommand(errCommandFinished)
 
-	cmd := exec.CommandContext(ctx, t.config.Exec.Shell, "-c", command)
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-	cmd.Dir = workingDirectory
+	cmd, err := backend.Command(ctx, command, workingDirectory, t.env)
+	if err != nil {
+		t.logger.With("command", command).With("error", err).Error("Failed to create command.")
+		return &Output{Tool: t.GetName(), Result: fmt.Sprintf(resultBackendUnavailable, command, err), IsError: true}, nil
+	}
+	group := terminateProcessGroup(cmd, t.gracePeriod)
+	defer group.stop()
 	cmd.Stdin = nil
 	startedAt := time.Now()
 
-	logger := t.logger.With("command", cmd.String()).With("working_directory", workingDirectory)
+	logger := t.logger.With("command", cmd.String()).With("working_directory", workingDirectory).With("host", host)
 	logger.Debug("Executing command.")
 
-	toolOutput, err := cmd.CombinedOutput()
+	running := Command{
+		ID:               newCommandID(),
+		Command:          command,
+		WorkingDirectory: workingDirectory,
+		Host:             host,
+		Classification:   verdict.Classification,
+		StartedAt:        startedAt,
+	}
+	handler, streaming := OutputHandlerFromContext(ctx)
+	lines := map[Stream]*lineRedactor{StreamStdout: t.redactor.lines(), StreamStderr: t.redactor.lines()}
+	capture := newOutputCapture(func(stream Stream, line string) {
+		if line, keep := lines[stream].Redact(line); streaming && keep {
+			handler.HandleOutput(ctx, CommandOutput{Command: running, Line: line, Stream: stream})
+		}
+	})
+	cmd.Stdout = capture.Writer(StreamStdout)
+	cmd.Stderr = capture.Writer(StreamStderr)
+
+	if streaming {
+		handler.HandleOutput(ctx, CommandOutput{Command: running, Started: true, Cancel: func() {
+			CancelByUser(cancelCommand)
+		}})
+	}
+
+	err = cmd.Run()
+	capture.Flush()
+	stdout, stderr := t.redactor.Redact(capture.Output(StreamStdout)), t.redactor.Redact(capture.Output(StreamStderr))
 	output := &Output{
 		Tool:    t.GetName(),
-		Result:  strings.TrimSpace(string(toolOutput)),
+		Result:  formatResult(stdout, stderr),
 		IsError: false,
 		ExecutedCommand: &Command{
+			ID:               running.ID,
 			Command:          command,
 			WorkingDirectory: workingDirectory,
 			ExitCode:         cmd.ProcessState.ExitCode(),
+			Output:           strings.TrimSpace(t.redactor.Redact(capture.Combined())),
+			Stdout:           strings.TrimSpace(stdout),
+			Stderr:           strings.TrimSpace(stderr),
+			Host:             host,
+			Classification:   verdict.Classification,
 			StartedAt:        startedAt,
 			CompletedAt:      time.Now(),
 		},
 	}
 
-	if toolOutput != nil {
-		output.ExecutedCommand.Output = output.Result
+	t.limitOutput(output)
+
+	if CancelledByUser(ctx) {
+		logger.Info("Command cancelled by the user.")
+		output.ExecutedCommand.Cancelled = true
+		output.IsError = true
+		output.Result = strings.TrimSpace(fmt.Sprintf(resultCommandCancelled, command) + "\n\n" + output.Result)
+		err = nil
+	} else if errors.Is(context.Cause(ctx), errTimedOut) {
+		logger.With("timeout", timeout).Warn("Command timed out.")
+		output.ExecutedCommand.TimedOut = true
+		output.IsError = true
+		output.Result = strings.TrimSpace(fmt.Sprintf(resultCommandTimedOut, command, timeout) + "\n\n" + output.Result)
+		err = nil
+	}
+
+	if note != "" {
+		output.Result = strings.TrimSpace(note + "\n\n" + output.Result)
 	}
 
 	if err != nil {
@@ -137,6 +421,123 @@ func (t *execTool) Execute(inputs map[string]any, ctx context.Context) (*Output,
 	return output, err
 }
 
+// limitOutput truncates the result returned to the agent to the configured limits, saving the full result to a file
+// if configured.
+func (t *execTool) limitOutput(output *Output) {
+	limits := t.config.Exec.Output
+	result, truncated := limitOutput(output.Result, limits.MaxBytes, limits.MaxLines)
+	if !truncated {
+		return
+	}
+
+	logger := t.logger.With("command", output.ExecutedCommand.Command).With("bytes", len(output.Result))
+	output.ExecutedCommand.Truncated = true
+	if limits.Spill && limits.Path != "" {
+		path, err := spillOutput(limits.Path, output.ExecutedCommand, output.Result)
+		if err != nil {
+			logger.With("error", err).Error("Failed to save the full command output.")
+		} else {
+			output.ExecutedCommand.OutputFile = path
+			result += "\n\n" + fmt.Sprintf(resultSpilled, path)
+		}
+	}
+
+	logger.With("output_file", output.ExecutedCommand.OutputFile).Debug("Command output truncated.")
+	output.Result = result
+}
+
+// blocked returns the output for a command blocked by the policy.
+func (t *execTool) blocked(command string, verdict Verdict) *Output {
+	t.logger.With("command", command).With("classification", verdict.Classification).With("reason", verdict.Reason).
+		Warn("Command blocked by policy.")
+
+	return &Output{
+		Tool:    t.GetName(),
+		Result:  fmt.Sprintf(resultCommandBlocked, command, verdict.Classification, verdict.Reason),
+		IsError: true,
+	}
+}
+
+// unknownHost returns the output for a command targeting a host which is not configured.
+func (t *execTool) unknownHost(host string) *Output {
+	t.logger.With("host", host).Warn("Command targets an unknown host.")
+
+	return &Output{
+		Tool:    t.GetName(),
+		Result:  fmt.Sprintf(resultUnknownHost, host, strings.Join(hostNames(t.config.Exec.Hosts), ", ")),
+		IsError: true,
+	}
+}
+
+// remoteEnv returns the output for a command targeting a remote host while the tool sets environment variables, which
+// are not passed to remote hosts.
+func (t *execTool) remoteEnv(command, host string) *Output {
+	names := []string{}
+	for _, v := range t.env {
+		name, _, _ := strings.Cut(v, "=")
+		names = append(names, name)
+	}
+	t.logger.With("host", host).With("env", names).Warn("Command with tool env targets a remote host.")
+
+	return &Output{
+		Tool:    t.GetName(),
+		Result:  fmt.Sprintf(resultRemoteEnv, command, host, strings.Join(names, ", ")),
+		IsError: true,
+	}
+}
+
+// skipDryRun returns true if the command must be recorded instead of being executed.
+func (t *execTool) skipDryRun(verdict Verdict) bool {
+	dryRun := t.config.Exec.DryRun
+	return dryRun.Enabled && !(dryRun.RunReadOnly && verdict.Classification == ClassificationReadOnly)
+}
+
+// dryRun returns the output for a command recorded in dry-run mode.
+func (t *execTool) dryRun(command, workingDirectory, host string, verdict Verdict) *Output {
+	t.logger.With("command", command).With("working_directory", workingDirectory).With("host", host).
+		With("classification", verdict.Classification).Info("Command recorded in dry-run mode.")
+
+	now := time.Now()
+	return &Output{
+		Tool:   t.GetName(),
+		Result: fmt.Sprintf(resultCommandDryRun, command),
+		ExecutedCommand: &Command{
+			Command:          command,
+			WorkingDirectory: workingDirectory,
+			Host:             host,
+			Classification:   verdict.Classification,
+			DryRun:           true,
+			StartedAt:        now,
+			CompletedAt:      now,
+		},
+	}
+}
+
+// approve asks the approver carried by the context to approve the command.
+func (t *execTool) approve(ctx context.Context, cmd Command) (Approval, error) {
+	approver, ok := ApproverFromContext(ctx)
+	if !ok {
+		return Approval{}, errors.New(ErrNoApprover)
+	}
+
+	logger := t.logger.With("command", cmd.Command).With("working_directory", cmd.WorkingDirectory)
+	logger.Debug("Waiting for command approval.")
+
+	approval, err := approver.Approve(ctx, cmd)
+	if err != nil {
+		logger.With("error", err).Error("Command approval failed.")
+		return Approval{}, err
+	}
+
+	if approval.Decision == DecisionEdited && strings.TrimSpace(approval.Command) == "" {
+		approval.Decision = DecisionRejected
+	}
+
+	logger.With("decision", approval.Decision).Debug("Command approval received.")
+
+	return approval, nil
+}
+
 // getTimeout returns the timeout for the Exec tool.
 func (t *execTool) getTimeout() time.Duration {
 	timeout := t.config.Timeout
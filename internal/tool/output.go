@@ -0,0 +1,242 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Stream is the output stream of a command.
+type Stream string
+
+const (
+	// StreamStdout is the standard output of a command.
+	StreamStdout Stream = "stdout"
+	// StreamStderr is the standard error of a command.
+	StreamStderr Stream = "stderr"
+
+	// resultStream is the format of a labeled stream in the result returned to the agent.
+	resultStream = "<%s>\n%s\n</%s>"
+	// resultTruncated is the marker added to the output returned to the agent in place of the dropped output.
+	resultTruncated = "[... output truncated: %d bytes and %d lines omitted ...]"
+	// resultSpilled is the note added to truncated output that was saved to a file.
+	resultSpilled = "The full output was saved to `%s`. Inspect it with follow-up commands (e.g. `grep`, `head`, " +
+		"`tail`) instead of running the command again."
+)
+
+// CommandOutput is an update on a running command.
+type CommandOutput struct {
+	// Command is the running command.
+	Command Command
+	// Line is a line of output written by the command.
+	Line string
+	// Stream is the stream the line was written to.
+	Stream Stream
+	// Started is true for the update sent when the command starts, which carries no output.
+	Started bool
+	// Cancel cancels the command on behalf of the user, terminating its process group. It is only set on the update
+	// sent when the command starts.
+	Cancel func()
+}
+
+// OutputHandler is the interface for receiving the output of commands while they run.
+type OutputHandler interface {
+	// HandleOutput handles an update on a running command.
+	HandleOutput(ctx context.Context, output CommandOutput)
+}
+
+// outputHandlerKey is the context key for the output handler.
+type outputHandlerKey struct{}
+
+// commandID is the counter used to identify running commands.
+var commandID atomic.Uint64
+
+// WithOutputHandler returns a copy of the context that carries the given output handler.
+func WithOutputHandler(ctx context.Context, handler OutputHandler) context.Context {
+	return context.WithValue(ctx, outputHandlerKey{}, handler)
+}
+
+// OutputHandlerFromContext returns the output handler carried by the context, if any.
+func OutputHandlerFromContext(ctx context.Context) (OutputHandler, bool) {
+	handler, ok := ctx.Value(outputHandlerKey{}).(OutputHandler)
+	return handler, ok
+}
+
+// newCommandID returns a new unique identifier for a command.
+func newCommandID() string {
+	return fmt.Sprintf("cmd-%d", commandID.Add(1))
+}
+
+// outputCapture captures the stdout and stderr of a command and passes every complete line to a callback.
+type outputCapture struct {
+	mu       sync.Mutex
+	combined bytes.Buffer
+	streams  map[Stream]*streamBuffer
+	onLine   func(stream Stream, line string)
+}
+
+// streamBuffer is the captured output of a single stream.
+type streamBuffer struct {
+	output  bytes.Buffer
+	partial []byte
+}
+
+// streamWriter is an io.Writer writing to a single stream of the output capture.
+type streamWriter struct {
+	capture *outputCapture
+	stream  Stream
+}
+
+// newOutputCapture creates a new output capture calling onLine for every line written.
+func newOutputCapture(onLine func(stream Stream, line string)) *outputCapture {
+	return &outputCapture{
+		streams: map[Stream]*streamBuffer{StreamStdout: {}, StreamStderr: {}},
+		onLine:  onLine,
+	}
+}
+
+// Writer returns the writer for the given stream.
+func (c *outputCapture) Writer(stream Stream) io.Writer {
+	return &streamWriter{capture: c, stream: stream}
+}
+
+// Write captures the data and passes the completed lines to the callback.
+func (w *streamWriter) Write(p []byte) (int, error) {
+	c := w.capture
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	buffer := c.streams[w.stream]
+	c.combined.Write(p)
+	buffer.output.Write(p)
+	buffer.partial = append(buffer.partial, p...)
+	for {
+		index := bytes.IndexByte(buffer.partial, '\n')
+		if index < 0 {
+			break
+		}
+
+		c.onLine(w.stream, string(bytes.TrimSuffix(buffer.partial[:index], []byte("\r"))))
+		buffer.partial = buffer.partial[index+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush passes the last line of each stream to the callback if it was not terminated by a newline.
+func (c *outputCapture) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, stream := range []Stream{StreamStdout, StreamStderr} {
+		if buffer := c.streams[stream]; len(buffer.partial) > 0 {
+			c.onLine(stream, string(buffer.partial))
+			buffer.partial = nil
+		}
+	}
+}
+
+// Output returns the captured output of the given stream.
+func (c *outputCapture) Output(stream Stream) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.streams[stream].output.String()
+}
+
+// Combined returns the captured output of all the streams, in the order it was written.
+func (c *outputCapture) Combined() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.combined.String()
+}
+
+// formatResult formats the output of a command for the agent, labeling the stream each part came from. The stdout is
+// returned as is when nothing was written to stderr, so that structured output can be parsed directly.
+func formatResult(stdout, stderr string) string {
+	stdout, stderr = strings.TrimSpace(stdout), strings.TrimSpace(stderr)
+	if stderr == "" {
+		return stdout
+	}
+
+	result := strings.Builder{}
+	if stdout != "" {
+		result.WriteString(fmt.Sprintf(resultStream, StreamStdout, stdout, StreamStdout))
+		result.WriteString("\n")
+	}
+	result.WriteString(fmt.Sprintf(resultStream, StreamStderr, stderr, StreamStderr))
+
+	return result.String()
+}
+
+// limitOutput truncates the output to the configured limits, keeping its head and tail and marking how much was
+// dropped. It returns the output unchanged when it is within the limits.
+func limitOutput(output string, maxBytes, maxLines int) (string, bool) {
+	head, tail := output, ""
+	if lines := strings.SplitAfter(output, "\n"); maxLines > 0 && len(lines) > maxLines {
+		headLines := (maxLines + 1) / 2
+		head = strings.Join(lines[:headLines], "")
+		tail = strings.Join(lines[len(lines)-(maxLines-headLines):], "")
+	}
+
+	if maxBytes > 0 && len(head)+len(tail) > maxBytes {
+		if tail == "" {
+			tail = head
+		}
+		headBytes := min(len(head), maxBytes/2)
+		tailBytes := min(len(tail), maxBytes-headBytes)
+		head = cutHead(head, headBytes)
+		tail = cutTail(tail, tailBytes)
+	}
+
+	if len(head)+len(tail) >= len(output) {
+		return output, false
+	}
+
+	dropped := output[len(head) : len(output)-len(tail)]
+	marker := fmt.Sprintf(resultTruncated, len(dropped), strings.Count(dropped, "\n"))
+
+	return strings.TrimRight(head, "\n") + "\n\n" + marker + "\n\n" + strings.TrimLeft(tail, "\n"), true
+}
+
+// cutHead returns at most n bytes from the start of s, without splitting a multi-byte character.
+func cutHead(s string, n int) string {
+	for n > 0 && n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
+
+// cutTail returns at most n bytes from the end of s, without splitting a multi-byte character.
+func cutTail(s string, n int) string {
+	start := len(s) - n
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+
+	return s[start:]
+}
+
+// spillOutput saves the full output of a command to a file in the given directory and returns the path of the file.
+func spillOutput(dir string, cmd *Command, output string) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("output-%s-%s.log", cmd.StartedAt.Format("20060102-150405"), cmd.ID)
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(output), 0600); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
@@ -0,0 +1,755 @@
+package tool
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/datolabs-io/opsy/internal/config"
+)
+
+// Classification is the impact classification of a command.
+type Classification string
+
+const (
+	// ClassificationReadOnly is the classification of commands that do not change any state.
+	ClassificationReadOnly Classification = "read-only"
+	// ClassificationMutating is the classification of commands that change state.
+	ClassificationMutating Classification = "mutating"
+	// ClassificationDestructive is the classification of commands that delete resources or rewrite history.
+	ClassificationDestructive Classification = "destructive"
+)
+
+// Action is the action the policy applies to a command.
+type Action string
+
+const (
+	// ActionAllow allows the command to be executed.
+	ActionAllow Action = "allow"
+	// ActionAsk requires the command to be approved by the user before it is executed.
+	ActionAsk Action = "ask"
+	// ActionDeny blocks the command.
+	ActionDeny Action = "deny"
+)
+
+const (
+	// ErrInvalidPolicyRule is the error returned when a policy rule cannot be compiled.
+	ErrInvalidPolicyRule = "invalid policy rule"
+
+	// maxShellDepth is the maximum depth of nested `sh -c` commands that are inspected.
+	maxShellDepth = 3
+)
+
+// Verdict is the result of evaluating a command against the policy.
+type Verdict struct {
+	// Classification is the most severe classification of the commands in the shell command.
+	Classification Classification
+	// Action is the most restrictive action of the commands in the shell command.
+	Action Action
+	// Reason explains why the command is denied or requires approval.
+	Reason string
+}
+
+// Policy classifies commands and decides whether they may be executed.
+type Policy struct {
+	// defaults are the actions applied to each classification when no rule matches.
+	defaults map[Classification]Action
+	// rules are the rules matched against each command, in order of precedence.
+	rules []policyRule
+}
+
+// policyRule is a compiled policy rule.
+type policyRule struct {
+	match  *regexp.Regexp
+	action Action
+	reason string
+}
+
+// segment is a single command of a shell pipeline or list.
+type segment struct {
+	// words are the words of the command, with environment assignments and wrappers removed.
+	words []string
+	// redirect indicates whether the command output is redirected to a file.
+	redirect bool
+}
+
+// NewPolicy creates a new policy from the configuration. Tool rules take precedence over the configured rules.
+func NewPolicy(cfg config.PolicyConfiguration, toolRules []config.PolicyRule) (*Policy, error) {
+	p := &Policy{
+		defaults: map[Classification]Action{
+			ClassificationReadOnly:    Action(cfg.ReadOnly),
+			ClassificationMutating:    Action(cfg.Mutating),
+			ClassificationDestructive: Action(cfg.Destructive),
+		},
+	}
+
+	for _, rule := range slices.Concat(toolRules, cfg.Rules) {
+		match, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q: %v", ErrInvalidPolicyRule, rule.Match, err)
+		}
+
+		p.rules = append(p.rules, policyRule{match: match, action: Action(rule.Action), reason: rule.Reason})
+	}
+
+	return p, nil
+}
+
+// Evaluate classifies the shell command and returns the action to apply to it.
+func (p *Policy) Evaluate(command string) Verdict {
+	verdict := Verdict{Classification: ClassificationReadOnly, Action: ActionAllow}
+
+	for _, s := range splitCommand(command, 0) {
+		v := p.evaluateSegment(s)
+		if severity(v.Classification) > severity(verdict.Classification) {
+			verdict.Classification = v.Classification
+		}
+		if strictness(v.Action) > strictness(verdict.Action) {
+			verdict.Action = v.Action
+			verdict.Reason = v.Reason
+		}
+	}
+
+	return verdict
+}
+
+// evaluateSegment evaluates a single command against the rules and defaults.
+func (p *Policy) evaluateSegment(s segment) Verdict {
+	text := strings.Join(s.words, " ")
+	verdict := Verdict{Classification: classify(s)}
+
+	for _, rule := range p.rules {
+		if !rule.match.MatchString(text) {
+			continue
+		}
+
+		verdict.Action = normalizeAction(rule.action)
+		verdict.Reason = rule.reason
+		if verdict.Reason == "" {
+			verdict.Reason = fmt.Sprintf("`%s` matches the policy rule `%s`", text, rule.match)
+		}
+
+		return verdict
+	}
+
+	verdict.Action = normalizeAction(p.defaults[verdict.Classification])
+	if verdict.Action != ActionAllow {
+		verdict.Reason = fmt.Sprintf("`%s` is classified as %s", text, verdict.Classification)
+	}
+
+	return verdict
+}
+
+// ClassifyCommand returns the most severe classification of the commands in the shell command.
+func ClassifyCommand(command string) Classification {
+	classification := ClassificationReadOnly
+	for _, s := range splitCommand(command, 0) {
+		if c := classify(s); severity(c) > severity(classification) {
+			classification = c
+		}
+	}
+
+	return classification
+}
+
+// classify returns the classification of a single command.
+func classify(s segment) Classification {
+	if len(s.words) == 0 {
+		if s.redirect {
+			return ClassificationMutating
+		}
+		return ClassificationReadOnly
+	}
+
+	classification := classifyCommand(filepath.Base(s.words[0]), s.words[1:])
+	if s.redirect && classification == ClassificationReadOnly {
+		return ClassificationMutating
+	}
+
+	return classification
+}
+
+// normalizeAction returns the action, defaulting to allow when it is not set.
+func normalizeAction(action Action) Action {
+	if action == "" {
+		return ActionAllow
+	}
+
+	return action
+}
+
+// severity returns the severity of the classification.
+func severity(c Classification) int {
+	return slices.Index([]Classification{ClassificationReadOnly, ClassificationMutating, ClassificationDestructive}, c)
+}
+
+// strictness returns the strictness of the action.
+func strictness(a Action) int {
+	return slices.Index([]Action{ActionAllow, ActionAsk, ActionDeny}, a)
+}
+
+// verbs are the sub-commands of an executable, grouped by classification.
+type verbs struct {
+	readOnly    []string
+	destructive []string
+	// valueFlags are the global flags which take a separate value, skipped when looking for the sub-command.
+	valueFlags []string
+}
+
+var (
+	// readOnlyCommands are the commands which never change any state on their own.
+	readOnlyCommands = []string{
+		"ls", "cat", "head", "tail", "less", "more", "grep", "egrep", "fgrep", "rg", "wc", "echo", "printf",
+		"pwd", "cd", "which", "whoami", "id", "printenv", "uname", "ps", "df", "du",
+		"stat", "file", "jq", "uniq", "cut", "tr", "diff", "basename", "dirname",
+		"realpath", "readlink", "test", "[", "true", "false", "sleep", "base64", "md5sum", "sha1sum",
+		"sha256sum", "dig", "nslookup", "host", "ping", "tree", "column", "xxd", "nl", "tac", "type",
+	}
+	// destructiveCommands are the commands which delete data or stop processes.
+	destructiveCommands = []string{
+		"rm", "rmdir", "shred", "dd", "mkfs", "fdisk", "kill", "killall", "pkill", "reboot", "shutdown",
+		"halt", "poweroff", "truncate",
+	}
+	// wrapperCommands are the commands which run the command passed as their arguments.
+	wrapperCommands = []string{"sudo", "env", "time", "nohup", "nice", "command", "exec", "xargs", "watch", "timeout"}
+	// shellCommands are the shells whose `-c` argument is inspected as a command.
+	shellCommands = []string{"sh", "bash", "zsh", "dash", "ksh"}
+	// wrapperValueFlags are the flags of the wrapper commands which take a separate value.
+	wrapperValueFlags = map[string][]string{
+		"sudo":    {"-u", "-g", "-h", "-p", "-C", "-D", "-U", "-r", "-t"},
+		"env":     {"-u", "-C", "-S"},
+		"nice":    {"-n"},
+		"timeout": {"-s", "-k", "--signal", "--kill-after"},
+		"xargs":   {"-I", "-n", "-P", "-L", "-d", "-E", "-s", "-a"},
+		"watch":   {"-n", "-d"},
+	}
+	// awsValueFlags are the global flags of the AWS CLI which take a separate value.
+	awsValueFlags = []string{
+		"--profile", "--region", "--output", "--query", "--endpoint-url", "--color", "--ca-bundle",
+		"--cli-read-timeout", "--cli-connect-timeout",
+	}
+
+	// subcommandVerbs are the sub-commands of well-known executables.
+	subcommandVerbs = map[string]verbs{
+		"kubectl": {
+			readOnly: []string{
+				"get", "describe", "logs", "top", "explain", "api-resources", "api-versions", "version",
+				"cluster-info", "diff", "events", "wait", "can-i", "view", "get-contexts", "current-context",
+				"status", "history",
+			},
+			destructive: []string{"delete", "drain"},
+			valueFlags: []string{
+				"-n", "--namespace", "--context", "--kubeconfig", "-l", "--selector", "-o", "--output", "--cluster",
+				"--user", "-s", "--server", "--token", "--as", "--as-group", "--request-timeout", "-f", "--filename",
+				"-c", "--container",
+			},
+		},
+		"helm": {
+			readOnly: []string{
+				"list", "ls", "status", "get", "history", "hist", "show", "inspect", "template", "search",
+				"version", "env", "lint", "verify", "diff",
+			},
+			destructive: []string{"uninstall", "delete", "del", "un"},
+			valueFlags: []string{
+				"--kube-context", "-n", "--namespace", "--kubeconfig", "--kube-apiserver", "--kube-as-user",
+				"--kube-token", "-o", "--output", "-f", "--values", "--set", "--version", "--repo",
+			},
+		},
+		"gcloud": {
+			readOnly:    []string{"list", "describe", "info", "version", "get-value", "print-access-token", "read"},
+			destructive: []string{"delete", "remove-iam-policy-binding"},
+			valueFlags: []string{
+				"--project", "--account", "--configuration", "--format", "--filter", "--zone", "--region",
+				"--impersonate-service-account",
+			},
+		},
+		"jira": {
+			readOnly:    []string{"list", "view", "me", "version"},
+			destructive: []string{"delete"},
+		},
+		"docker": {
+			readOnly:    []string{"ps", "images", "logs", "inspect", "version", "info", "stats", "top", "history"},
+			destructive: []string{"rm", "rmi", "prune", "kill"},
+			valueFlags:  []string{"-H", "--host", "-c", "--context", "--config", "-l", "--log-level"},
+		},
+		"terraform": {
+			readOnly:    []string{"plan", "show", "validate", "output", "version", "fmt", "graph", "providers", "list"},
+			destructive: []string{"destroy"},
+		},
+	}
+)
+
+// classifyCommand returns the classification of an executable with the given arguments.
+func classifyCommand(name string, args []string) Classification {
+	switch {
+	case name == "git":
+		return classifyGit(args)
+	case name == "aws":
+		return classifyAWS(args)
+	case name == "gh":
+		return classifyGH(args)
+	case name == "curl":
+		return classifyHTTPMethod(flagValue(args, "-X", "--request"), hasAnyFlag(args, "-d", "--data", "-F", "--form", "-T"))
+	case name == "find":
+		if hasAnyFlag(args, "-delete") {
+			return ClassificationDestructive
+		}
+		if hasAnyFlag(args, "-exec", "-execdir", "-ok") {
+			return ClassificationMutating
+		}
+		return ClassificationReadOnly
+	case name == "sed":
+		if hasFlagPrefix(args, "-i", "--in-place") {
+			return ClassificationMutating
+		}
+		return ClassificationReadOnly
+	case name == "awk" || name == "gawk" || name == "mawk":
+		return classifyAWK(args)
+	case name == "yq":
+		if hasFlagPrefix(args, "-i", "--inplace") || slices.ContainsFunc(args, isShortFlagWith('i')) {
+			return ClassificationMutating
+		}
+		return ClassificationReadOnly
+	case name == "sort":
+		if hasFlagPrefix(args, "-o", "--output") || slices.ContainsFunc(args, isShortFlagWith('o')) {
+			return ClassificationMutating
+		}
+		return ClassificationReadOnly
+	case name == "date":
+		// Setting the date either uses -s or passes it as an argument, while formats start with +.
+		words := positional(args, "-d", "--date", "-f", "--file", "-r", "--reference")
+		if hasFlagPrefix(args, "-s", "--set") || slices.ContainsFunc(words, func(w string) bool { return !strings.HasPrefix(w, "+") }) {
+			return ClassificationMutating
+		}
+		return ClassificationReadOnly
+	case name == "hostname":
+		if len(positional(args)) > 0 || hasFlagPrefix(args, "-F", "--file", "-b", "--boot") {
+			return ClassificationMutating
+		}
+		return ClassificationReadOnly
+	case name == "terraform" && hasAnyFlag(args, "-destroy"):
+		return ClassificationDestructive
+	case slices.Contains(readOnlyCommands, name):
+		return ClassificationReadOnly
+	case slices.Contains(destructiveCommands, name) || strings.HasPrefix(name, "mkfs."):
+		return ClassificationDestructive
+	}
+
+	if v, ok := subcommandVerbs[name]; ok {
+		// A destructive verb anywhere wins over a read-only one, e.g. a resource named after a read-only verb.
+		words := positional(args, v.valueFlags...)
+		if slices.ContainsFunc(words, isAnyOf(v.destructive...)) {
+			return ClassificationDestructive
+		}
+		for _, word := range words {
+			if slices.Contains(v.readOnly, word) {
+				return ClassificationReadOnly
+			}
+		}
+	}
+
+	return ClassificationMutating
+}
+
+// classifyAWK returns the classification of an awk command. The program can run commands with system() or pipes and
+// write files with redirections, so it is only read-only without them; a program read from a file is not inspected.
+func classifyAWK(args []string) Classification {
+	if hasFlagPrefix(args, "-f", "--file") || hasAnyFlag(args, "-i", "--include") {
+		return ClassificationMutating
+	}
+
+	words := positional(args, "-F", "--field-separator", "-v", "--assign")
+	if len(words) == 0 {
+		return ClassificationReadOnly
+	}
+	if program := words[0]; strings.Contains(program, "system") || strings.ContainsAny(program, "|>") {
+		return ClassificationMutating
+	}
+
+	return ClassificationReadOnly
+}
+
+// classifyGit returns the classification of a git command.
+func classifyGit(args []string) Classification {
+	words := positional(args)
+	if len(words) == 0 {
+		return ClassificationReadOnly
+	}
+
+	switch words[0] {
+	case "status", "log", "diff", "show", "blame", "grep", "ls-files", "ls-remote", "ls-tree", "rev-parse",
+		"rev-list", "describe", "shortlog", "cat-file", "version", "help", "reflog":
+		if words[0] == "reflog" && slices.ContainsFunc(words[1:], isAnyOf("expire", "delete")) {
+			return ClassificationDestructive
+		}
+		return ClassificationReadOnly
+	case "push":
+		if hasAnyFlag(args, "-f", "--force", "--force-with-lease", "-d", "--delete", "--mirror", "--prune") ||
+			slices.ContainsFunc(words[1:], func(w string) bool { return strings.HasPrefix(w, ":") || strings.HasPrefix(w, "+") }) {
+			return ClassificationDestructive
+		}
+	case "reset":
+		if hasAnyFlag(args, "--hard") {
+			return ClassificationDestructive
+		}
+	case "clean":
+		if hasFlagPrefix(args, "-f", "--force") || slices.ContainsFunc(args, isShortFlagWith('f')) {
+			return ClassificationDestructive
+		}
+	case "branch":
+		if hasAnyFlag(args, "-D") || (hasAnyFlag(args, "-d", "--delete") && hasAnyFlag(args, "-f", "--force")) {
+			return ClassificationDestructive
+		}
+		if len(words) == 1 && !hasAnyFlag(args, "-d", "--delete", "-m", "-M", "-c", "-C", "--set-upstream-to", "-u") {
+			return ClassificationReadOnly
+		}
+	case "tag":
+		if hasAnyFlag(args, "-d", "--delete") {
+			return ClassificationDestructive
+		}
+		if len(words) == 1 {
+			return ClassificationReadOnly
+		}
+	case "stash":
+		if len(words) > 1 && slices.Contains([]string{"drop", "clear"}, words[1]) {
+			return ClassificationDestructive
+		}
+		if len(words) > 1 && slices.Contains([]string{"list", "show"}, words[1]) {
+			return ClassificationReadOnly
+		}
+	case "remote":
+		if len(words) == 1 || slices.Contains([]string{"show", "get-url"}, words[1]) {
+			return ClassificationReadOnly
+		}
+		if slices.Contains([]string{"remove", "rm"}, words[1]) {
+			return ClassificationDestructive
+		}
+	case "config":
+		if hasAnyFlag(args, "--get", "--get-all", "--list", "-l", "--get-regexp") {
+			return ClassificationReadOnly
+		}
+	case "checkout", "restore":
+		if slices.Contains(args, "--") || hasAnyFlag(args, "-f", "--force") {
+			return ClassificationDestructive
+		}
+	case "filter-branch", "filter-repo":
+		return ClassificationDestructive
+	}
+
+	return ClassificationMutating
+}
+
+// classifyAWS returns the classification of an AWS CLI command.
+func classifyAWS(args []string) Classification {
+	words := positional(args, awsValueFlags...)
+	if len(words) < 2 {
+		return ClassificationReadOnly
+	}
+
+	service, operation := words[0], words[1]
+	if service == "s3" {
+		switch operation {
+		case "rm", "rb":
+			return ClassificationDestructive
+		case "ls", "presign":
+			return ClassificationReadOnly
+		}
+		return ClassificationMutating
+	}
+
+	if service == "configure" && slices.Contains([]string{"list", "get", "list-profiles"}, operation) {
+		return ClassificationReadOnly
+	}
+
+	for _, prefix := range []string{"delete-", "terminate-", "remove-", "deregister-", "purge-", "destroy-"} {
+		if strings.HasPrefix(operation, prefix) {
+			return ClassificationDestructive
+		}
+	}
+
+	for _, prefix := range []string{"describe-", "list-", "get-", "lookup-", "search-", "batch-get-", "wait"} {
+		if strings.HasPrefix(operation, prefix) {
+			return ClassificationReadOnly
+		}
+	}
+
+	if slices.Contains([]string{"help", "scan", "query"}, operation) {
+		return ClassificationReadOnly
+	}
+
+	return ClassificationMutating
+}
+
+// classifyGH returns the classification of a GitHub CLI command.
+func classifyGH(args []string) Classification {
+	words := positional(args)
+	if len(words) == 0 {
+		return ClassificationReadOnly
+	}
+
+	if words[0] == "api" {
+		return classifyHTTPMethod(flagValue(args, "-X", "--method"), hasAnyFlag(args, "-f", "-F", "--field", "--raw-field", "--input"))
+	}
+
+	if slices.Contains([]string{"search", "status", "browse", "version", "help"}, words[0]) {
+		return ClassificationReadOnly
+	}
+
+	if len(words) > 1 {
+		switch words[1] {
+		case "delete", "delete-asset":
+			return ClassificationDestructive
+		case "list", "view", "status", "diff", "checks", "download", "watch":
+			return ClassificationReadOnly
+		}
+	}
+
+	return ClassificationMutating
+}
+
+// classifyHTTPMethod returns the classification of an HTTP request with the given method.
+func classifyHTTPMethod(method string, hasBody bool) Classification {
+	switch strings.ToUpper(method) {
+	case "DELETE":
+		return ClassificationDestructive
+	case "", "GET", "HEAD", "OPTIONS":
+		if hasBody {
+			return ClassificationMutating
+		}
+		return ClassificationReadOnly
+	}
+
+	return ClassificationMutating
+}
+
+// positional returns the arguments which are not flags.
+func positional(args []string, valueFlags ...string) []string {
+	words := []string{}
+	for i := 0; i < len(args); i++ {
+		switch {
+		case slices.Contains(valueFlags, args[i]):
+			i++
+		case !strings.HasPrefix(args[i], "-"):
+			words = append(words, args[i])
+		}
+	}
+
+	return words
+}
+
+// hasAnyFlag returns true if any of the flags is present in the arguments.
+func hasAnyFlag(args []string, flags ...string) bool {
+	return slices.ContainsFunc(args, func(arg string) bool {
+		name, _, _ := strings.Cut(arg, "=")
+		return slices.Contains(flags, name)
+	})
+}
+
+// hasFlagPrefix returns true if any argument starts with any of the flags.
+func hasFlagPrefix(args []string, flags ...string) bool {
+	return slices.ContainsFunc(args, func(arg string) bool {
+		return slices.ContainsFunc(flags, func(flag string) bool { return strings.HasPrefix(arg, flag) })
+	})
+}
+
+// flagValue returns the value of the first of the flags present in the arguments.
+func flagValue(args []string, flags ...string) string {
+	for i, arg := range args {
+		for _, flag := range flags {
+			switch {
+			case arg == flag && i+1 < len(args):
+				return args[i+1]
+			case strings.HasPrefix(arg, flag+"="):
+				return strings.TrimPrefix(arg, flag+"=")
+			case len(flag) == 2 && strings.HasPrefix(arg, flag) && len(arg) > 2 && !strings.HasPrefix(arg, "--"):
+				return arg[2:]
+			}
+		}
+	}
+
+	return ""
+}
+
+// isAnyOf returns a function which checks if a word is any of the given words.
+func isAnyOf(words ...string) func(string) bool {
+	return func(w string) bool { return slices.Contains(words, w) }
+}
+
+// isShortFlagWith returns a function which checks if an argument is a combined short flag containing the letter.
+func isShortFlagWith(letter rune) func(string) bool {
+	return func(arg string) bool {
+		return strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.ContainsRune(arg[1:], letter)
+	}
+}
+
+// splitCommand splits the shell command into the individual commands of its pipelines and lists.
+func splitCommand(command string, depth int) []segment {
+	segments := []segment{}
+	current := segment{}
+	word := strings.Builder{}
+	inWord := false
+	pendingRedirect, pendingInput := false, false
+	var quote rune
+
+	finishWord := func() {
+		if !inWord {
+			return
+		}
+
+		w := word.String()
+		word.Reset()
+		inWord = false
+
+		switch {
+		case pendingRedirect:
+			pendingRedirect = false
+			if w != "/dev/null" {
+				current.redirect = true
+			}
+		case pendingInput:
+			pendingInput = false
+		default:
+			current.words = append(current.words, w)
+		}
+	}
+
+	finishSegment := func() {
+		finishWord()
+		pendingRedirect, pendingInput = false, false
+		if len(current.words) > 0 || current.redirect {
+			segments = append(segments, expandSegment(current, depth)...)
+		}
+		current = segment{}
+	}
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && i+1 < len(runes):
+				i++
+				word.WriteRune(runes[i])
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\' && i+1 < len(runes):
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case r == ' ' || r == '\t':
+			finishWord()
+		case r == '>':
+			if inWord && isNumber(word.String()) {
+				word.Reset()
+				inWord = false
+			}
+			finishWord()
+			if i+1 < len(runes) && runes[i+1] == '>' {
+				i++
+			}
+			if i+1 < len(runes) && runes[i+1] == '&' {
+				i++
+				for i+1 < len(runes) && (runes[i+1] == '-' || (runes[i+1] >= '0' && runes[i+1] <= '9')) {
+					i++
+				}
+				continue
+			}
+			pendingRedirect = true
+		case r == '<':
+			finishWord()
+			pendingInput = true
+		case r == '&' && i+1 < len(runes) && runes[i+1] == '>':
+			finishWord()
+		case r == ';' || r == '&' || r == '|' || r == '\n' || r == '(' || r == ')' || r == '`':
+			finishSegment()
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '(':
+			finishSegment()
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	finishSegment()
+
+	return segments
+}
+
+// expandSegment removes environment assignments and wrappers from the command and expands `sh -c` commands.
+func expandSegment(s segment, depth int) []segment {
+	words := s.words
+	for len(words) > 0 {
+		switch {
+		case isAssignment(words[0]):
+			words = words[1:]
+		case slices.Contains(wrapperCommands, filepath.Base(words[0])):
+			wrapper := filepath.Base(words[0])
+			words = words[1:]
+			for len(words) > 0 && (strings.HasPrefix(words[0], "-") || isAssignment(words[0])) {
+				if slices.Contains(wrapperValueFlags[wrapper], words[0]) && len(words) > 1 {
+					words = words[1:]
+				}
+				words = words[1:]
+			}
+			if wrapper == "timeout" && len(words) > 0 && isDuration(words[0]) {
+				words = words[1:]
+			}
+		default:
+			s.words = words
+			return expandShell(s, depth)
+		}
+	}
+
+	s.words = words
+	return []segment{s}
+}
+
+// expandShell expands the `-c` argument of a shell into its commands.
+func expandShell(s segment, depth int) []segment {
+	if depth >= maxShellDepth || !slices.Contains(shellCommands, filepath.Base(s.words[0])) {
+		return []segment{s}
+	}
+
+	index := slices.Index(s.words, "-c")
+	if index < 0 || index+1 >= len(s.words) {
+		return []segment{s}
+	}
+
+	segments := splitCommand(s.words[index+1], depth+1)
+	if s.redirect {
+		segments = append(segments, segment{redirect: true})
+	}
+
+	return segments
+}
+
+// isAssignment returns true if the word is an environment variable assignment.
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	return ok && name != "" && !strings.HasPrefix(name, "-") && !strings.ContainsAny(name, "/.:")
+}
+
+// isNumber returns true if the word consists of digits only.
+func isNumber(word string) bool {
+	return word != "" && strings.Trim(word, "0123456789") == ""
+}
+
+// isDuration returns true if the word is a duration as accepted by timeout (e.g. `10`, `1.5m`).
+func isDuration(word string) bool {
+	return isNumber(strings.TrimRight(strings.Replace(word, ".", "", 1), "smhd"))
+}
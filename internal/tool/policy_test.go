@@ -0,0 +1,242 @@
+package tool
+
+import (
+	"testing"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPolicyConfig creates a policy configuration with the default actions.
+func newTestPolicyConfig() config.PolicyConfiguration {
+	return config.PolicyConfiguration{
+		ReadOnly:    string(ActionAllow),
+		Mutating:    string(ActionAllow),
+		Destructive: string(ActionAsk),
+	}
+}
+
+// TestClassifyCommand tests the classification of shell commands.
+func TestClassifyCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected Classification
+	}{
+		{"kubectl get pods -n default", ClassificationReadOnly},
+		{"kubectl -n kube-system describe pod coredns", ClassificationReadOnly},
+		{"kubectl logs deploy/delete-me", ClassificationReadOnly},
+		{"kubectl apply -f deployment.yaml", ClassificationMutating},
+		{"kubectl rollout restart deployment/api", ClassificationMutating},
+		{"kubectl delete pod test", ClassificationDestructive},
+		{"kubectl drain node-1 --ignore-daemonsets", ClassificationDestructive},
+		{"helm list -A", ClassificationReadOnly},
+		{"helm upgrade --install api ./chart", ClassificationMutating},
+		{"helm uninstall api", ClassificationDestructive},
+		{"kubectl -n get delete pod x", ClassificationDestructive},
+		{"kubectl --context view delete pod x", ClassificationDestructive},
+		{"kubectl -n status get pods", ClassificationReadOnly},
+		{"kubectl --namespace events apply -f app.yaml", ClassificationMutating},
+		{"kubectl get pods delete", ClassificationDestructive},
+		{"helm --kube-context get uninstall foo", ClassificationDestructive},
+		{"helm -n status list", ClassificationReadOnly},
+		{"gcloud --project list compute instances delete vm-1", ClassificationDestructive},
+		{"docker --context ps rm api", ClassificationDestructive},
+		{"awk '{print $1}' file.txt", ClassificationReadOnly},
+		{"awk -F: '{print $1}' /etc/passwd", ClassificationReadOnly},
+		{`awk 'BEGIN{system("rm -rf /tmp/x")}'`, ClassificationMutating},
+		{`awk '{print | "sh"}' cmds.txt`, ClassificationMutating},
+		{`awk '{print > "out.txt"}' in.txt`, ClassificationMutating},
+		{"awk -f script.awk in.txt", ClassificationMutating},
+		{"hostname", ClassificationReadOnly},
+		{"hostname -f", ClassificationReadOnly},
+		{"hostname web-2", ClassificationMutating},
+		{"hostname -F /etc/hostname", ClassificationMutating},
+		{"date +%F", ClassificationReadOnly},
+		{`date -d yesterday +%F`, ClassificationReadOnly},
+		{`date -s "2020-01-01 00:00"`, ClassificationMutating},
+		{"date --set=2020-01-01", ClassificationMutating},
+		{"date 010100002020", ClassificationMutating},
+		{"sort -u names.txt", ClassificationReadOnly},
+		{"sort -o names.txt names.txt", ClassificationMutating},
+		{"sort --output=names.txt names.txt", ClassificationMutating},
+		{"yq '.spec' app.yaml", ClassificationReadOnly},
+		{"yq -i '.spec.replicas = 3' app.yaml", ClassificationMutating},
+		{"yq --inplace '.a = 1' app.yaml", ClassificationMutating},
+		{"git status", ClassificationReadOnly},
+		{"git commit -m 'feat: test'", ClassificationMutating},
+		{"git push origin main", ClassificationMutating},
+		{"git push --force origin main", ClassificationDestructive},
+		{"git push -f origin main", ClassificationDestructive},
+		{"git push origin +main", ClassificationDestructive},
+		{"git push origin :feature", ClassificationDestructive},
+		{"git reset --hard HEAD~1", ClassificationDestructive},
+		{"git clean -fdx", ClassificationDestructive},
+		{"git branch", ClassificationReadOnly},
+		{"git branch -D feature", ClassificationDestructive},
+		{"aws ec2 describe-instances --region us-east-1", ClassificationReadOnly},
+		{"aws sts get-caller-identity", ClassificationReadOnly},
+		{"aws ec2 start-instances --instance-ids i-123", ClassificationMutating},
+		{"aws ec2 terminate-instances --instance-ids i-123", ClassificationDestructive},
+		{"aws --profile prod s3 rm s3://bucket/key", ClassificationDestructive},
+		{"aws s3 ls s3://bucket", ClassificationReadOnly},
+		{"gh pr list", ClassificationReadOnly},
+		{"gh pr create --title test", ClassificationMutating},
+		{"gh repo delete datolabs-io/test --yes", ClassificationDestructive},
+		{"gh api repos/datolabs-io/opsy", ClassificationReadOnly},
+		{"gh api -X DELETE repos/datolabs-io/test", ClassificationDestructive},
+		{"gcloud compute instances list", ClassificationReadOnly},
+		{"gcloud compute instances delete vm-1", ClassificationDestructive},
+		{"curl https://example.com", ClassificationReadOnly},
+		{"curl -X POST -d '{}' https://example.com", ClassificationMutating},
+		{"curl -XDELETE https://example.com/resource", ClassificationDestructive},
+		{"find . -name '*.tmp' -delete", ClassificationDestructive},
+		{"sed -i 's/a/b/' file.txt", ClassificationMutating},
+		{"sed 's/a/b/' file.txt", ClassificationReadOnly},
+		{"rm -rf /tmp/test", ClassificationDestructive},
+		{"mkdir -p /tmp/test", ClassificationMutating},
+		{"ls -la", ClassificationReadOnly},
+		{"", ClassificationReadOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ClassifyCommand(tt.command))
+		})
+	}
+}
+
+// TestClassifyCommand_ShellSyntax tests the classification of compound shell commands.
+func TestClassifyCommand_ShellSyntax(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		expected Classification
+	}{
+		{"pipeline", "kubectl get pods -o name | grep api", ClassificationReadOnly},
+		{"pipeline to xargs", "kubectl get pods -o name | xargs kubectl delete", ClassificationDestructive},
+		{"list", "cd /tmp && ls -la; pwd", ClassificationReadOnly},
+		{"or list", "kubectl get ns prod || kubectl create ns prod", ClassificationMutating},
+		{"command substitution", "echo $(kubectl delete pod test)", ClassificationDestructive},
+		{"backticks", "echo `rm -rf /tmp/test`", ClassificationDestructive},
+		{"subshell", "(cd /tmp && helm uninstall api)", ClassificationDestructive},
+		{"quoted separators", "echo 'kubectl delete pod; rm -rf /'", ClassificationReadOnly},
+		{"double quoted separators", `grep "a|b" file.txt`, ClassificationReadOnly},
+		{"output redirection", "kubectl get pods -o yaml > pods.yaml", ClassificationMutating},
+		{"append redirection", "echo test >> file.txt", ClassificationMutating},
+		{"redirection to /dev/null", "kubectl get pods > /dev/null 2>&1", ClassificationReadOnly},
+		{"stderr redirection", "ls missing 2>/dev/null", ClassificationReadOnly},
+		{"input redirection", "jq . < delete.json", ClassificationReadOnly},
+		{"environment assignment", "KUBECONFIG=~/.kube/prod kubectl delete pod test", ClassificationDestructive},
+		{"sudo wrapper", "sudo -u root rm -rf /var/log/test", ClassificationDestructive},
+		{"timeout wrapper", "timeout 10 kubectl get pods", ClassificationReadOnly},
+		{"shell command", `bash -c "kubectl delete pod test"`, ClassificationDestructive},
+		{"absolute path", "/usr/local/bin/kubectl delete pod test", ClassificationDestructive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ClassifyCommand(tt.command))
+		})
+	}
+}
+
+// TestNewPolicy tests the creation of a new policy.
+func TestNewPolicy(t *testing.T) {
+	t.Run("compiles rules", func(t *testing.T) {
+		cfg := newTestPolicyConfig()
+		cfg.Rules = []config.PolicyRule{{Match: `^kubectl get`, Action: "deny"}}
+		policy, err := NewPolicy(cfg, []config.PolicyRule{{Match: `^git push`, Action: "ask"}})
+		require.NoError(t, err)
+		require.Len(t, policy.rules, 2)
+		assert.Equal(t, `^git push`, policy.rules[0].match.String())
+		assert.Equal(t, `^kubectl get`, policy.rules[1].match.String())
+	})
+
+	t.Run("returns error for invalid rule", func(t *testing.T) {
+		_, err := NewPolicy(newTestPolicyConfig(), []config.PolicyRule{{Match: `(`, Action: "deny"}})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), ErrInvalidPolicyRule)
+	})
+}
+
+// TestPolicy_Evaluate tests the evaluation of commands against the policy.
+func TestPolicy_Evaluate(t *testing.T) {
+	t.Run("applies default actions", func(t *testing.T) {
+		policy, err := NewPolicy(newTestPolicyConfig(), nil)
+		require.NoError(t, err)
+
+		verdict := policy.Evaluate("kubectl get pods")
+		assert.Equal(t, Verdict{Classification: ClassificationReadOnly, Action: ActionAllow}, verdict)
+
+		verdict = policy.Evaluate("kubectl delete pod test")
+		assert.Equal(t, ClassificationDestructive, verdict.Classification)
+		assert.Equal(t, ActionAsk, verdict.Action)
+		assert.Contains(t, verdict.Reason, "classified as destructive")
+	})
+
+	t.Run("treats missing actions as allow", func(t *testing.T) {
+		policy, err := NewPolicy(config.PolicyConfiguration{}, nil)
+		require.NoError(t, err)
+
+		verdict := policy.Evaluate("rm -rf /tmp/test")
+		assert.Equal(t, ClassificationDestructive, verdict.Classification)
+		assert.Equal(t, ActionAllow, verdict.Action)
+	})
+
+	t.Run("applies matching rule", func(t *testing.T) {
+		cfg := newTestPolicyConfig()
+		cfg.Rules = []config.PolicyRule{{Match: `^kubectl .*--context[ =]prod`, Action: "deny", Reason: "production is read-only"}}
+		policy, err := NewPolicy(cfg, nil)
+		require.NoError(t, err)
+
+		verdict := policy.Evaluate("kubectl apply -f app.yaml --context prod")
+		assert.Equal(t, ClassificationMutating, verdict.Classification)
+		assert.Equal(t, ActionDeny, verdict.Action)
+		assert.Equal(t, "production is read-only", verdict.Reason)
+	})
+
+	t.Run("rule overrides default action", func(t *testing.T) {
+		cfg := newTestPolicyConfig()
+		cfg.Rules = []config.PolicyRule{{Match: `^kubectl delete pod`, Action: "allow"}}
+		policy, err := NewPolicy(cfg, nil)
+		require.NoError(t, err)
+
+		verdict := policy.Evaluate("kubectl delete pod test")
+		assert.Equal(t, ClassificationDestructive, verdict.Classification)
+		assert.Equal(t, ActionAllow, verdict.Action)
+	})
+
+	t.Run("tool rules take precedence", func(t *testing.T) {
+		cfg := newTestPolicyConfig()
+		cfg.Rules = []config.PolicyRule{{Match: `^git push`, Action: "allow"}}
+		policy, err := NewPolicy(cfg, []config.PolicyRule{{Match: `^git push .*main`, Action: "deny"}})
+		require.NoError(t, err)
+
+		assert.Equal(t, ActionDeny, policy.Evaluate("git push origin main").Action)
+		assert.Equal(t, ActionAllow, policy.Evaluate("git push origin feature").Action)
+	})
+
+	t.Run("uses most restrictive action of all commands", func(t *testing.T) {
+		cfg := newTestPolicyConfig()
+		cfg.Rules = []config.PolicyRule{{Match: `^rm `, Action: "deny", Reason: "no deletions"}}
+		policy, err := NewPolicy(cfg, nil)
+		require.NoError(t, err)
+
+		verdict := policy.Evaluate("kubectl delete pod test && rm -rf /tmp/test")
+		assert.Equal(t, ClassificationDestructive, verdict.Classification)
+		assert.Equal(t, ActionDeny, verdict.Action)
+		assert.Equal(t, "no deletions", verdict.Reason)
+	})
+
+	t.Run("matches rules against normalized commands", func(t *testing.T) {
+		cfg := newTestPolicyConfig()
+		cfg.Rules = []config.PolicyRule{{Match: `^kubectl delete`, Action: "deny"}}
+		policy, err := NewPolicy(cfg, nil)
+		require.NoError(t, err)
+
+		verdict := policy.Evaluate("cd /tmp && KUBECONFIG=prod sudo kubectl   delete pod test")
+		assert.Equal(t, ActionDeny, verdict.Action)
+		assert.Contains(t, verdict.Reason, "`kubectl delete pod test` matches the policy rule")
+	})
+}
@@ -0,0 +1,217 @@
+package tool
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/cancelreader"
+	"golang.org/x/sys/unix"
+)
+
+// outputDrainTimeout is the time left to read the remaining output of the pseudo-terminal once the command has exited.
+const outputDrainTimeout = time.Second
+
+// TerminalSession runs an interactive command in a pseudo-terminal connected to the terminal of the user, recording a
+// transcript of everything the command displays. It implements tea.ExecCommand, so that a Bubble Tea program can
+// release the terminal while the session runs.
+type TerminalSession struct {
+	cmd    *exec.Cmd
+	stdin  io.Reader
+	stdout io.Writer
+
+	mu         sync.Mutex
+	transcript bytes.Buffer
+	started    bool
+	exitCode   int
+}
+
+// newTerminalSession creates a new session running the command in a pseudo-terminal.
+func newTerminalSession(cmd *exec.Cmd) *TerminalSession {
+	return &TerminalSession{cmd: cmd, stdin: os.Stdin, stdout: os.Stdout}
+}
+
+// SetStdin sets the input of the terminal of the user.
+func (s *TerminalSession) SetStdin(r io.Reader) {
+	s.stdin = r
+}
+
+// SetStdout sets the output of the terminal of the user.
+func (s *TerminalSession) SetStdout(w io.Writer) {
+	s.stdout = w
+}
+
+// SetStderr is a no-op: the command writes both its output streams to the pseudo-terminal.
+func (s *TerminalSession) SetStderr(io.Writer) {}
+
+// Run runs the command in a pseudo-terminal until it exits, forwarding the input of the user to the command and its
+// output to the user. The terminal of the user is put into raw mode meanwhile, so that every key reaches the command.
+// It only returns an error if the command could not be started; its exit code is returned by ExitCode.
+func (s *TerminalSession) Run() error {
+	ptmx, tty, err := openPTY()
+	if err != nil {
+		return err
+	}
+	defer ptmx.Close()
+
+	s.cmd.Stdin, s.cmd.Stdout, s.cmd.Stderr = tty, tty, tty
+	s.cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+
+	if f, ok := s.stdin.(*os.File); ok && term.IsTerminal(f.Fd()) {
+		state, err := term.MakeRaw(f.Fd())
+		if err == nil {
+			defer func() { _ = term.Restore(f.Fd(), state) }()
+		}
+		defer watchResize(ptmx, f)()
+	}
+
+	err = s.cmd.Start()
+	tty.Close()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.started = true
+	s.mu.Unlock()
+
+	if input, err := cancelreader.NewReader(s.stdin); err == nil {
+		defer input.Cancel()
+		go func() { _, _ = io.Copy(ptmx, input) }()
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		_, _ = io.Copy(io.MultiWriter(s.stdout, &transcriptWriter{session: s}), ptmx)
+	}()
+
+	err = s.cmd.Wait()
+	select {
+	case <-drained:
+	case <-time.After(outputDrainTimeout):
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exitCode = s.cmd.ProcessState.ExitCode()
+	if exitErr := (&exec.ExitError{}); err != nil && !errors.As(err, &exitErr) {
+		s.exitCode = -1
+	}
+
+	return nil
+}
+
+// Started returns true once the command has been started.
+func (s *TerminalSession) Started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.started
+}
+
+// ExitCode returns the exit code of the command once it has exited, -1 if it was terminated by a signal.
+func (s *TerminalSession) ExitCode() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.exitCode
+}
+
+// Transcript returns what the command displayed in the terminal, as plain text.
+func (s *TerminalSession) Transcript() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return cleanTranscript(s.transcript.String())
+}
+
+// transcriptWriter records the output of the command in the transcript of the session.
+type transcriptWriter struct {
+	session *TerminalSession
+}
+
+// Write appends the output to the transcript.
+func (w *transcriptWriter) Write(p []byte) (int, error) {
+	w.session.mu.Lock()
+	defer w.session.mu.Unlock()
+
+	return w.session.transcript.Write(p)
+}
+
+// watchResize resizes the pseudo-terminal to the size of the terminal of the user now and whenever the terminal is
+// resized, until the returned function is called.
+func watchResize(ptmx, terminal *os.File) (stop func()) {
+	resize := make(chan os.Signal, 1)
+	signal.Notify(resize, syscall.SIGWINCH)
+	resizePTY(ptmx, terminal)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range resize {
+			resizePTY(ptmx, terminal)
+		}
+	}()
+
+	return func() {
+		// No signal is sent to the channel once Stop returns, so it can be closed to end the goroutine.
+		signal.Stop(resize)
+		close(resize)
+		<-done
+	}
+}
+
+// resizePTY sets the size of the pseudo-terminal to the size of the terminal of the user.
+func resizePTY(ptmx, terminal *os.File) {
+	width, height, err := term.GetSize(terminal.Fd())
+	if err != nil {
+		return
+	}
+
+	conn, err := ptmx.SyscallConn()
+	if err != nil {
+		return
+	}
+	_ = conn.Control(func(fd uintptr) {
+		_ = unix.IoctlSetWinsize(int(fd), unix.TIOCSWINSZ, &unix.Winsize{Row: uint16(height), Col: uint16(width)})
+	})
+}
+
+// cleanTranscript turns the raw output of a terminal into plain text: escape sequences are removed, and carriage
+// returns and backspaces are applied, so that only the final state of each line is kept.
+func cleanTranscript(raw string) string {
+	raw = strings.ReplaceAll(ansi.Strip(raw), "\r\n", "\n")
+
+	lines := strings.Split(raw, "\n")
+	for i, line := range lines {
+		if segments := strings.Split(line, "\r"); len(segments) > 1 {
+			line = ""
+			for _, segment := range segments {
+				if segment != "" {
+					line = segment
+				}
+			}
+		}
+
+		runes := []rune{}
+		for _, r := range line {
+			if r == '\b' {
+				runes = runes[:max(len(runes)-1, 0)]
+				continue
+			}
+			runes = append(runes, r)
+		}
+		lines[i] = strings.TrimRight(string(runes), " \t")
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,54 @@
+//go:build darwin
+
+package tool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY opens a new pseudo-terminal, returning its controller and the terminal to connect the command to.
+func openPTY() (ptmx, tty *os.File, err error) {
+	ptmx, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := ptmx.SyscallConn()
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+
+	name := make([]byte, 128)
+	var ioctlErr error
+	if err := conn.Control(func(fd uintptr) {
+		if ioctlErr = unix.IoctlSetInt(int(fd), unix.TIOCPTYGRANT, 0); ioctlErr != nil {
+			return
+		}
+		if ioctlErr = unix.IoctlSetInt(int(fd), unix.TIOCPTYUNLK, 0); ioctlErr != nil {
+			return
+		}
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(unix.TIOCPTYGNAME),
+			uintptr(unsafe.Pointer(&name[0]))); errno != 0 {
+			ioctlErr = errno
+		}
+	}); err != nil || ioctlErr != nil {
+		ptmx.Close()
+		return nil, nil, fmt.Errorf("failed to unlock the pseudo-terminal: %w", errors.Join(err, ioctlErr))
+	}
+
+	tty, err = os.OpenFile(string(bytes.TrimRight(name, "\x00")), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+
+	return ptmx, tty, nil
+}
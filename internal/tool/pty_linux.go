@@ -0,0 +1,46 @@
+//go:build linux
+
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY opens a new pseudo-terminal, returning its controller and the terminal to connect the command to.
+func openPTY() (ptmx, tty *os.File, err error) {
+	ptmx, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := ptmx.SyscallConn()
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+
+	var number uint32
+	var ioctlErr error
+	if err := conn.Control(func(fd uintptr) {
+		if ioctlErr = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0); ioctlErr != nil {
+			return
+		}
+		number, ioctlErr = unix.IoctlGetUint32(int(fd), unix.TIOCGPTN)
+	}); err != nil || ioctlErr != nil {
+		ptmx.Close()
+		return nil, nil, fmt.Errorf("failed to unlock the pseudo-terminal: %w", errors.Join(err, ioctlErr))
+	}
+
+	tty, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+
+	return ptmx, tty, nil
+}
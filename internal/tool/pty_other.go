@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package tool
+
+import (
+	"errors"
+	"os"
+)
+
+// openPTY returns an error, since pseudo-terminals are only supported on Linux and macOS.
+func openPTY() (ptmx, tty *os.File, err error) {
+	return nil, nil, errors.New(ErrTerminalUnsupported)
+}
@@ -0,0 +1,92 @@
+package tool
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTerminalSession tests running commands in a pseudo-terminal
+func TestTerminalSession(t *testing.T) {
+	t.Run("runs the command in a terminal and records the transcript", func(t *testing.T) {
+		session := newTerminalSession(exec.Command("/bin/sh", "-c", `test -t 0 && echo "tty"; read name; echo "hello $name"`))
+		screen := &bytes.Buffer{}
+		session.SetStdin(strings.NewReader("opsy\n"))
+		session.SetStdout(screen)
+
+		require.NoError(t, session.Run())
+		assert.True(t, session.Started())
+		assert.Equal(t, 0, session.ExitCode())
+		// The terminal echoes the input as soon as it is written, possibly before the command writes its first line.
+		assert.ElementsMatch(t, []string{"tty", "opsy", "hello opsy"}, strings.Split(session.Transcript(), "\n"))
+		assert.Contains(t, screen.String(), "hello opsy")
+	})
+
+	t.Run("returns the exit code of the command", func(t *testing.T) {
+		session := newTerminalSession(exec.Command("/bin/sh", "-c", "exit 3"))
+		session.SetStdin(strings.NewReader(""))
+		session.SetStdout(&bytes.Buffer{})
+
+		require.NoError(t, session.Run())
+		assert.Equal(t, 3, session.ExitCode())
+	})
+
+	t.Run("returns an error when the command cannot be started", func(t *testing.T) {
+		session := newTerminalSession(exec.Command("/nonexistent"))
+		session.SetStdin(strings.NewReader(""))
+		session.SetStdout(&bytes.Buffer{})
+
+		assert.Error(t, session.Run())
+		assert.False(t, session.Started())
+	})
+}
+
+// TestWatchResize tests following the size of the terminal of the user
+func TestWatchResize(t *testing.T) {
+	t.Run("stops watching once stopped", func(t *testing.T) {
+		ptmx, tty, err := openPTY()
+		require.NoError(t, err)
+		defer ptmx.Close()
+		defer tty.Close()
+
+		stop := watchResize(ptmx, tty)
+		require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGWINCH))
+
+		stopped := make(chan struct{})
+		go func() {
+			stop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("the resize goroutine did not exit")
+		}
+	})
+}
+
+// TestCleanTranscript tests turning the output of a terminal into plain text
+func TestCleanTranscript(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		expected string
+	}{
+		{name: "removes escape sequences", raw: "\x1b[1;32mok\x1b[0m\r\n", expected: "ok"},
+		{name: "keeps the last state of lines rewritten with carriage returns", raw: "10%\r50%\r100%\r\ndone", expected: "100%\ndone"},
+		{name: "applies backspaces", raw: "pasx\bsword\r\n", expected: "password"},
+		{name: "trims trailing spaces", raw: "  list  \r\n\r\n", expected: "list"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, cleanTranscript(tt.raw))
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package tool
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/datolabs-io/opsy/internal/config"
+)
+
+const (
+	// redactedSecret is the format of the marker replacing a redacted secret.
+	redactedSecret = "[REDACTED:%s]"
+	// customPatternName is the name of the user-defined patterns without a name.
+	customPatternName = "custom"
+	// minSecretDataLength is the minimum length of a value of Kubernetes secret data to be redacted.
+	minSecretDataLength = 4
+)
+
+// detector is a pattern of secrets redacted from the output of commands.
+type detector struct {
+	// name is the name shown in place of the redacted secrets.
+	name string
+	// pattern is the regular expression matching the secrets.
+	pattern *regexp.Regexp
+	// group is the capture group holding the secret, 0 for the whole match.
+	group int
+}
+
+// builtinDetectors are the detectors of the secrets commonly printed by infrastructure tools.
+var builtinDetectors = []detector{
+	{name: "pem-block", pattern: regexp.MustCompile(`-----BEGIN [A-Z0-9 ]+-----(.+?)-----END [A-Z0-9 ]+-----`), group: 1},
+	{name: "pem-block", pattern: regexp.MustCompile(`\bLS0tLS1CRUdJT[A-Za-z0-9+/]+=*`)},
+	{name: "aws-access-key-id", pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{
+		name:    "aws-secret-access-key",
+		pattern: regexp.MustCompile(`(?i)(?:aws_secret_access_key|secret_?access_?key)["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`),
+		group:   1,
+	},
+	{
+		name:    "aws-session-token",
+		pattern: regexp.MustCompile(`(?i)(?:aws_session_token|session_?token)["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{16,})`),
+		group:   1,
+	},
+	{name: "jwt", pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)},
+	{name: "github-token", pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+}
+
+var (
+	// pemBegin matches the start of a PEM block whose end is on a following line.
+	pemBegin = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]+-----\s*$`)
+	// pemEnd matches the end of a PEM block.
+	pemEnd = regexp.MustCompile(`-----END [A-Z0-9 ]+-----`)
+	// secretDataYAML matches the start of the data of a Kubernetes secret in YAML.
+	secretDataYAML = regexp.MustCompile(`^(\s*)data:\s*$`)
+	// secretDataJSON matches the start of the data of a Kubernetes secret in indented JSON.
+	secretDataJSON = regexp.MustCompile(`^(\s*)"data":\s*\{\s*$`)
+	// secretDataInline matches the data of a Kubernetes secret in compact JSON.
+	secretDataInline = regexp.MustCompile(`"data":\s*\{[^{}]*\}`)
+	// secretValueYAML matches a key and value of the data of a Kubernetes secret in YAML.
+	secretValueYAML = regexp.MustCompile(`^(\s*[^\s:]+:\s*)(\S+)\s*$`)
+	// secretValueJSON matches a key and value of the data of a Kubernetes secret in JSON.
+	secretValueJSON = regexp.MustCompile(`("[^"]+":\s*")([^"]*)(")`)
+)
+
+// Redactor redacts secrets from the output of commands, such as AWS keys, JWTs, PEM blocks, Kubernetes secret data
+// and GitHub tokens, plus the user-defined patterns. All the methods of a nil Redactor return the output unchanged.
+type Redactor struct {
+	detectors []detector
+}
+
+// lineRedactor redacts the lines of a single output in order, following the secrets which span several lines.
+type lineRedactor struct {
+	redactor *Redactor
+	// inPEM is true within the body of a PEM block.
+	inPEM bool
+	// pemRedacted is true once the marker of the current PEM block was returned.
+	pemRedacted bool
+	// dataIndent is the indentation of the data of a Kubernetes secret in YAML, -1 outside of it.
+	dataIndent int
+	// inDataJSON is true within the data of a Kubernetes secret in indented JSON.
+	inDataJSON bool
+}
+
+// NewRedactor creates a new Redactor from the configuration. It returns nil if redaction is disabled, and an error if
+// a user-defined pattern is invalid.
+func NewRedactor(cfg config.RedactionConfiguration) (*Redactor, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	if err := config.ValidateRedactionPatterns(cfg.Patterns); err != nil {
+		return nil, err
+	}
+
+	r := &Redactor{detectors: append([]detector{}, builtinDetectors...)}
+	for _, pattern := range cfg.Patterns {
+		d := detector{name: pattern.Name, pattern: regexp.MustCompile(pattern.Match)}
+		if d.name == "" {
+			d.name = customPatternName
+		}
+		if d.pattern.NumSubexp() > 0 {
+			d.group = 1
+		}
+		r.detectors = append(r.detectors, d)
+	}
+
+	return r, nil
+}
+
+// Redact returns the output with its secrets redacted. The body of a PEM block is replaced by a single marker line.
+func (r *Redactor) Redact(output string) string {
+	if r == nil || output == "" {
+		return output
+	}
+
+	lines := r.lines()
+	redacted := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if line, keep := lines.Redact(line); keep {
+			redacted = append(redacted, line)
+		}
+	}
+
+	return strings.Join(redacted, "\n")
+}
+
+// lines returns a redactor of the lines of a single output.
+func (r *Redactor) lines() *lineRedactor {
+	return &lineRedactor{redactor: r, dataIndent: -1}
+}
+
+// Redact returns the line with its secrets redacted, and false if the line must be dropped because it continues a
+// redacted PEM block.
+func (l *lineRedactor) Redact(line string) (string, bool) {
+	if l.redactor == nil {
+		return line, true
+	}
+
+	if l.inPEM {
+		if index := pemEnd.FindStringIndex(line); index != nil {
+			l.inPEM = false
+			return line[index[0]:], true
+		}
+		if l.pemRedacted {
+			return "", false
+		}
+		l.pemRedacted = true
+		return fmt.Sprintf(redactedSecret, "pem-block"), true
+	}
+
+	line = l.redactSecretData(line)
+	for _, d := range l.redactor.detectors {
+		line = d.redact(line)
+	}
+
+	if pemBegin.MatchString(line) {
+		l.inPEM, l.pemRedacted = true, false
+	}
+
+	return line, true
+}
+
+// redactSecretData redacts the values of the data of Kubernetes secrets, printed by e.g. `kubectl get secret -o yaml`.
+// Values which are not valid base64 are kept, so that the data of config maps is mostly left readable.
+func (l *lineRedactor) redactSecretData(line string) string {
+	line = secretDataInline.ReplaceAllStringFunc(line, func(data string) string {
+		return secretValueJSON.ReplaceAllStringFunc(data, func(value string) string {
+			return redactSecretValue(secretValueJSON, value, 2)
+		})
+	})
+
+	switch {
+	case l.inDataJSON:
+		if strings.HasPrefix(strings.TrimSpace(line), "}") {
+			l.inDataJSON = false
+			return line
+		}
+		return secretValueJSON.ReplaceAllStringFunc(line, func(value string) string {
+			return redactSecretValue(secretValueJSON, value, 2)
+		})
+	case l.dataIndent >= 0 && strings.TrimSpace(line) != "":
+		if len(line)-len(strings.TrimLeft(line, " \t")) <= l.dataIndent {
+			l.dataIndent = -1
+			break
+		}
+		return redactSecretValue(secretValueYAML, line, 2)
+	}
+
+	if match := secretDataYAML.FindStringSubmatch(line); match != nil {
+		l.dataIndent = len(match[1])
+	} else if secretDataJSON.MatchString(line) {
+		l.inDataJSON = true
+	}
+
+	return line
+}
+
+// redactSecretValue redacts the given capture group of the match of the pattern if it is valid base64.
+func redactSecretValue(pattern *regexp.Regexp, text string, group int) string {
+	index := pattern.FindStringSubmatchIndex(text)
+	if index == nil || index[2*group] < 0 {
+		return text
+	}
+
+	value := text[index[2*group]:index[2*group+1]]
+	if _, err := base64.StdEncoding.DecodeString(value); err != nil || len(value) < minSecretDataLength {
+		return text
+	}
+
+	return text[:index[2*group]] + fmt.Sprintf(redactedSecret, "secret-data") + text[index[2*group+1]:]
+}
+
+// redact replaces the secrets matched by the detector in the line.
+func (d detector) redact(line string) string {
+	marker := fmt.Sprintf(redactedSecret, d.name)
+	if d.group == 0 {
+		return d.pattern.ReplaceAllLiteralString(line, marker)
+	}
+
+	result := strings.Builder{}
+	last := 0
+	for _, index := range d.pattern.FindAllStringSubmatchIndex(line, -1) {
+		start, end := index[2*d.group], index[2*d.group+1]
+		if start < 0 {
+			continue
+		}
+		result.WriteString(line[last:start])
+		result.WriteString(marker)
+		last = end
+	}
+	result.WriteString(line[last:])
+
+	return result.String()
+}
@@ -0,0 +1,123 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/datolabs-io/opsy/internal/config"
+)
+
+const (
+	// ErrSandboxUnavailable is the error returned when the sandbox is enabled but bubblewrap cannot be found.
+	ErrSandboxUnavailable = "sandbox is enabled but bubblewrap is not available"
+
+	// sandboxExecutable is the executable of bubblewrap.
+	sandboxExecutable = "bwrap"
+)
+
+// sandboxEnv are the environment variables always passed to sandboxed commands, if set.
+var sandboxEnv = []string{"PATH", "HOME", "USER", "LOGNAME", "LANG", "LC_ALL", "TERM", "TZ", "TMPDIR"}
+
+// Sandbox executes commands in a restricted environment using bubblewrap: the root filesystem is read-only except for
+// the working directory and the writable paths, the environment is filtered and the network is optionally isolated.
+type Sandbox struct {
+	config     config.SandboxConfiguration
+	executable string
+}
+
+// NewSandbox creates a new Sandbox from the configuration. It returns nil if the sandbox is disabled.
+func NewSandbox(cfg config.SandboxConfiguration) *Sandbox {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	return &Sandbox{config: cfg, executable: sandboxExecutable}
+}
+
+// Command returns the command executing the shell command in the sandbox, with the given working directory.
+func (s *Sandbox) Command(ctx context.Context, shell, command, workingDirectory string) (*exec.Cmd, error) {
+	return s.command(ctx, shell, command, workingDirectory, false)
+}
+
+// TerminalCommand returns the command executing the interactive shell command in the sandbox, with the given working
+// directory. Unlike Command, the command keeps the controlling terminal, which is the pseudo-terminal of the session
+// rather than the terminal of the user, so that it can read from it and use job control.
+func (s *Sandbox) TerminalCommand(ctx context.Context, shell, command, workingDirectory string) (*exec.Cmd, error) {
+	return s.command(ctx, shell, command, workingDirectory, true)
+}
+
+// command returns the command executing the shell command in the sandbox.
+func (s *Sandbox) command(ctx context.Context, shell, command, workingDirectory string, interactive bool) (*exec.Cmd, error) {
+	path, err := exec.LookPath(s.executable)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrSandboxUnavailable, err)
+	}
+
+	cmd := exec.CommandContext(ctx, path, s.args(shell, command, workingDirectory, interactive)...)
+	cmd.Env = s.env(os.Environ())
+
+	return cmd, nil
+}
+
+// args returns the arguments of bubblewrap executing the shell command. Commands run in a new session, so that they
+// cannot inject input into the terminal of the user, unless they are interactive.
+func (s *Sandbox) args(shell, command, workingDirectory string, interactive bool) []string {
+	args := []string{
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+		"--unshare-pid",
+		"--die-with-parent",
+	}
+	if !interactive {
+		args = append(args, "--new-session")
+	}
+	if s.config.IsolateNetwork {
+		args = append(args, "--unshare-net")
+	}
+
+	for _, path := range s.config.Writable {
+		path = expandHome(path)
+		args = append(args, "--bind-try", path, path)
+	}
+	args = append(args, "--bind", workingDirectory, workingDirectory, "--chdir", workingDirectory)
+
+	return append(args, "--", shell, "-c", command)
+}
+
+// env returns the variables of the environment passed to the sandboxed commands.
+func (s *Sandbox) env(environ []string) []string {
+	allowed := append(append([]string{}, sandboxEnv...), s.config.Env...)
+
+	env := []string{}
+	for _, variable := range environ {
+		if name, _, _ := strings.Cut(variable, "="); slices.Contains(allowed, name) {
+			env = append(env, variable)
+		}
+	}
+
+	return env
+}
+
+// expandHome replaces a leading ~ of the path with the home directory of the user.
+func expandHome(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	if path == "~" {
+		return home
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(home, rest)
+	}
+
+	return path
+}
@@ -0,0 +1,88 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSandbox tests building the sandboxed commands.
+func TestSandbox(t *testing.T) {
+	t.Run("is nil when disabled", func(t *testing.T) {
+		assert.Nil(t, NewSandbox(config.SandboxConfiguration{}))
+	})
+
+	t.Run("builds the bubblewrap arguments", func(t *testing.T) {
+		home, err := os.UserHomeDir()
+		require.NoError(t, err)
+
+		sandbox := NewSandbox(config.SandboxConfiguration{
+			Enabled:        true,
+			IsolateNetwork: true,
+			Writable:       []string{"~/.kube/cache", "/var/tmp"},
+		})
+		args := sandbox.args("/bin/sh", "git status", "/srv/repo", false)
+		assert.Equal(t, []string{
+			"--ro-bind", "/", "/",
+			"--dev", "/dev",
+			"--proc", "/proc",
+			"--tmpfs", "/tmp",
+			"--unshare-pid",
+			"--die-with-parent",
+			"--new-session",
+			"--unshare-net",
+			"--bind-try", filepath.Join(home, ".kube/cache"), filepath.Join(home, ".kube/cache"),
+			"--bind-try", "/var/tmp", "/var/tmp",
+			"--bind", "/srv/repo", "/srv/repo",
+			"--chdir", "/srv/repo",
+			"--", "/bin/sh", "-c", "git status",
+		}, args)
+	})
+
+	t.Run("shares the network unless isolated", func(t *testing.T) {
+		args := NewSandbox(config.SandboxConfiguration{Enabled: true}).args("/bin/sh", "true", "/srv", false)
+		assert.NotContains(t, args, "--unshare-net")
+	})
+
+	t.Run("keeps the controlling terminal of interactive commands", func(t *testing.T) {
+		sandbox := NewSandbox(config.SandboxConfiguration{Enabled: true})
+		assert.Contains(t, sandbox.args("/bin/sh", "true", "/srv", false), "--new-session")
+		assert.NotContains(t, sandbox.args("/bin/sh", "true", "/srv", true), "--new-session")
+	})
+
+	t.Run("filters the environment", func(t *testing.T) {
+		sandbox := NewSandbox(config.SandboxConfiguration{Enabled: true, Env: []string{"KUBECONFIG"}})
+		env := sandbox.env([]string{"PATH=/usr/bin", "HOME=/home/me", "KUBECONFIG=/k", "AWS_SECRET_ACCESS_KEY=s", "PATHX=x"})
+		assert.Equal(t, []string{"PATH=/usr/bin", "HOME=/home/me", "KUBECONFIG=/k"}, env)
+	})
+
+	t.Run("returns error without bubblewrap", func(t *testing.T) {
+		sandbox := NewSandbox(config.SandboxConfiguration{Enabled: true})
+		sandbox.executable = "opsy-missing-bwrap"
+		_, err := sandbox.Command(context.Background(), "/bin/sh", "true", t.TempDir())
+		assert.ErrorContains(t, err, ErrSandboxUnavailable)
+	})
+
+	t.Run("executes commands with a read-only root", func(t *testing.T) {
+		if _, err := exec.LookPath(sandboxExecutable); err != nil {
+			t.Skip("bubblewrap is not available")
+		}
+		if err := exec.Command(sandboxExecutable, "--ro-bind", "/", "/", "true").Run(); err != nil {
+			t.Skipf("bubblewrap cannot create namespaces: %v", err)
+		}
+
+		dir, readOnly := t.TempDir(), t.TempDir()
+		cmd, err := NewSandbox(config.SandboxConfiguration{Enabled: true}).Command(context.Background(), "/bin/sh",
+			"touch written && touch "+filepath.Join(readOnly, "blocked"), dir)
+		require.NoError(t, err)
+		assert.Error(t, cmd.Run())
+		assert.FileExists(t, filepath.Join(dir, "written"))
+		assert.NoFileExists(t, filepath.Join(readOnly, "blocked"))
+	})
+}
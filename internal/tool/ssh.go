@@ -0,0 +1,174 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	// ErrSSHUnavailable is the error returned when the ssh client cannot be found.
+	ErrSSHUnavailable = "ssh is not available"
+	// ErrSSHEnvUnsupported is the error returned when a command with environment variables targets a remote host.
+	ErrSSHEnvUnsupported = "environment variables of the tool cannot be passed to remote hosts"
+
+	// sshExecutable is the executable of the OpenSSH client.
+	sshExecutable = "ssh"
+	// remoteHomeDirectory is the working directory shown for remote commands executed in the home directory.
+	remoteHomeDirectory = "~"
+)
+
+// SSHBackend executes the commands on a remote host over SSH, using the OpenSSH client, optionally through jump hosts.
+// The client runs in batch mode, so a host which asks for a password or an unknown host key fails instead of hanging.
+// The commands are executed by a POSIX shell script which terminates them once the connection is closed, e.g. when the
+// local client is terminated because the command was cancelled or timed out.
+type SSHBackend struct {
+	name       string
+	hosts      map[string]config.HostConfiguration
+	executable string
+}
+
+// NewSSHBackend creates a new SSHBackend executing the commands on the named host. The other hosts are used to
+// resolve its jump hosts.
+func NewSSHBackend(name string, hosts map[string]config.HostConfiguration) *SSHBackend {
+	return &SSHBackend{name: name, hosts: hosts, executable: sshExecutable}
+}
+
+// Command returns the process executing the shell command on the host. The working directory is a path on the host;
+// the command is executed in the home directory of the user if it is empty or ~. It returns an error if environment
+// variables are given: most SSH servers only accept a few of them, and passing them on the command line would expose
+// their values, which may be secrets, in the process list of both hosts.
+func (b *SSHBackend) Command(ctx context.Context, command, workingDirectory string, env []string) (*exec.Cmd, error) {
+	if len(env) > 0 {
+		return nil, errors.New(ErrSSHEnvUnsupported)
+	}
+
+	path, err := exec.LookPath(b.executable)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrSSHUnavailable, err)
+	}
+
+	return exec.CommandContext(ctx, path, b.args(command, workingDirectory)...), nil
+}
+
+// args returns the arguments of the ssh client executing the shell command.
+func (b *SSHBackend) args(command, workingDirectory string) []string {
+	host := b.hosts[b.name]
+	args := append([]string{"-T"}, hostArgs(host)...)
+	if len(host.Jump) > 0 {
+		args = append(args, "-o", "ProxyCommand="+b.proxyCommand(host.Jump))
+	}
+
+	return append(args, "--", host.Host, remoteCommand(command, workingDirectory))
+}
+
+// hostArgs returns the options of the ssh client connecting to the host: batch mode, and its key, port and user.
+func hostArgs(host config.HostConfiguration) []string {
+	args := []string{"-o", "BatchMode=yes"}
+	if host.Key != "" {
+		args = append(args, "-i", expandHome(host.Key), "-o", "IdentitiesOnly=yes")
+	}
+	if host.Port > 0 {
+		args = append(args, "-p", strconv.Itoa(host.Port))
+	}
+	if host.User != "" {
+		args = append(args, "-l", host.User)
+	}
+
+	return args
+}
+
+// proxyCommand returns the ProxyCommand reaching a host through the jump hosts: a client connected to the last jump
+// host forwards the connection, and reaches that host through the other jump hosts in the same way. Unlike ProxyJump,
+// the clients connect to the jump hosts named in the configuration with their own key, port and user.
+func (b *SSHBackend) proxyCommand(jumps []string) string {
+	name := jumps[len(jumps)-1]
+	jump, ok := b.hosts[name]
+	if !ok {
+		jump = parseDestination(name)
+	}
+
+	args := append([]string{b.executable}, hostArgs(jump)...)
+	if len(jumps) > 1 {
+		// The tokens of the inner ProxyCommand are escaped, since they are expanded by the client of the jump host:
+		args = append(args, "-o", "ProxyCommand="+strings.ReplaceAll(b.proxyCommand(jumps[:len(jumps)-1]), "%", "%%"))
+	}
+	args = append(args, "-W", "%h:%p", "--", jump.Host)
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// parseDestination returns the host of a [user@]host[:port] destination.
+func parseDestination(destination string) config.HostConfiguration {
+	host := config.HostConfiguration{Host: destination}
+	if user, rest, ok := strings.Cut(host.Host, "@"); ok {
+		host.User, host.Host = user, rest
+	}
+	if i := strings.LastIndex(host.Host, ":"); i > 0 {
+		if port, err := strconv.Atoi(host.Host[i+1:]); err == nil {
+			host.Host, host.Port = host.Host[:i], port
+		}
+	}
+
+	return host
+}
+
+// remoteCommand returns the script executing the shell command on the host, in the working directory. sshd does not
+// signal the processes of a session without a terminal when the connection is closed, so the command runs next to a
+// watcher which terminates the process group of the session, SIGTERM then SIGKILL after the grace period, once the
+// sshd process of the session is gone, e.g. because the command was cancelled or timed out.
+func remoteCommand(command, workingDirectory string) string {
+	script := []string{}
+	if workingDirectory != "" && workingDirectory != remoteHomeDirectory {
+		dir := shellQuote(workingDirectory)
+		if rest, ok := strings.CutPrefix(workingDirectory, "~/"); ok {
+			dir = "~/" + shellQuote(rest)
+		}
+		script = append(script, fmt.Sprintf("cd %s || exit 1", dir))
+	}
+
+	return strings.Join(append(script,
+		fmt.Sprintf(`(trap '' TERM; while kill -0 "$PPID" 2>/dev/null; do sleep 1; done; kill -TERM 0; sleep %d; `+
+			`kill -KILL 0) </dev/null >/dev/null 2>&1 &`, int(terminationGracePeriod.Seconds())),
+		"watcher=$!",
+		fmt.Sprintf("(eval %s)", shellQuote(command)),
+		"status=$?",
+		`kill -KILL "$watcher" 2>/dev/null`,
+		`exit "$status"`,
+	), "\n")
+}
+
+// shellQuote quotes the value for a POSIX shell.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// hostNames returns the sorted names of the hosts.
+func hostNames(hosts map[string]config.HostConfiguration) []string {
+	names := maps.Keys(hosts)
+	slices.Sort(names)
+
+	return names
+}
+
+// getRemoteWorkingDirectory returns the working directory on a remote host, the home directory unless given.
+func getRemoteWorkingDirectory(inputs map[string]any) string {
+	workingDir, ok := inputs[inputWorkingDirectory].(string)
+	if !ok || workingDir == "" || workingDir == "." {
+		return remoteHomeDirectory
+	}
+
+	return workingDir
+}
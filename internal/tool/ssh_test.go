@@ -0,0 +1,215 @@
+package tool
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHosts are remote hosts for testing.
+var testHosts = map[string]config.HostConfiguration{
+	"bastion": {Host: "bastion.example.com", User: "ops", Port: 2222, Key: "/keys/bastion"},
+	"db-1":    {Host: "10.0.0.5", User: "postgres", Key: "/keys/db", Jump: []string{"bastion", "admin@gw.example.com"}},
+	"web-1":   {Host: "web-1.example.com"},
+}
+
+// newFakeSSH creates an ssh client which prints its arguments instead of connecting to the host.
+func newFakeSSH(t *testing.T) string {
+	t.Helper()
+
+	fakeSSH := filepath.Join(t.TempDir(), "ssh")
+	if err := os.WriteFile(fakeSSH, []byte("#!/bin/sh\nprintf '%s|' \"$@\"\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	return fakeSSH
+}
+
+// TestSSHBackend tests building the commands executed over SSH.
+func TestSSHBackend(t *testing.T) {
+	fakeSSH := newFakeSSH(t)
+
+	t.Run("builds the ssh arguments", func(t *testing.T) {
+		backend := NewSSHBackend("db-1", testHosts)
+		args := backend.args("df -h", "/var/lib/postgresql")
+		assert.Equal(t, []string{
+			"-T", "-o", "BatchMode=yes",
+			"-i", "/keys/db", "-o", "IdentitiesOnly=yes",
+			"-l", "postgres",
+			"-o", "ProxyCommand=" + backend.proxyCommand([]string{"bastion", "admin@gw.example.com"}),
+			"--", "10.0.0.5", remoteCommand("df -h", "/var/lib/postgresql"),
+		}, args)
+	})
+
+	t.Run("reaches the jump hosts with their own options", func(t *testing.T) {
+		// proxy runs the ProxyCommand with the fake ssh, expanding its tokens like the client.
+		proxy := func(command, host string) []string {
+			command = strings.NewReplacer("%%", "%", "%h", host, "%p", "22").Replace(command)
+			output, err := exec.Command("/bin/sh", "-c", command).Output()
+			require.NoError(t, err)
+			return strings.Split(strings.TrimSuffix(string(output), "|"), "|")
+		}
+		backend := NewSSHBackend("db-1", testHosts)
+		backend.executable = fakeSSH
+
+		gateway := proxy(backend.proxyCommand(testHosts["db-1"].Jump), "10.0.0.5")
+		require.Len(t, gateway, 10)
+		assert.Equal(t, []string{"-o", "BatchMode=yes", "-l", "admin", "-o"}, gateway[:5])
+		assert.Equal(t, []string{"-W", "10.0.0.5:22", "--", "gw.example.com"}, gateway[6:])
+
+		bastion := proxy(strings.TrimPrefix(gateway[5], "ProxyCommand="), "gw.example.com")
+		assert.Equal(t, []string{
+			"-o", "BatchMode=yes", "-i", "/keys/bastion", "-o", "IdentitiesOnly=yes", "-p", "2222", "-l", "ops",
+			"-W", "gw.example.com:22", "--", "bastion.example.com",
+		}, bastion)
+	})
+
+	t.Run("parses the destinations of jump hosts", func(t *testing.T) {
+		assert.Equal(t, config.HostConfiguration{Host: "gw.example.com"}, parseDestination("gw.example.com"))
+		assert.Equal(t, config.HostConfiguration{Host: "gw.example.com", User: "admin", Port: 2200},
+			parseDestination("admin@gw.example.com:2200"))
+		assert.Equal(t, config.HostConfiguration{Host: "10.0.0.1", User: "ops"}, parseDestination("ops@10.0.0.1"))
+	})
+
+	t.Run("executes commands in the home directory by default", func(t *testing.T) {
+		args := NewSSHBackend("web-1", testHosts).args("uptime", remoteHomeDirectory)
+		assert.Equal(t, []string{"-T", "-o", "BatchMode=yes", "--", "web-1.example.com", remoteCommand("uptime", "")}, args)
+		assert.NotContains(t, args[5], "cd ")
+	})
+
+	t.Run("quotes the working directory", func(t *testing.T) {
+		assert.True(t, strings.HasPrefix(remoteCommand("ls", "~/my app"), "cd ~/'my app' || exit 1\n"))
+		assert.True(t, strings.HasPrefix(remoteCommand("ls", "/srv/it's"), "cd '/srv/it'\\''s' || exit 1\n"))
+	})
+
+	t.Run("executes the command in the remote script", func(t *testing.T) {
+		output, err := exec.Command("/bin/sh", "-c", remoteCommand(`cd /tmp && echo "$(pwd)"; exit 3`, "/")).Output()
+		assert.Equal(t, "/tmp\n", string(output))
+		var exitErr *exec.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, 3, exitErr.ExitCode())
+	})
+
+	t.Run("terminates the remote command once the session is gone", func(t *testing.T) {
+		// The background shell stands in for the sshd process of the session, reaped by its parent once it is killed.
+		cmd := exec.Command("/bin/sh", "-c", `/bin/sh -c '/bin/sh -c "$1"; sleep 30' sh "$1" & echo $!; wait`, "sh",
+			remoteCommand("echo started; sleep 30", ""))
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		stdout, err := cmd.StdoutPipe()
+		require.NoError(t, err)
+		require.NoError(t, cmd.Start())
+		t.Cleanup(func() { _ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) })
+
+		reader := bufio.NewReader(stdout)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		sshd, err := strconv.Atoi(strings.TrimSpace(line))
+		require.NoError(t, err)
+		line, err = reader.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "started\n", line)
+		startedAt := time.Now()
+		require.NoError(t, syscall.Kill(sshd, syscall.SIGKILL))
+
+		_, err = io.ReadAll(reader)
+		assert.NoError(t, err, "the output is closed once the command is terminated")
+		assert.Less(t, time.Since(startedAt), 5*time.Second)
+		_ = cmd.Wait()
+	})
+
+	t.Run("returns error without ssh", func(t *testing.T) {
+		backend := NewSSHBackend("web-1", testHosts)
+		backend.executable = "opsy-missing-ssh"
+		_, err := backend.Command(context.Background(), "uptime", remoteHomeDirectory, nil)
+		assert.ErrorContains(t, err, ErrSSHUnavailable)
+	})
+
+	t.Run("returns error with environment variables", func(t *testing.T) {
+		backend := NewSSHBackend("web-1", testHosts)
+		_, err := backend.Command(context.Background(), "uptime", remoteHomeDirectory, []string{"JIRA_API_TOKEN=secret"})
+		assert.EqualError(t, err, ErrSSHEnvUnsupported)
+	})
+
+	t.Run("returns the remote working directory", func(t *testing.T) {
+		assert.Equal(t, "~", getRemoteWorkingDirectory(map[string]any{}))
+		assert.Equal(t, "~", getRemoteWorkingDirectory(map[string]any{inputWorkingDirectory: "."}))
+		assert.Equal(t, "/var/log", getRemoteWorkingDirectory(map[string]any{inputWorkingDirectory: "/var/log"}))
+	})
+}
+
+// TestExecTool_Hosts tests executing commands on remote hosts.
+func TestExecTool_Hosts(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Exec.Hosts = testHosts
+
+	fakeSSH := newFakeSSH(t)
+
+	t.Run("adds the host input when hosts are configured", func(t *testing.T) {
+		tool := NewExecTool(newTestLogger(), cfg)
+		property, ok := tool.GetInputSchema().Properties.Get(inputHost)
+		assert.True(t, ok)
+		assert.Contains(t, property.Description, "bastion, db-1, web-1")
+		assert.NotContains(t, tool.GetInputSchema().Required, inputHost)
+
+		_, ok = NewExecTool(newTestLogger(), newTestConfig()).GetInputSchema().Properties.Get(inputHost)
+		assert.False(t, ok)
+	})
+
+	t.Run("executes the command on the host", func(t *testing.T) {
+		tool := NewExecTool(newTestLogger(), cfg)
+		tool.remotes["web-1"].executable = fakeSSH
+
+		output, err := tool.Execute(map[string]any{inputCommand: "uptime", inputHost: "web-1"}, context.Background())
+		assert.NoError(t, err)
+		assert.False(t, output.IsError)
+		assert.Equal(t, "-T|-o|BatchMode=yes|--|web-1.example.com|"+remoteCommand("uptime", "")+"|", output.Result)
+		assert.Equal(t, "web-1", output.ExecutedCommand.Host)
+		assert.Equal(t, remoteHomeDirectory, output.ExecutedCommand.WorkingDirectory)
+		assert.Equal(t, "web-1:~", output.ExecutedCommand.Location())
+	})
+
+	t.Run("does not execute commands on unknown hosts", func(t *testing.T) {
+		output, err := NewExecTool(newTestLogger(), cfg).Execute(map[string]any{inputCommand: "uptime", inputHost: "db-2"},
+			context.Background())
+		assert.NoError(t, err)
+		assert.True(t, output.IsError)
+		assert.Contains(t, output.Result, "`db-2` is not configured")
+		assert.Nil(t, output.ExecutedCommand)
+	})
+
+	t.Run("does not execute commands of tools with env on hosts", func(t *testing.T) {
+		tool := NewExecTool(newTestLogger(), cfg, WithEnv([]string{"KUBECONFIG=/srv/kubeconfig", "API_TOKEN=secret"},
+			map[string]string{"API_TOKEN": "secret"}))
+		tool.remotes["web-1"].executable = fakeSSH
+
+		output, err := tool.Execute(map[string]any{inputCommand: "uptime", inputHost: "web-1"}, context.Background())
+		assert.NoError(t, err)
+		assert.True(t, output.IsError)
+		assert.Nil(t, output.ExecutedCommand)
+		assert.Equal(t, "The command `uptime` was not executed on `web-1`: the environment variables KUBECONFIG, "+
+			"API_TOKEN of the tool cannot be passed to remote hosts. Run the command locally instead, or ask the user "+
+			"to run it on the host.", output.Result)
+	})
+
+	t.Run("records the host in dry-run mode", func(t *testing.T) {
+		cfg := *cfg
+		cfg.Exec.DryRun = config.DryRunConfiguration{Enabled: true}
+		output, err := NewExecTool(newTestLogger(), &cfg).Execute(
+			map[string]any{inputCommand: "systemctl restart nginx", inputHost: "web-1", inputWorkingDirectory: "/etc"},
+			context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "web-1:/etc", output.ExecutedCommand.Location())
+	})
+}
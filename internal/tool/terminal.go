@@ -0,0 +1,178 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// ErrTerminalUnsupported is the error returned when interactive commands are not supported on the platform.
+	ErrTerminalUnsupported = "interactive commands are not supported on this platform"
+
+	// inputInteractive is the input parameter marking the command as interactive.
+	inputInteractive = "interactive"
+
+	// resultCommandInteractive is the note added to the transcript returned to the agent for interactive commands.
+	resultCommandInteractive = "The command `%s` was run interactively by the user in a terminal, it exited with " +
+		"code %d. The transcript of the terminal follows."
+	// resultInteractiveUnavailable is the result returned to the agent when an interactive command cannot be run.
+	resultInteractiveUnavailable = "The command `%s` needs an interactive terminal, which is not available: %s. " +
+		"Ask the user to run it themselves, or use a non-interactive alternative."
+	// resultInteractiveRemote is the reason returned to the agent for interactive commands of remote or container
+	// backends.
+	resultInteractiveRemote = "interactive commands can only be executed locally on the host"
+)
+
+// TerminalRequest is a request to hand the terminal over to the user to run an interactive command.
+type TerminalRequest struct {
+	// Command is the interactive command.
+	Command Command
+	// Session is the session running the command in a pseudo-terminal. It must be run with the stdin and stdout of
+	// the terminal of the user.
+	Session *TerminalSession
+	// Response is the channel the result of handing the terminal over must be sent to once the session has ended.
+	Response chan error
+}
+
+// Terminal is the interface for running interactive commands in the terminal of the user.
+type Terminal interface {
+	// Attach blocks until the user has finished the session of the interactive command.
+	Attach(ctx context.Context, cmd Command, session *TerminalSession) error
+}
+
+// terminalKey is the context key for the terminal.
+type terminalKey struct{}
+
+// WithTerminal returns a copy of the context that carries the given terminal.
+func WithTerminal(ctx context.Context, terminal Terminal) context.Context {
+	return context.WithValue(ctx, terminalKey{}, terminal)
+}
+
+// TerminalFromContext returns the terminal carried by the context, if any.
+func TerminalFromContext(ctx context.Context) (Terminal, bool) {
+	terminal, ok := ctx.Value(terminalKey{}).(Terminal)
+	return terminal, ok
+}
+
+// NewTerminalRequest creates a new request to run the session of the interactive command in the terminal.
+func NewTerminalRequest(cmd Command, session *TerminalSession) TerminalRequest {
+	return TerminalRequest{
+		Command:  cmd,
+		Session:  session,
+		Response: make(chan error, 1),
+	}
+}
+
+// WithInteractive sets the regular expressions matching the commands of the tool which need an interactive terminal.
+func WithInteractive(patterns []string) ExecOption {
+	return func(t *execTool) {
+		t.interactivePatterns = patterns
+	}
+}
+
+// compileInteractive compiles the patterns matching the interactive commands. Invalid patterns are logged and skipped.
+func (t *execTool) compileInteractive() {
+	for _, pattern := range t.interactivePatterns {
+		expression, err := regexp.Compile(pattern)
+		if err != nil {
+			t.logger.With("pattern", pattern).With("error", err).Error("Invalid interactive command pattern, skipping.")
+			continue
+		}
+		t.interactive = append(t.interactive, expression)
+	}
+}
+
+// isInteractive returns true if the command was marked as interactive by the agent or matches an interactive pattern.
+func (t *execTool) isInteractive(command string, inputs map[string]any) bool {
+	if interactive, _ := inputs[inputInteractive].(bool); interactive {
+		return true
+	}
+
+	for _, expression := range t.interactive {
+		if expression.MatchString(command) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// executeInteractive runs the command in a pseudo-terminal handed over to the user by the terminal carried by the
+// context. The command is not subject to the timeout, since the user controls it, and the transcript of the terminal
+// is returned to the agent once the user has finished.
+func (t *execTool) executeInteractive(ctx context.Context, backend Backend, running Command) *Output {
+	host, local := backend.(*hostBackend)
+	if !local {
+		return t.interactiveUnavailable(running.Command, resultInteractiveRemote)
+	}
+
+	terminal, ok := TerminalFromContext(ctx)
+	if !ok {
+		return t.interactiveUnavailable(running.Command, "no terminal is attached")
+	}
+
+	ctx, cancelCommand := context.WithCancelCause(ctx)
+	defer cancelCommand(nil)
+
+	cmd, err := host.terminalCommand(ctx, running.Command, running.WorkingDirectory, t.env)
+	if err != nil {
+		t.logger.With("command", running.Command).With("error", err).Error("Failed to create command.")
+		return &Output{
+			Tool:    t.GetName(),
+			Result:  fmt.Sprintf(resultBackendUnavailable, running.Command, err),
+			IsError: true,
+		}
+	}
+	group := terminateProcessGroup(cmd, t.gracePeriod)
+	defer group.stop()
+
+	logger := t.logger.With("command", cmd.String()).With("working_directory", running.WorkingDirectory)
+	logger.Debug("Executing interactive command.")
+
+	session := newTerminalSession(cmd)
+	err = terminal.Attach(ctx, running, session)
+	if !session.Started() {
+		if err == nil {
+			err = context.Cause(ctx)
+		}
+		logger.With("error", err).Error("Failed to run interactive command.")
+		return t.interactiveUnavailable(running.Command, err.Error())
+	}
+	if err != nil {
+		logger.With("error", err).Warn("Failed to restore the terminal after the interactive command.")
+	}
+
+	transcript := t.redactor.Redact(session.Transcript())
+	running.ExitCode = session.ExitCode()
+	running.Output, running.Stdout = transcript, transcript
+	running.Interactive = true
+	running.CompletedAt = time.Now()
+	output := &Output{Tool: t.GetName(), Result: transcript, IsError: running.ExitCode != 0, ExecutedCommand: &running}
+
+	t.limitOutput(output)
+	output.Result = strings.TrimSpace(fmt.Sprintf(resultCommandInteractive, running.Command, running.ExitCode) +
+		"\n\n" + output.Result)
+
+	if CancelledByUser(ctx) {
+		logger.Info("Interactive command cancelled by the user.")
+		running.Cancelled = true
+		output.IsError = true
+		output.Result = strings.TrimSpace(fmt.Sprintf(resultCommandCancelled, running.Command) + "\n\n" + output.Result)
+	}
+
+	return output
+}
+
+// interactiveUnavailable returns the output of an interactive command that could not be run for the given reason.
+func (t *execTool) interactiveUnavailable(command, reason string) *Output {
+	t.logger.With("command", command).With("reason", reason).Warn("Interactive command not executed.")
+
+	return &Output{
+		Tool:    t.GetName(),
+		Result:  fmt.Sprintf(resultInteractiveUnavailable, command, reason),
+		IsError: true,
+	}
+}
@@ -0,0 +1,132 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockTerminal runs the sessions of interactive commands with the given input instead of the terminal of the user.
+type mockTerminal struct {
+	input    string
+	screen   bytes.Buffer
+	commands []Command
+	err      error
+}
+
+func (m *mockTerminal) Attach(ctx context.Context, cmd Command, session *TerminalSession) error {
+	m.commands = append(m.commands, cmd)
+	if m.err != nil {
+		return m.err
+	}
+
+	session.SetStdin(strings.NewReader(m.input))
+	session.SetStdout(&m.screen)
+
+	return session.Run()
+}
+
+// TestExecTool_Interactive tests the execution of interactive commands
+func TestExecTool_Interactive(t *testing.T) {
+	logger := newTestLogger()
+
+	t.Run("hands the terminal over for commands marked as interactive", func(t *testing.T) {
+		terminal := &mockTerminal{input: "yes\n"}
+		inputs := map[string]any{
+			inputCommand:          `read answer; echo "answer: $answer"`,
+			inputWorkingDirectory: ".",
+			inputInteractive:      true,
+		}
+		output, err := NewExecTool(logger, newTestConfig()).Execute(inputs, WithTerminal(context.Background(), terminal))
+		require.NoError(t, err)
+
+		require.Len(t, terminal.commands, 1)
+		assert.Equal(t, inputs[inputCommand], terminal.commands[0].Command)
+		assert.False(t, output.IsError)
+		require.NotNil(t, output.ExecutedCommand)
+		assert.True(t, output.ExecutedCommand.Interactive)
+		assert.Equal(t, "yes\nanswer: yes", output.ExecutedCommand.Output)
+		assert.Equal(t, "The command `read answer; echo \"answer: $answer\"` was run interactively by the user in "+
+			"a terminal, it exited with code 0. The transcript of the terminal follows.\n\nyes\nanswer: yes", output.Result)
+	})
+
+	t.Run("hands the terminal over for commands matching an interactive pattern", func(t *testing.T) {
+		terminal := &mockTerminal{}
+		inputs := map[string]any{inputCommand: "echo login; exit 1", inputWorkingDirectory: "."}
+		execTool := NewExecTool(logger, newTestConfig(), WithInteractive([]string{"^echo login", "("}))
+		output, err := execTool.Execute(inputs, WithTerminal(context.Background(), terminal))
+		require.NoError(t, err)
+
+		assert.Len(t, terminal.commands, 1)
+		assert.True(t, output.IsError)
+		assert.Equal(t, 1, output.ExecutedCommand.ExitCode)
+		assert.Contains(t, output.Result, "it exited with code 1")
+	})
+
+	t.Run("does not run interactive commands without a terminal", func(t *testing.T) {
+		inputs := map[string]any{inputCommand: "aws sso login", inputWorkingDirectory: ".", inputInteractive: true}
+		output, err := NewExecTool(logger, newTestConfig()).Execute(inputs, context.Background())
+		require.NoError(t, err)
+
+		assert.True(t, output.IsError)
+		assert.Nil(t, output.ExecutedCommand)
+		assert.Equal(t, "The command `aws sso login` needs an interactive terminal, which is not available: no "+
+			"terminal is attached. Ask the user to run it themselves, or use a non-interactive alternative.", output.Result)
+	})
+
+	t.Run("reports the terminal which cannot be handed over", func(t *testing.T) {
+		terminal := &mockTerminal{err: errors.New("rejected: headless mode")}
+		inputs := map[string]any{inputCommand: "gh auth login", inputWorkingDirectory: ".", inputInteractive: true}
+		output, err := NewExecTool(logger, newTestConfig()).Execute(inputs, WithTerminal(context.Background(), terminal))
+		require.NoError(t, err)
+
+		assert.True(t, output.IsError)
+		assert.Contains(t, output.Result, "which is not available: rejected: headless mode")
+	})
+
+	t.Run("runs interactive commands in the sandbox with the terminal", func(t *testing.T) {
+		// The fake bubblewrap records its arguments and executes the command without a sandbox.
+		dir := t.TempDir()
+		argsPath := filepath.Join(dir, "args")
+		script := "#!/bin/sh\necho \"$@\" > " + argsPath + "\nwhile [ \"$1\" != -- ]; do shift; done\nshift\nexec \"$@\"\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, sandboxExecutable), []byte(script), 0700))
+		t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		terminal := &mockTerminal{input: "yes\n"}
+		inputs := map[string]any{
+			inputCommand:          `test -t 0 && read answer && echo "answer: $answer"`,
+			inputWorkingDirectory: ".",
+			inputInteractive:      true,
+		}
+		execTool := NewExecTool(logger, newTestConfig(), WithSandbox(config.SandboxConfiguration{Enabled: true}))
+		output, err := execTool.Execute(inputs, WithTerminal(context.Background(), terminal))
+		require.NoError(t, err)
+
+		assert.False(t, output.IsError)
+		assert.Contains(t, output.ExecutedCommand.Output, "answer: yes")
+		args, err := os.ReadFile(argsPath)
+		require.NoError(t, err)
+		assert.Contains(t, string(args), "--die-with-parent")
+		assert.NotContains(t, string(args), "--new-session")
+	})
+
+	t.Run("does not run interactive commands on remote hosts", func(t *testing.T) {
+		cfg := newTestConfig()
+		cfg.Exec.Hosts = testHosts
+		terminal := &mockTerminal{}
+		inputs := map[string]any{inputCommand: "top", inputWorkingDirectory: "/srv", inputHost: "web-1", inputInteractive: true}
+		output, err := NewExecTool(logger, cfg).Execute(inputs, WithTerminal(context.Background(), terminal))
+		require.NoError(t, err)
+
+		assert.Empty(t, terminal.commands)
+		assert.Contains(t, output.Result, resultInteractiveRemote)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os/exec"
+	"regexp"
 	"time"
 
 	"github.com/datolabs-io/opsy/assets"
@@ -57,6 +58,14 @@ type Definition struct {
 	Inputs map[string]Input `yaml:"inputs"`
 	// Executable is the executable to use to execute the tool.
 	Executable string `yaml:"executable,omitempty"`
+	// Policy is the policy rules applied to the commands executed by the tool.
+	Policy []config.PolicyRule `yaml:"policy,omitempty"`
+	// Sandbox is the sandbox the commands executed by the tool run in, replacing tools.exec.sandbox if set.
+	Sandbox *config.SandboxConfiguration `yaml:"sandbox,omitempty"`
+	// Interactive is the regular expressions matching the commands of the tool which need an interactive terminal.
+	Interactive []string `yaml:"interactive,omitempty"`
+	// Env is the environment variables of the commands executed by the tool, overridden by tools.<name>.env.
+	Env []config.EnvVar `yaml:"env,omitempty"`
 }
 
 // Input is the definition of an input for a tool.
@@ -102,6 +111,12 @@ const (
 	ErrToolMarshalingInputs = "tool inputs cannot be marshaled"
 	// ErrToolInvalidSystemPrompt is the error returned when a tool has an invalid system prompt.
 	ErrToolInvalidSystemPrompt = "invalid system prompt"
+	// ErrToolInvalidPolicy is the error returned when a tool has invalid policy rules.
+	ErrToolInvalidPolicy = "invalid tool policy"
+	// ErrToolInvalidInteractive is the error returned when a tool has an invalid interactive command pattern.
+	ErrToolInvalidInteractive = "invalid tool interactive command pattern"
+	// ErrToolInvalidEnv is the error returned when a tool has invalid environment variables.
+	ErrToolInvalidEnv = "invalid tool env"
 
 	// inputTask is the input parameter for the task to complete.
 	inputTask = "task"
@@ -193,11 +208,17 @@ func (t *tool) Execute(inputs map[string]any, ctx context.Context) (*Output, err
 		return nil, fmt.Errorf("%s: %w", assets.ErrToolRenderingPrompt, err)
 	}
 
+	execOptions, err := t.execOptions(ctx)
+	if err != nil {
+		logger.With("error", err).Error("Failed to resolve tool env.")
+		return nil, err
+	}
+
 	options := &RunOptions{
 		Task:   userPrompt,
 		Prompt: systemPrompt,
 		Caller: t.GetDisplayName(),
-		Tools:  map[string]Tool{ExecToolName: NewExecTool(t.logger, t.config)},
+		Tools:  map[string]Tool{ExecToolName: NewExecTool(t.logger, t.config, execOptions...)},
 	}
 	output := &Output{
 		Tool:            t.GetDisplayName(),
@@ -219,6 +240,29 @@ func (t *tool) Execute(inputs map[string]any, ctx context.Context) (*Output, err
 	return output, err
 }
 
+// execOptions returns the options of the exec tool executing the commands of the tool. The environment variables of
+// the tool are resolved on every execution, so that secrets read by commands are never stored.
+func (t *tool) execOptions(ctx context.Context) ([]ExecOption, error) {
+	opts := []ExecOption{WithPolicyRules(t.definition.Policy), WithInteractive(t.definition.Interactive)}
+	if t.definition.Sandbox != nil {
+		opts = append(opts, WithSandbox(*t.definition.Sandbox))
+	}
+
+	vars := t.definition.Env
+	if t.config != nil {
+		vars = mergeEnv(vars, t.config.Overrides[t.name].Env)
+	}
+	if len(vars) > 0 {
+		env, secrets, err := resolveEnv(ctx, t.config.Exec.Shell, vars)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ErrToolEnv, err)
+		}
+		opts = append(opts, WithEnv(env, secrets))
+	}
+
+	return opts, nil
+}
+
 // getTimeout returns the timeout for the tool.
 func (t *tool) getTimeout() time.Duration {
 	return time.Duration(t.config.Timeout) * time.Second
@@ -314,6 +358,20 @@ func ValidateDefinition(def *Definition) error {
 		}
 	}
 
+	if err := config.ValidatePolicyRules(def.Policy); err != nil {
+		return fmt.Errorf("%s: %v", ErrToolInvalidPolicy, err)
+	}
+
+	for _, pattern := range def.Interactive {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s: %q", ErrToolInvalidInteractive, pattern)
+		}
+	}
+
+	if err := config.ValidateEnv(def.Env); err != nil {
+		return fmt.Errorf("%s: %v", ErrToolInvalidEnv, err)
+	}
+
 	// Validate that the system prompt can be rendered
 	_, err := assets.RenderToolSystemPrompt(&assets.ToolSystemPromptData{
 		Name:       def.DisplayName,
@@ -17,9 +17,11 @@ import (
 type mockRunner struct {
 	outputs []Output
 	err     error
+	options *RunOptions
 }
 
 func (r *mockRunner) Run(opts *RunOptions, ctx context.Context) ([]Output, error) {
+	r.options = opts
 	if r.err != nil {
 		return nil, r.err
 	}
@@ -238,6 +240,80 @@ func TestToolExecute(t *testing.T) {
 		assert.Nil(t, output.ExecutedCommand)
 	})
 
+	t.Run("executes commands in the sandbox of the tool", func(t *testing.T) {
+		sandbox := config.SandboxConfiguration{Enabled: true, Writable: []string{"/srv/repo"}}
+		runner := newMockRunner(nil, nil)
+		tool := New("test", Definition{
+			DisplayName: "Test Tool",
+			Description: "Test Description",
+			Sandbox:     &sandbox,
+		}, logger, cfg, runner)
+
+		_, err := tool.Execute(map[string]any{inputTask: "test task"}, context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, runner.options)
+		execTool, ok := runner.options.Tools[ExecToolName].(*execTool)
+		require.True(t, ok)
+		backend, ok := execTool.backend.(*hostBackend)
+		require.True(t, ok)
+		require.NotNil(t, backend.sandbox)
+		assert.Equal(t, sandbox, backend.sandbox.config)
+	})
+
+	t.Run("marks the interactive commands of the tool", func(t *testing.T) {
+		runner := newMockRunner(nil, nil)
+		tool := New("test", Definition{
+			DisplayName: "Test Tool",
+			Description: "Test Description",
+			Interactive: []string{`^kubectl exec .*-it\b`},
+		}, logger, cfg, runner)
+
+		_, err := tool.Execute(map[string]any{inputTask: "test task"}, context.Background())
+		require.NoError(t, err)
+		execTool, ok := runner.options.Tools[ExecToolName].(*execTool)
+		require.True(t, ok)
+		assert.True(t, execTool.isInteractive("kubectl exec -it api -- sh", map[string]any{}))
+		assert.False(t, execTool.isInteractive("kubectl exec api -- ls", map[string]any{}))
+	})
+
+	t.Run("executes commands with the env of the tool", func(t *testing.T) {
+		t.Setenv("OPSY_TEST_PROXY", "http://proxy:3128")
+		cfg := *cfg
+		cfg.Overrides = map[string]config.ToolConfiguration{
+			"test": {Env: []config.EnvVar{{Name: "API_TOKEN", Command: "echo s3cr3t-token"}}},
+		}
+		runner := newMockRunner(nil, nil)
+		tool := New("test", Definition{
+			DisplayName: "Test Tool",
+			Description: "Test Description",
+			Env: []config.EnvVar{
+				{Name: "API_TOKEN", Value: "overridden"},
+				{Name: "HTTPS_PROXY", FromEnv: "OPSY_TEST_PROXY"},
+			},
+		}, logger, &cfg, runner)
+
+		_, err := tool.Execute(map[string]any{inputTask: "test task"}, context.Background())
+		require.NoError(t, err)
+		execTool, ok := runner.options.Tools[ExecToolName].(*execTool)
+		require.True(t, ok)
+		assert.Equal(t, []string{"HTTPS_PROXY=http://proxy:3128", "API_TOKEN=s3cr3t-token"}, execTool.env)
+		assert.Equal(t, map[string]string{"API_TOKEN": "s3cr3t-token"}, execTool.envSecrets)
+	})
+
+	t.Run("returns error when the env cannot be resolved", func(t *testing.T) {
+		runner := newMockRunner(nil, nil)
+		tool := New("test", Definition{
+			DisplayName: "Test Tool",
+			Description: "Test Description",
+			Env:         []config.EnvVar{{Name: "API_TOKEN", Command: "exit 1"}},
+		}, logger, cfg, runner)
+
+		output, err := tool.Execute(map[string]any{inputTask: "test task"}, context.Background())
+		assert.ErrorContains(t, err, ErrToolEnv)
+		assert.Nil(t, output)
+		assert.Nil(t, runner.options)
+	})
+
 	t.Run("validates task input", func(t *testing.T) {
 		runner := newMockRunner(nil, nil)
 		tool := New("test", Definition{
@@ -375,6 +451,52 @@ func TestValidateToolDefinition(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("validates policy rules", func(t *testing.T) {
+		def := &Definition{
+			DisplayName: "Tool",
+			Description: "Description",
+			Policy:      []config.PolicyRule{{Match: "^kubectl delete", Action: "deny"}},
+		}
+		err := ValidateDefinition(def)
+		assert.NoError(t, err)
+
+		def.Policy = []config.PolicyRule{{Match: "(", Action: "deny"}}
+		err = ValidateDefinition(def)
+		assert.ErrorContains(t, err, ErrToolInvalidPolicy)
+
+		def.Policy = []config.PolicyRule{{Match: "^kubectl delete", Action: "block"}}
+		err = ValidateDefinition(def)
+		assert.ErrorContains(t, err, ErrToolInvalidPolicy)
+	})
+
+	t.Run("validates interactive command patterns", func(t *testing.T) {
+		def := &Definition{
+			DisplayName: "Tool",
+			Description: "Description",
+			Interactive: []string{"^aws sso login"},
+		}
+		err := ValidateDefinition(def)
+		assert.NoError(t, err)
+
+		def.Interactive = []string{"("}
+		err = ValidateDefinition(def)
+		assert.ErrorContains(t, err, ErrToolInvalidInteractive)
+	})
+
+	t.Run("validates env", func(t *testing.T) {
+		def := &Definition{
+			DisplayName: "Tool",
+			Description: "Description",
+			Env:         []config.EnvVar{{Name: "JIRA_API_TOKEN", Command: "pass show jira/token"}},
+		}
+		err := ValidateDefinition(def)
+		assert.NoError(t, err)
+
+		def.Env = []config.EnvVar{{Name: "JIRA_API_TOKEN", Value: "token", FromEnv: "JIRA_TOKEN"}}
+		err = ValidateDefinition(def)
+		assert.ErrorContains(t, err, ErrToolInvalidEnv)
+	})
+
 	t.Run("allows empty inputs", func(t *testing.T) {
 		def := &Definition{
 			DisplayName: "Tool",
@@ -3,7 +3,10 @@ package commandspane
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -26,19 +29,71 @@ type Model struct {
 	viewport viewport.Model
 	// commands stores the history of executed commands
 	commands []tool.Command
+	// running stores the commands which are still running, with their latest output
+	running []*runningCommand
+	// spinner is shown next to the running commands
+	spinner spinner.Model
+	// approval is the pending approval request, if any
+	approval *tool.ApprovalRequest
+	// queued are the approval requests received while another command is awaiting approval, in order
+	queued []tool.ApprovalRequest
+	// editor is the input used to edit the command awaiting approval
+	editor textinput.Model
+	// editing indicates whether the command awaiting approval is being edited
+	editing bool
+}
+
+// runningCommand is a command which is still running.
+type runningCommand struct {
+	// command is the running command
+	command tool.Command
+	// lines are the latest lines of output of the command, with the streams they were written to
+	lines []tool.CommandOutput
+	// cancel cancels the command, nil if it cannot be cancelled
+	cancel func()
+	// cancelled indicates whether the user has cancelled the command
+	cancelled bool
+}
+
+// CancelCommand is the message cancelling the most recently started command which is still running.
+type CancelCommand struct{}
+
+// approvalDone is the message sent once the approval request with the response channel is no longer awaited.
+type approvalDone struct {
+	response chan tool.Approval
 }
 
 // Option is a function that modifies the Model.
 type Option func(*Model)
 
-// title is the title of the commands pane.
-const title = "Commands"
+const (
+	// title is the title of the commands pane.
+	title = "Commands"
+	// approvalHint is the hint shown below a command awaiting approval.
+	approvalHint = "Awaiting approval: [a] Approve  [r] Reject  [e] Edit"
+	// dryRunPrefix is the prefix of commands recorded in dry-run mode.
+	dryRunPrefix = "[dry-run] "
+	// queuedHint is the hint shown when more commands are awaiting approval.
+	queuedHint = "%s [%d more waiting]"
+	// editHint is the hint shown below a command being edited.
+	editHint = "Editing: [enter] Run edited command  [esc] Cancel"
+	// runningStatus is the status shown below a running command.
+	runningStatus = "%s Running for %s"
+	// cancelHint is the hint shown next to the status of the command cancelled by ctrl+x.
+	cancelHint = "  [ctrl+x] Cancel"
+	// cancellingStatus is the status shown below a command cancelled by the user until it has exited.
+	cancellingStatus = "%s Cancelling after %s…"
+	// maxOutputLines is the number of the latest output lines shown below a running command.
+	maxOutputLines = 10
+)
 
 // New creates a new commands pane component.
 func New(opts ...Option) *Model {
 	m := &Model{
 		viewport: viewport.New(0, 0),
 		commands: []tool.Command{},
+		editor:   textinput.New(),
+		spinner:  spinner.New(spinner.WithSpinner(spinner.Dot)),
 	}
 
 	for _, opt := range opts {
@@ -65,15 +120,56 @@ func (m *Model) Update(msg tea.Msg) (*Model, tea.Cmd) {
 		m.viewport.Style = lipgloss.NewStyle().Background(m.theme.BaseColors.Base01)
 
 		// Rerender all commands with new dimensions
-		if len(m.commands) > 0 {
+		if len(m.commands) > 0 || len(m.running) > 0 || m.approval != nil {
 			m.renderCommands()
 		} else {
 			m.viewport.SetContent(m.titleStyle().Render(title))
 		}
 	case tool.Command:
+		m.removeRunning(msg.ID)
 		m.commands = append(m.commands, msg)
 		m.renderCommands()
 		m.viewport.GotoBottom()
+	case tool.CommandOutput:
+		cmd = m.updateRunning(msg)
+		m.renderCommands()
+		m.viewport.GotoBottom()
+		return m, cmd
+	case spinner.TickMsg:
+		if len(m.running) == 0 {
+			return m, nil
+		}
+		m.spinner, cmd = m.spinner.Update(msg)
+		m.renderCommands()
+		return m, cmd
+	case CancelCommand:
+		if m.cancelRunning() {
+			m.renderCommands()
+		}
+		return m, nil
+	case tool.ApprovalRequest:
+		if m.approval != nil {
+			m.queued = append(m.queued, msg)
+			m.renderCommands()
+			return m, awaitApproval(msg)
+		}
+		m.approval = &msg
+		m.editing = false
+		m.renderCommands()
+		m.viewport.GotoBottom()
+		return m, awaitApproval(msg)
+	case approvalDone:
+		if m.dismiss(msg.response) {
+			m.renderCommands()
+		}
+		return m, nil
+	case tea.KeyMsg:
+		if m.approval != nil {
+			cmd = m.handleApprovalKey(msg)
+			m.renderCommands()
+			m.viewport.GotoBottom()
+			return m, cmd
+		}
 	}
 
 	m.viewport, cmd = m.viewport.Update(msg)
@@ -85,6 +181,83 @@ func (m *Model) View() string {
 	return m.containerStyle().Render(m.viewport.View())
 }
 
+// updateRunning adds the output to the running command, tracking the command if it has just started. The spinner
+// starts ticking when the first command starts running.
+func (m *Model) updateRunning(output tool.CommandOutput) tea.Cmd {
+	var cmd tea.Cmd
+	index := m.runningIndex(output.Command.ID)
+	if index < 0 {
+		if len(m.running) == 0 {
+			cmd = m.spinner.Tick
+		}
+		m.running = append(m.running, &runningCommand{command: output.Command})
+		index = len(m.running) - 1
+	}
+
+	if output.Started {
+		m.running[index].cancel = output.Cancel
+	} else {
+		running := m.running[index]
+		running.lines = append(running.lines, output)
+		if len(running.lines) > maxOutputLines {
+			running.lines = running.lines[len(running.lines)-maxOutputLines:]
+		}
+	}
+
+	return cmd
+}
+
+// cancelRunning cancels the most recently started command which is still running and can be cancelled. It returns
+// false if there is no such command.
+func (m *Model) cancelRunning() bool {
+	running := m.cancellable()
+	if running == nil {
+		return false
+	}
+
+	running.cancel()
+	running.cancelled = true
+	return true
+}
+
+// cancellable returns the most recently started command which is still running and can be cancelled, if any.
+func (m *Model) cancellable() *runningCommand {
+	for i := len(m.running) - 1; i >= 0; i-- {
+		if running := m.running[i]; running.cancel != nil && !running.cancelled {
+			return running
+		}
+	}
+
+	return nil
+}
+
+// removeRunning stops tracking the running command with the given ID.
+func (m *Model) removeRunning(id string) {
+	if index := m.runningIndex(id); index >= 0 {
+		m.running = append(m.running[:index], m.running[index+1:]...)
+	}
+}
+
+// runningIndex returns the index of the running command with the given ID, or -1 if it is not running.
+func (m *Model) runningIndex(id string) int {
+	if id == "" {
+		return -1
+	}
+
+	for i, running := range m.running {
+		if running.command.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// AwaitingApproval returns true if a command is waiting for the user's decision.
+func (m *Model) AwaitingApproval() bool {
+	return m.approval != nil
+}
+
 // WithTheme sets the theme for the commands pane component.
 func WithTheme(theme thememanager.Theme) Option {
 	return func(m *Model) {
@@ -127,6 +300,37 @@ func (m *Model) workdirStyle() lipgloss.Style {
 		Padding(0, 1)
 }
 
+// approvalStyle creates a style for the approval hints.
+func (m *Model) approvalStyle() lipgloss.Style {
+	return lipgloss.NewStyle().
+		Foreground(m.theme.AccentColors.Accent1).
+		Background(m.theme.BaseColors.Base01).
+		Bold(true).
+		Width(m.maxWidth)
+}
+
+// runningStyle creates a style for the status of running commands.
+func (m *Model) runningStyle() lipgloss.Style {
+	return lipgloss.NewStyle().
+		Foreground(m.theme.AccentColors.Accent2).
+		Background(m.theme.BaseColors.Base01).
+		Width(m.maxWidth)
+}
+
+// outputStyle creates a style for the output of running commands.
+func (m *Model) outputStyle() lipgloss.Style {
+	return lipgloss.NewStyle().
+		Foreground(m.theme.BaseColors.Base04).
+		Background(m.theme.BaseColors.Base01).
+		PaddingLeft(2).
+		Width(m.maxWidth)
+}
+
+// stderrStyle creates a style for the standard error output of running commands.
+func (m *Model) stderrStyle() lipgloss.Style {
+	return m.outputStyle().Foreground(m.theme.AccentColors.Accent0)
+}
+
 // titleStyle creates a style for the title.
 func (m *Model) titleStyle() lipgloss.Style {
 	return lipgloss.NewStyle().
@@ -144,32 +348,17 @@ func (m *Model) renderCommands() {
 	content.WriteString("\n\n")
 
 	for _, cmd := range m.commands {
-		timestamp := m.timestampStyle().Render(fmt.Sprintf("[%s]", cmd.StartedAt.Format("15:04:05")))
-		workdir := m.workdirStyle().Render(cmd.WorkingDirectory)
-
-		// Calculate available width for command
-		commandWidth := m.maxWidth - lipgloss.Width(timestamp) - lipgloss.Width(workdir)
-
-		// Always wrap the command to ensure consistent formatting
-		wrappedCommand := wrap.String(cmd.Command, commandWidth)
-
-		// Split wrapped command into lines
-		commandLines := strings.Split(wrappedCommand, "\n")
+		content.WriteString(m.renderCommand(cmd))
+		content.WriteString("\n")
+	}
 
-		// Render first line with timestamp and workdir
-		firstLine := m.commandStyle().Width(commandWidth).Render(commandLines[0])
-		content.WriteString(fmt.Sprintf("%s%s%s", timestamp, workdir, firstLine))
+	for _, running := range m.running {
+		content.WriteString(m.renderRunning(running))
 		content.WriteString("\n")
+	}
 
-		// Render remaining lines with proper indentation
-		if len(commandLines) > 1 {
-			indent := strings.Repeat(" ", lipgloss.Width(timestamp)+lipgloss.Width(workdir))
-			for _, line := range commandLines[1:] {
-				content.WriteString(indent)
-				content.WriteString(m.commandStyle().Width(commandWidth).Render(line))
-				content.WriteString("\n")
-			}
-		}
+	if m.approval != nil {
+		content.WriteString(m.renderApproval())
 		content.WriteString("\n")
 	}
 
@@ -182,3 +371,195 @@ func (m *Model) renderCommands() {
 	output.WriteString(contentStyle.Render(content.String()))
 	m.viewport.SetContent(output.String())
 }
+
+// renderCommand formats and renders a single command.
+func (m *Model) renderCommand(cmd tool.Command) string {
+	content := strings.Builder{}
+	timestamp := m.timestampStyle().Render(fmt.Sprintf("[%s]", cmd.StartedAt.Format("15:04:05")))
+	workdir := m.workdirStyle().Render(cmd.Location())
+
+	// Calculate available width for command
+	commandWidth := m.maxWidth - lipgloss.Width(timestamp) - lipgloss.Width(workdir)
+
+	// Always wrap the command to ensure consistent formatting
+	command := cmd.Command
+	if cmd.DryRun {
+		command = dryRunPrefix + command
+	}
+	wrappedCommand := wrap.String(command, commandWidth)
+
+	// Split wrapped command into lines
+	commandLines := strings.Split(wrappedCommand, "\n")
+
+	// Render first line with timestamp and workdir
+	firstLine := m.commandStyle().Width(commandWidth).Render(commandLines[0])
+	content.WriteString(fmt.Sprintf("%s%s%s", timestamp, workdir, firstLine))
+	content.WriteString("\n")
+
+	// Render remaining lines with proper indentation
+	if len(commandLines) > 1 {
+		indent := strings.Repeat(" ", lipgloss.Width(timestamp)+lipgloss.Width(workdir))
+		for _, line := range commandLines[1:] {
+			content.WriteString(indent)
+			content.WriteString(m.commandStyle().Width(commandWidth).Render(line))
+			content.WriteString("\n")
+		}
+	}
+
+	return content.String()
+}
+
+// renderRunning formats and renders a running command with its elapsed time and latest output.
+func (m *Model) renderRunning(running *runningCommand) string {
+	content := strings.Builder{}
+	content.WriteString(m.renderCommand(running.command))
+
+	elapsed := time.Since(running.command.StartedAt).Truncate(time.Second)
+	status := fmt.Sprintf(runningStatus, m.spinner.View(), elapsed)
+	if running.cancelled {
+		status = fmt.Sprintf(cancellingStatus, m.spinner.View(), elapsed)
+	} else if running == m.cancellable() {
+		status += cancelHint
+	}
+	content.WriteString(m.runningStyle().Render(status))
+	content.WriteString("\n")
+
+	for _, line := range running.lines {
+		style := m.outputStyle()
+		if line.Stream == tool.StreamStderr {
+			style = m.stderrStyle()
+		}
+		content.WriteString(style.Render(truncate(line.Line, m.maxWidth-2)))
+		content.WriteString("\n")
+	}
+
+	return content.String()
+}
+
+// renderApproval formats and renders the command awaiting approval.
+func (m *Model) renderApproval() string {
+	content := strings.Builder{}
+
+	if m.editing {
+		m.editor.Width = m.maxWidth - lipgloss.Width(m.editor.Prompt) - 1
+		content.WriteString(m.editor.View())
+		content.WriteString("\n")
+		content.WriteString(m.approvalStyle().Render(editHint))
+		content.WriteString("\n")
+		return content.String()
+	}
+
+	hint := approvalHint
+	if classification := m.approval.Command.Classification; classification != "" {
+		hint = fmt.Sprintf("%s (%s)", approvalHint, classification)
+	}
+	if len(m.queued) > 0 {
+		hint = fmt.Sprintf(queuedHint, hint, len(m.queued))
+	}
+
+	content.WriteString(m.renderCommand(m.approval.Command))
+	content.WriteString(m.approvalStyle().Render(hint))
+	content.WriteString("\n")
+
+	return content.String()
+}
+
+// handleApprovalKey handles the key presses while a command is awaiting approval.
+func (m *Model) handleApprovalKey(msg tea.KeyMsg) tea.Cmd {
+	if m.editing {
+		switch msg.Type {
+		case tea.KeyEnter:
+			approval := tool.Approval{Decision: tool.DecisionEdited, Command: m.editor.Value()}
+			if approval.Command == m.approval.Command.Command {
+				approval = tool.Approval{Decision: tool.DecisionApproved}
+			}
+			m.respond(approval)
+		case tea.KeyEsc:
+			m.editing = false
+			m.editor.Blur()
+		default:
+			var cmd tea.Cmd
+			m.editor, cmd = m.editor.Update(msg)
+			return cmd
+		}
+
+		return nil
+	}
+
+	switch msg.String() {
+	case "a", "y":
+		m.respond(tool.Approval{Decision: tool.DecisionApproved})
+	case "r", "n":
+		m.respond(tool.Approval{Decision: tool.DecisionRejected})
+	case "e":
+		m.editing = true
+		m.editor.SetValue(m.approval.Command.Command)
+		m.editor.CursorEnd()
+		return m.editor.Focus()
+	}
+
+	return nil
+}
+
+// respond sends the approval to the pending request and moves on to the next queued request, if any.
+func (m *Model) respond(approval tool.Approval) {
+	m.approval.Response <- approval
+	m.next()
+}
+
+// dismiss removes the request with the response channel, pending or queued, once it is no longer awaited. It returns
+// false if the request was already answered.
+func (m *Model) dismiss(response chan tool.Approval) bool {
+	if m.approval != nil && m.approval.Response == response {
+		m.next()
+		return true
+	}
+
+	for i, request := range m.queued {
+		if request.Response == response {
+			m.queued = append(m.queued[:i:i], m.queued[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// next moves on to the next queued request, if any.
+func (m *Model) next() {
+	m.approval = nil
+	if len(m.queued) > 0 {
+		m.approval = &m.queued[0]
+		m.queued = m.queued[1:]
+	}
+	m.editing = false
+	m.editor.Blur()
+	m.editor.Reset()
+}
+
+// awaitApproval returns the command waiting until the approval request is no longer awaited, if it can expire.
+func awaitApproval(request tool.ApprovalRequest) tea.Cmd {
+	if request.Done == nil {
+		return nil
+	}
+
+	return func() tea.Msg {
+		<-request.Done
+		return approvalDone{response: request.Response}
+	}
+}
+
+// truncate shortens the line to the given width, so that long output lines do not wrap.
+func truncate(line string, width int) string {
+	line = strings.ReplaceAll(line, "\t", "    ")
+	if width <= 0 || lipgloss.Width(line) <= width {
+		return line
+	}
+
+	runes := []rune(line)
+	for len(runes) > 0 && lipgloss.Width(string(runes))+1 > width {
+		runes = runes[:len(runes)-1]
+	}
+
+	return string(runes) + "…"
+}
@@ -1,6 +1,8 @@
 package commandspane
 
 import (
+	"context"
+	"fmt"
 	"regexp"
 	"strings"
 	"testing"
@@ -11,6 +13,7 @@ import (
 	"github.com/datolabs-io/opsy/internal/thememanager"
 	"github.com/datolabs-io/opsy/internal/tool"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // stripANSI removes ANSI color codes from a string.
@@ -117,6 +120,18 @@ func TestView(t *testing.T) {
 	assert.Contains(t, view, "~/opsy")
 	assert.Contains(t, view, "ls -la")
 	assert.Contains(t, view, now.Format("15:04:05"))
+	assert.NotContains(t, view, "[dry-run]")
+
+	// Add command recorded in dry-run mode
+	m.Update(tool.Command{
+		Command:          "kubectl apply -f app.yaml",
+		WorkingDirectory: "~/opsy",
+		StartedAt:        now,
+		DryRun:           true,
+	})
+
+	view = stripANSI(m.View())
+	assert.Contains(t, view, "[dry-run] kubectl apply -f app.yaml")
 }
 
 // TestInit tests the initialization of the commands pane component.
@@ -214,6 +229,12 @@ func TestMultipleCommands(t *testing.T) {
 			WorkingDirectory: "~/project3",
 			StartedAt:        time.Now().Add(2 * time.Second),
 		},
+		{
+			Command:          "uptime",
+			WorkingDirectory: "/var/log",
+			Host:             "web-1",
+			StartedAt:        time.Now().Add(3 * time.Second),
+		},
 	}
 
 	for _, cmd := range commands {
@@ -225,7 +246,7 @@ func TestMultipleCommands(t *testing.T) {
 	// Verify all commands are rendered
 	for _, cmd := range commands {
 		assert.Contains(t, view, cmd.Command)
-		assert.Contains(t, view, cmd.WorkingDirectory)
+		assert.Contains(t, view, cmd.Location())
 		assert.Contains(t, view, cmd.StartedAt.Format("15:04:05"))
 	}
 
@@ -317,3 +338,267 @@ func TestThemeChange(t *testing.T) {
 		"container style should use Base01 color",
 	)
 }
+
+// TestApproval tests the approval flow of the commands pane component.
+func TestApproval(t *testing.T) {
+	newRequest := func() tool.ApprovalRequest {
+		return tool.NewApprovalRequest(context.Background(), tool.Command{
+			Command:          "kubectl delete pod test",
+			WorkingDirectory: "~/opsy",
+			StartedAt:        time.Now(),
+			Classification:   tool.ClassificationDestructive,
+		})
+	}
+
+	t.Run("renders pending approval", func(t *testing.T) {
+		m := New()
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+		m, _ = m.Update(newRequest())
+
+		assert.True(t, m.AwaitingApproval())
+		view := stripANSI(m.View())
+		assert.Contains(t, view, "kubectl delete pod test")
+		assert.Contains(t, view, "[a] Approve")
+		assert.Contains(t, view, "[r] Reject")
+		assert.Contains(t, view, "[e] Edit")
+		assert.Contains(t, view, "(destructive)")
+	})
+
+	t.Run("approves command", func(t *testing.T) {
+		m := New()
+		request := newRequest()
+		m, _ = m.Update(request)
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+
+		assert.False(t, m.AwaitingApproval())
+		assert.Equal(t, tool.Approval{Decision: tool.DecisionApproved}, <-request.Response)
+	})
+
+	t.Run("rejects command", func(t *testing.T) {
+		m := New()
+		request := newRequest()
+		m, _ = m.Update(request)
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+
+		assert.False(t, m.AwaitingApproval())
+		assert.Equal(t, tool.Approval{Decision: tool.DecisionRejected}, <-request.Response)
+	})
+
+	t.Run("edits command", func(t *testing.T) {
+		m := New()
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+		request := newRequest()
+		m, _ = m.Update(request)
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+		assert.True(t, m.editing)
+		assert.Contains(t, stripANSI(m.View()), "[enter] Run edited command")
+
+		for range len("delete pod test") {
+			m, _ = m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+		}
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("get pods")})
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+		assert.False(t, m.AwaitingApproval())
+		assert.Equal(t, tool.Approval{Decision: tool.DecisionEdited, Command: "kubectl get pods"}, <-request.Response)
+	})
+
+	t.Run("cancels editing", func(t *testing.T) {
+		m := New()
+		request := newRequest()
+		m, _ = m.Update(request)
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+
+		assert.True(t, m.AwaitingApproval())
+		assert.False(t, m.editing)
+		assert.Empty(t, request.Response)
+	})
+
+	t.Run("queues requests while a command is awaiting approval", func(t *testing.T) {
+		m := New()
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+		first, second := newRequest(), newRequest()
+		m, _ = m.Update(first)
+		m, _ = m.Update(second)
+		assert.Contains(t, stripANSI(m.View()), "[1 more waiting]")
+
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+		assert.Equal(t, tool.Approval{Decision: tool.DecisionApproved}, <-first.Response)
+		assert.True(t, m.AwaitingApproval())
+		assert.NotContains(t, stripANSI(m.View()), "more waiting")
+
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+		assert.Equal(t, tool.Approval{Decision: tool.DecisionRejected}, <-second.Response)
+		assert.False(t, m.AwaitingApproval())
+	})
+
+	t.Run("ignores other keys", func(t *testing.T) {
+		m := New()
+		request := newRequest()
+		m, _ = m.Update(request)
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+
+		assert.True(t, m.AwaitingApproval())
+		assert.Empty(t, request.Response)
+	})
+
+	t.Run("dismisses the pending request once it is no longer awaited", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		request := tool.NewApprovalRequest(ctx, tool.Command{Command: "kubectl delete pod test"})
+		m := New()
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+		m, cmd := m.Update(request)
+		require.NotNil(t, cmd)
+
+		cancel()
+		m, _ = m.Update(cmd())
+		assert.False(t, m.AwaitingApproval())
+		assert.NotContains(t, stripANSI(m.View()), "Awaiting approval")
+
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+		assert.Empty(t, request.Response)
+	})
+
+	t.Run("dismisses queued requests once they are no longer awaited", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		first, second := newRequest(), tool.NewApprovalRequest(ctx, tool.Command{Command: "kubectl delete ns test"})
+		m := New()
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+		m, _ = m.Update(first)
+		m, cmd := m.Update(second)
+		require.NotNil(t, cmd)
+		assert.Contains(t, stripANSI(m.View()), "[1 more waiting]")
+
+		cancel()
+		m, _ = m.Update(cmd())
+		assert.True(t, m.AwaitingApproval())
+		assert.NotContains(t, stripANSI(m.View()), "more waiting")
+
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+		assert.Equal(t, tool.Approval{Decision: tool.DecisionApproved}, <-first.Response)
+		assert.False(t, m.AwaitingApproval())
+		assert.Empty(t, second.Response)
+	})
+
+	t.Run("ignores answered requests once they are no longer awaited", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		first := tool.NewApprovalRequest(ctx, tool.Command{Command: "kubectl delete pod test"})
+		second := newRequest()
+		m := New()
+		m, cmd := m.Update(first)
+		m, _ = m.Update(second)
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+
+		cancel()
+		m, _ = m.Update(cmd())
+		assert.True(t, m.AwaitingApproval())
+		assert.Equal(t, second.Command, m.approval.Command)
+	})
+}
+
+// TestRunningCommands tests the live output of running commands.
+func TestRunningCommands(t *testing.T) {
+	running := tool.Command{
+		ID:               "cmd-1",
+		Command:          "helm upgrade --install api ./chart --wait",
+		WorkingDirectory: "~/opsy",
+		StartedAt:        time.Now().Add(-5 * time.Second),
+	}
+
+	t.Run("renders running command with output", func(t *testing.T) {
+		m := New()
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+		m, cmd := m.Update(tool.CommandOutput{Command: running, Started: true})
+		assert.NotNil(t, cmd, "spinner should start ticking")
+		m, cmd = m.Update(tool.CommandOutput{Command: running, Line: "Release \"api\" has been upgraded."})
+		assert.Nil(t, cmd)
+
+		view := stripANSI(m.View())
+		assert.Contains(t, view, "helm upgrade --install api ./chart --wait")
+		assert.Contains(t, view, "Running for 5s")
+		assert.Contains(t, view, "Release \"api\" has been upgraded.")
+	})
+
+	t.Run("keeps latest output lines", func(t *testing.T) {
+		m := New()
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+		for i := range maxOutputLines + 5 {
+			m, _ = m.Update(tool.CommandOutput{Command: running, Line: fmt.Sprintf("line %d", i)})
+		}
+
+		require.Len(t, m.running, 1)
+		assert.Len(t, m.running[0].lines, maxOutputLines)
+		assert.Equal(t, "line 5", m.running[0].lines[0].Line)
+	})
+
+	t.Run("renders stderr in a distinct colour", func(t *testing.T) {
+		m := New(WithTheme(thememanager.Theme{
+			BaseColors:   thememanager.BaseColors{Base01: "#222222", Base04: "#FFFFFF"},
+			AccentColors: thememanager.AccentColors{Accent0: "#FF0000"},
+		}))
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+		m, _ = m.Update(tool.CommandOutput{Command: running, Line: "upgrading", Stream: tool.StreamStdout})
+		m, _ = m.Update(tool.CommandOutput{Command: running, Line: "warning: deprecated", Stream: tool.StreamStderr})
+
+		require.Len(t, m.running, 1)
+		assert.Equal(t, tool.StreamStderr, m.running[0].lines[1].Stream)
+		assert.Equal(t, m.theme.AccentColors.Accent0, m.stderrStyle().GetForeground())
+		assert.NotEqual(t, m.outputStyle().GetForeground(), m.stderrStyle().GetForeground())
+		view := stripANSI(m.View())
+		assert.Contains(t, view, "upgrading")
+		assert.Contains(t, view, "warning: deprecated")
+	})
+
+	t.Run("replaces running command when it completes", func(t *testing.T) {
+		m := New()
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+		m, _ = m.Update(tool.CommandOutput{Command: running, Line: "waiting"})
+
+		completed := running
+		completed.Output = "waiting"
+		completed.CompletedAt = time.Now()
+		m, _ = m.Update(completed)
+
+		assert.Empty(t, m.running)
+		require.Len(t, m.commands, 1)
+		view := stripANSI(m.View())
+		assert.NotContains(t, view, "Running for")
+		assert.Contains(t, view, "helm upgrade --install api ./chart --wait")
+	})
+
+	t.Run("cancels the most recently started command", func(t *testing.T) {
+		m := New()
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+		cancelled := []string{}
+		second := running
+		second.ID, second.Command = "cmd-2", "kubectl rollout status deploy/api"
+		for _, command := range []tool.Command{running, second} {
+			m, _ = m.Update(tool.CommandOutput{Command: command, Started: true, Cancel: func() {
+				cancelled = append(cancelled, command.ID)
+			}})
+		}
+		assert.Equal(t, 1, strings.Count(stripANSI(m.View()), "[ctrl+x] Cancel"))
+
+		m, _ = m.Update(CancelCommand{})
+		assert.Equal(t, []string{"cmd-2"}, cancelled)
+		assert.Contains(t, stripANSI(m.View()), "Cancelling after 5s")
+
+		m, _ = m.Update(CancelCommand{})
+		m, _ = m.Update(CancelCommand{})
+		assert.Equal(t, []string{"cmd-2", "cmd-1"}, cancelled)
+		assert.NotContains(t, stripANSI(m.View()), "[ctrl+x] Cancel")
+	})
+
+	t.Run("stops spinner without running commands", func(t *testing.T) {
+		m := New()
+		_, cmd := m.Update(m.spinner.Tick())
+		assert.Nil(t, cmd)
+	})
+
+	t.Run("truncates long output lines", func(t *testing.T) {
+		assert.Equal(t, "short", truncate("short", 10))
+		assert.Equal(t, "a long…", truncate("a long line", 7))
+		assert.Equal(t, "a    b", truncate("a\tb", 20))
+	})
+}
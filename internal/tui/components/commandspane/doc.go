@@ -2,8 +2,9 @@
 //
 // The commands pane component displays a scrollable list of executed commands, including:
 //   - Timestamp of execution in [HH:MM:SS] format
-//   - Working directory with a distinct background
-//   - Command text in an accent color
+//   - Working directory with a distinct background, prefixed with the host for remote commands
+//   - Command text in an accent color, prefixed with [dry-run] when it was only recorded
+//   - Command awaiting approval with its classification and the available choices
 //
 // # Component Structure
 //
@@ -21,6 +22,8 @@
 //   - timestampStyle: formats the timestamp with a neutral color
 //   - workdirStyle: highlights the working directory with a distinct background
 //   - commandStyle: renders the command text in an accent color
+//   - outputStyle: renders the stdout of running commands in a neutral color
+//   - stderrStyle: renders the stderr of running commands in the command accent color
 //   - containerStyle: provides the overall pane styling with borders
 //   - titleStyle: formats the "Commands" title
 //
@@ -43,7 +46,18 @@
 //
 // The component responds to:
 //   - tea.WindowSizeMsg: Updates viewport dimensions
-//   - tool.Command: Adds new command to history
+//   - tool.Command: Adds new command to history, replacing the running command with the same ID
+//   - tool.CommandOutput: Shows a running command with a spinner, its elapsed time and latest output lines
+//   - spinner.TickMsg: Animates the spinner while commands are running
+//   - tool.ApprovalRequest: Shows the command awaiting approval; requests received meanwhile are queued and
+//     shown one after another. Requests are dismissed once their Done channel is closed, e.g. when the task is
+//     cancelled, so that keys are no longer captured for them
+//   - tea.KeyMsg: Approves (a), rejects (r) or edits (e) the command awaiting approval
+//   - CancelCommand: Cancels the most recently started running command, which shows it is cancelling until the
+//     command completes
+//
+// When editing, enter runs the edited command and esc returns to the choices.
+// The decision is sent to the request's Response channel.
 //
 // The component is built using the Bubble Tea framework and Lip Gloss styling
 // library, providing a consistent look and feel with the rest of the application.
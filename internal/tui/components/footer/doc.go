@@ -2,8 +2,9 @@
 //
 // The footer component displays important information about the application's state,
 // including:
-//   - The AI engine being used (e.g., "Anthropic")
+//   - The LLM provider being used (e.g., "Anthropic" or "OpenAI")
 //   - Model configuration (model name, max tokens, temperature)
+//   - Running totals of the tokens used and the estimated cost
 //   - Number of available tools
 //   - Current status
 //
@@ -23,6 +24,7 @@
 // The component responds to:
 //   - tea.WindowSizeMsg: Updates viewport dimensions
 //   - agent.Status: Updates the current status display
+//   - agent.Usage: Updates the tokens and cost display
 //
 // # Styling
 //
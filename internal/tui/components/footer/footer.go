@@ -1,6 +1,7 @@
 package footer
 
 import (
+	"fmt"
 	"strconv"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -17,6 +18,7 @@ type Model struct {
 	textStyle      lipgloss.Style
 	maxWidth       int
 	status         string
+	usage          agent.Usage
 }
 
 // Parameters represent the parameters of the application.
@@ -31,6 +33,13 @@ type Parameters struct {
 // Option is a function that modifies the Model.
 type Option func(*Model)
 
+const (
+	// tokensFormat is the format of the input and output tokens used by the task.
+	tokensFormat = "%s in / %s out"
+	// costFormat is the format of the estimated cost of the task.
+	costFormat = "$%.3f"
+)
+
 // New creates a new footer component.
 func New(opts ...Option) *Model {
 	m := &Model{
@@ -61,6 +70,8 @@ func (m *Model) Update(msg tea.Msg) (*Model, tea.Cmd) {
 		m.containerStyle = containerStyle(m.theme, m.maxWidth)
 	case agent.Status:
 		m.status = string(msg)
+	case agent.Usage:
+		m.usage = msg
 	}
 
 	return m, nil
@@ -70,6 +81,9 @@ func (m *Model) Update(msg tea.Msg) (*Model, tea.Cmd) {
 func (m *Model) View() string {
 	footer := m.textStyle.Bold(true).Render("Engine: ") + m.textStyle.Render(m.parameters.Engine)
 	footer += m.textStyle.Render(" | ") + m.textStyle.Bold(true).Render("Model: ") + m.textStyle.Render(m.parameters.Model)
+	footer += m.textStyle.Render(" | ") + m.textStyle.Bold(true).Render("Tokens: ") + m.textStyle.Render(fmt.Sprintf(tokensFormat,
+		formatTokens(m.usage.TotalInputTokens()), formatTokens(m.usage.OutputTokens)))
+	footer += m.textStyle.Render(" | ") + m.textStyle.Bold(true).Render("Cost: ") + m.textStyle.Render(fmt.Sprintf(costFormat, m.usage.Cost))
 	footer += m.textStyle.Render(" | ") + m.textStyle.Bold(true).Render("Temperature: ") + m.textStyle.Render(strconv.FormatFloat(m.parameters.Temperature, 'f', -1, 64))
 	footer += m.textStyle.Render(" | ") + m.textStyle.Bold(true).Render("Max Tokens: ") + m.textStyle.Render(strconv.FormatInt(m.parameters.MaxTokens, 10))
 	footer += m.textStyle.Render(" | ") + m.textStyle.Bold(true).Render("Tools: ") + m.textStyle.Render(strconv.Itoa(m.parameters.ToolsCount))
@@ -80,6 +94,18 @@ func (m *Model) View() string {
 	return m.containerStyle.Render(footer)
 }
 
+// formatTokens formats the number of tokens in a compact form, e.g. 12.3k.
+func formatTokens(tokens int64) string {
+	switch {
+	case tokens >= 1_000_000:
+		return strconv.FormatFloat(float64(tokens)/1_000_000, 'f', 1, 64) + "M"
+	case tokens >= 1_000:
+		return strconv.FormatFloat(float64(tokens)/1_000, 'f', 1, 64) + "k"
+	default:
+		return strconv.FormatInt(tokens, 10)
+	}
+}
+
 // WithTheme sets the theme for the footer component.
 func WithTheme(theme thememanager.Theme) Option {
 	return func(m *Model) {
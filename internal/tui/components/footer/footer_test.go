@@ -83,6 +83,14 @@ func TestUpdate(t *testing.T) {
 		assert.Nil(t, cmd)
 		assert.Equal(t, "Running", newModel.status)
 	})
+
+	t.Run("handles usage update", func(t *testing.T) {
+		m := New()
+		usage := agent.Usage{Requests: 1, InputTokens: 100, OutputTokens: 10, Cost: 0.5}
+		newModel, cmd := m.Update(usage)
+		assert.Nil(t, cmd)
+		assert.Equal(t, usage, newModel.usage)
+	})
 }
 
 // TestView tests the view function of the footer component.
@@ -117,6 +125,22 @@ func TestView(t *testing.T) {
 		assert.Contains(t, view, "Ready")
 	})
 
+	t.Run("renders usage", func(t *testing.T) {
+		m := New(WithParameters(Parameters{Engine: "TestEngine", Model: "TestModel"}))
+		m.maxWidth = 200
+		assert.Contains(t, stripANSI(m.View()), "Tokens: 0 in / 0 out | Cost: $0.000")
+
+		m, _ = m.Update(agent.Usage{
+			Requests:        3,
+			InputTokens:     12_000,
+			CacheReadTokens: 345,
+			OutputTokens:    1_250_000,
+			Cost:            1.2345,
+		})
+		view := stripANSI(m.View())
+		assert.Contains(t, view, "Model: TestModel | Tokens: 12.3k in / 1.2M out | Cost: $1.234")
+	})
+
 	t.Run("handles small window width", func(t *testing.T) {
 		m := New(WithParameters(Parameters{
 			Engine: "TestEngine",
@@ -246,3 +270,12 @@ func TestConcurrentAccess(t *testing.T) {
 	assert.NotEmpty(t, view)
 	assert.Contains(t, view, "Running")
 }
+
+// TestFormatTokens tests the compact formatting of token counts.
+func TestFormatTokens(t *testing.T) {
+	assert.Equal(t, "0", formatTokens(0))
+	assert.Equal(t, "999", formatTokens(999))
+	assert.Equal(t, "1.0k", formatTokens(1_000))
+	assert.Equal(t, "12.3k", formatTokens(12_345))
+	assert.Equal(t, "2.5M", formatTokens(2_500_000))
+}
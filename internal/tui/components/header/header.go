@@ -60,6 +60,11 @@ func (m *Model) View() string {
 	return m.containerStyle.Render(m.textStyle.Bold(true).Render("Task: ") + task)
 }
 
+// SetTask sets the task text in the header, e.g. when the user sends a follow-up instruction.
+func (m *Model) SetTask(task string) {
+	m.task = task
+}
+
 // WithTask returns an Option that sets the task text in the header.
 func WithTask(task string) Option {
 	return func(m *Model) {
@@ -0,0 +1,45 @@
+// Package inputpane provides an input pane component for the terminal user interface.
+//
+// The input pane lets the user send follow-up instructions once the agent has finished a task, e.g. "now do the
+// same for staging". The follow-up continues the same conversation, so the agent keeps the context of the previous
+// tasks.
+//
+// # Component Structure
+//
+// The Model type represents the input pane component and provides the following methods:
+//   - Init: Initializes the component (required by bubbletea.Model)
+//   - Update: Handles messages and updates the component state
+//   - View: Renders the component's current state
+//   - Enabled: Reports whether a follow-up instruction can be sent
+//
+// The component supports configuration through options:
+//   - WithTheme: Sets the theme for styling the component
+//
+// # Message Handling
+//
+// The component responds to:
+//   - tea.WindowSizeMsg: Updates the width of the input
+//   - agent.Status: Enables and focuses the input when the agent has finished or failed, disables it otherwise
+//   - tea.KeyMsg: Edits the instruction while the input is enabled
+//
+// Pressing enter submits a non-empty instruction: the input is cleared and disabled, and a Submitted message with
+// the instruction is returned as a command, so that the parent model can start the follow-up task.
+//
+// # Styling
+//
+// Each element is styled using dedicated styling methods:
+//   - containerStyle: provides the bordered container of the input
+//   - promptStyle: highlights the prompt with an accent color
+//   - textStyle: formats the instruction being typed
+//   - placeholderStyle: dims the hint shown in the empty input
+//
+// Example usage:
+//
+//	input := inputpane.New(inputpane.WithTheme(myTheme))
+//
+//	// Enable the input once the task has finished
+//	input, cmd := input.Update(agent.Status(agent.StatusFinished))
+//
+//	// Render the component
+//	view := input.View()
+package inputpane
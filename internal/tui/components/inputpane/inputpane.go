@@ -0,0 +1,164 @@
+package inputpane
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/datolabs-io/opsy/internal/agent"
+	"github.com/datolabs-io/opsy/internal/thememanager"
+)
+
+// Model represents the input pane component.
+type Model struct {
+	// theme defines the color scheme for the component
+	theme thememanager.Theme
+	// maxWidth is the maximum width of the component
+	maxWidth int
+	// input is the input of the follow-up instruction
+	input textinput.Model
+	// enabled indicates whether the agent is idle and a follow-up instruction can be sent
+	enabled bool
+}
+
+// Submitted is the message sent when the user submits a follow-up instruction.
+type Submitted struct {
+	// Task is the follow-up instruction.
+	Task string
+}
+
+// Option is a function that modifies the Model.
+type Option func(*Model)
+
+const (
+	// prompt is the prompt shown before the input.
+	prompt = "> "
+	// placeholderEnabled is the placeholder shown when a follow-up instruction can be sent.
+	placeholderEnabled = "Send a follow-up instruction and press enter"
+	// placeholderDisabled is the placeholder shown while the agent is running.
+	placeholderDisabled = "Waiting for the task to finish… [ctrl+t] Cancel task  [ctrl+x] Cancel command"
+)
+
+// New creates a new input pane component.
+func New(opts ...Option) *Model {
+	m := &Model{
+		input: textinput.New(),
+	}
+	m.input.Prompt = prompt
+	m.input.Placeholder = placeholderDisabled
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.input.PromptStyle = m.promptStyle()
+	m.input.TextStyle = m.textStyle()
+	m.input.PlaceholderStyle = m.placeholderStyle()
+
+	return m
+}
+
+// Init initializes the input pane component.
+func (m *Model) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles messages and updates the input pane component.
+func (m *Model) Update(msg tea.Msg) (*Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.maxWidth = msg.Width - 6
+		m.input.Width = m.maxWidth - lipgloss.Width(prompt) - 1
+	case agent.Status:
+		if msg == agent.StatusFinished || msg == agent.StatusError {
+			return m, m.enable()
+		}
+		m.disable()
+	case tea.KeyMsg:
+		if !m.enabled {
+			return m, nil
+		}
+
+		if msg.Type == tea.KeyEnter {
+			task := strings.TrimSpace(m.input.Value())
+			if task == "" {
+				return m, nil
+			}
+
+			m.input.Reset()
+			m.disable()
+			return m, func() tea.Msg { return Submitted{Task: task} }
+		}
+
+		var cmd tea.Cmd
+		m.input, cmd = m.input.Update(msg)
+		return m, cmd
+	}
+
+	return m, nil
+}
+
+// View renders the input pane component.
+func (m *Model) View() string {
+	return m.containerStyle().Render(m.input.View())
+}
+
+// Enabled returns true if a follow-up instruction can be sent.
+func (m *Model) Enabled() bool {
+	return m.enabled
+}
+
+// WithTheme returns an Option that sets the theme for the input pane.
+func WithTheme(theme thememanager.Theme) Option {
+	return func(m *Model) {
+		m.theme = theme
+	}
+}
+
+// enable enables the input and focuses it.
+func (m *Model) enable() tea.Cmd {
+	m.enabled = true
+	m.input.Placeholder = placeholderEnabled
+	return m.input.Focus()
+}
+
+// disable disables the input until the agent finishes.
+func (m *Model) disable() {
+	m.enabled = false
+	m.input.Placeholder = placeholderDisabled
+	m.input.Blur()
+}
+
+// containerStyle creates a style for the container of the input pane component.
+func (m *Model) containerStyle() lipgloss.Style {
+	return lipgloss.NewStyle().
+		Background(m.theme.BaseColors.Base01).
+		Width(m.maxWidth+4).
+		Padding(0, 2).
+		Border(lipgloss.NormalBorder(), true).
+		BorderForeground(m.theme.BaseColors.Base02).
+		BorderBackground(m.theme.BaseColors.Base00)
+}
+
+// promptStyle creates a style for the prompt of the input.
+func (m *Model) promptStyle() lipgloss.Style {
+	return lipgloss.NewStyle().
+		Foreground(m.theme.AccentColors.Accent1).
+		Background(m.theme.BaseColors.Base01).
+		Bold(true)
+}
+
+// textStyle creates a style for the text of the input.
+func (m *Model) textStyle() lipgloss.Style {
+	return lipgloss.NewStyle().
+		Foreground(m.theme.BaseColors.Base04).
+		Background(m.theme.BaseColors.Base01)
+}
+
+// placeholderStyle creates a style for the placeholder of the input.
+func (m *Model) placeholderStyle() lipgloss.Style {
+	return lipgloss.NewStyle().
+		Foreground(m.theme.BaseColors.Base03).
+		Background(m.theme.BaseColors.Base01)
+}
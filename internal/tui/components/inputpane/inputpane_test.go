@@ -0,0 +1,92 @@
+package inputpane
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/datolabs-io/opsy/internal/agent"
+	"github.com/datolabs-io/opsy/internal/thememanager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// typeText sends the text to the input pane as key presses.
+func typeText(m *Model, text string) {
+	for _, r := range text {
+		m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+// TestInputPaneCreation tests the creation of a new input pane component.
+func TestInputPaneCreation(t *testing.T) {
+	t.Run("creates disabled", func(t *testing.T) {
+		m := New()
+		require.NotNil(t, m)
+		assert.False(t, m.Enabled())
+		assert.Equal(t, placeholderDisabled, m.input.Placeholder)
+	})
+
+	t.Run("creates with theme", func(t *testing.T) {
+		theme := thememanager.Theme{BaseColors: thememanager.BaseColors{Base01: "#000000"}}
+		m := New(WithTheme(theme))
+		assert.Equal(t, theme, m.theme)
+	})
+}
+
+// TestInputPaneUpdate tests the update function of the input pane component.
+func TestInputPaneUpdate(t *testing.T) {
+	t.Run("enables when the agent finishes", func(t *testing.T) {
+		for _, status := range []agent.Status{agent.StatusFinished, agent.StatusError} {
+			m := New()
+			_, cmd := m.Update(status)
+			assert.True(t, m.Enabled(), status)
+			assert.NotNil(t, cmd)
+			assert.Equal(t, placeholderEnabled, m.input.Placeholder)
+		}
+	})
+
+	t.Run("disables when the agent runs", func(t *testing.T) {
+		m := New()
+		m.Update(agent.Status(agent.StatusFinished))
+		m.Update(agent.Status(agent.StatusRunning))
+		assert.False(t, m.Enabled())
+	})
+
+	t.Run("ignores keys while disabled", func(t *testing.T) {
+		m := New()
+		typeText(m, "hello")
+		assert.Empty(t, m.input.Value())
+	})
+
+	t.Run("submits the follow-up", func(t *testing.T) {
+		m := New()
+		m.Update(agent.Status(agent.StatusFinished))
+		typeText(m, " now do the same for staging ")
+
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		require.NotNil(t, cmd)
+		assert.Equal(t, Submitted{Task: "now do the same for staging"}, cmd())
+		assert.Empty(t, m.input.Value())
+		assert.False(t, m.Enabled())
+	})
+
+	t.Run("does not submit an empty follow-up", func(t *testing.T) {
+		m := New()
+		m.Update(agent.Status(agent.StatusFinished))
+		typeText(m, "   ")
+
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		assert.Nil(t, cmd)
+		assert.True(t, m.Enabled())
+	})
+}
+
+// TestInputPaneView tests the view function of the input pane component.
+func TestInputPaneView(t *testing.T) {
+	m := New()
+	m.Update(tea.WindowSizeMsg{Width: 100, Height: 3})
+	assert.Contains(t, m.View(), "Waiting for the task to finish")
+
+	m.Update(agent.Status(agent.StatusFinished))
+	assert.Contains(t, m.View(), "Send a follow-up instruction")
+}
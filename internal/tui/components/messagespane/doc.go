@@ -16,7 +16,9 @@
 //
 // The component responds to:
 //   - tea.WindowSizeMsg: Updates viewport dimensions and text wrapping
-//   - agent.Message: Adds a new message to the pane
+//   - agent.Message: Adds a new message to the pane, or updates a streamed message with the same ID in place
+//   - agent.ToolRun: Shows the tool below the messages with a spinner and its elapsed time while it is running;
+//     several tools are shown at once when they are executed in parallel
 //
 // Each message includes:
 //   - Timestamp in [HH:MM:SS] format
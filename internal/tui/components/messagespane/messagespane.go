@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -19,6 +21,10 @@ type Model struct {
 	maxHeight int
 	viewport  viewport.Model
 	messages  []agent.Message
+	// running are the tools which are still running, in the order they started.
+	running []agent.ToolRun
+	// spinner is shown next to the running tools.
+	spinner spinner.Model
 }
 
 // Option is a function that modifies the Model.
@@ -29,6 +35,7 @@ func New(opts ...Option) *Model {
 	m := &Model{
 		viewport: viewport.New(0, 0),
 		messages: []agent.Message{},
+		spinner:  spinner.New(spinner.WithSpinner(spinner.Dot)),
 	}
 
 	for _, opt := range opts {
@@ -38,8 +45,12 @@ func New(opts ...Option) *Model {
 	return m
 }
 
-// title is the title of the messages pane.
-const title = "Messages"
+const (
+	// title is the title of the messages pane.
+	title = "Messages"
+	// runningStatus is the status shown below a running tool.
+	runningStatus = "%s Running for %s"
+)
 
 // Init initializes the messages pane component.
 func (m *Model) Init() tea.Cmd {
@@ -58,15 +69,27 @@ func (m *Model) Update(msg tea.Msg) (*Model, tea.Cmd) {
 		m.viewport.Style = lipgloss.NewStyle().Background(m.theme.BaseColors.Base01)
 
 		// Rerender all messages with new dimensions
-		if len(m.messages) > 0 {
+		if len(m.messages) > 0 || len(m.running) > 0 {
 			m.renderMessages()
 		} else {
 			m.viewport.SetContent(m.titleStyle().Render(title))
 		}
 	case agent.Message:
-		m.messages = append(m.messages, msg)
+		m.addMessage(msg)
+		m.renderMessages()
+		m.viewport.GotoBottom()
+	case agent.ToolRun:
+		cmd = m.updateRunning(msg)
 		m.renderMessages()
 		m.viewport.GotoBottom()
+		return m, cmd
+	case spinner.TickMsg:
+		if len(m.running) == 0 {
+			return m, nil
+		}
+		m.spinner, cmd = m.spinner.Update(msg)
+		m.renderMessages()
+		return m, cmd
 	}
 
 	m.viewport, cmd = m.viewport.Update(msg)
@@ -133,6 +156,49 @@ func (m *Model) titleStyle() lipgloss.Style {
 		Width(m.maxWidth)
 }
 
+// addMessage adds the message or, if it is a new version of a streamed message, updates it in place.
+func (m *Model) addMessage(msg agent.Message) {
+	if msg.ID != "" {
+		for i := len(m.messages) - 1; i >= 0; i-- {
+			if m.messages[i].ID == msg.ID {
+				m.messages[i] = msg
+				return
+			}
+		}
+	}
+
+	m.messages = append(m.messages, msg)
+}
+
+// updateRunning tracks the tool while it is running. The spinner starts ticking when the first tool starts running.
+func (m *Model) updateRunning(run agent.ToolRun) tea.Cmd {
+	for i, running := range m.running {
+		if running.ID == run.ID {
+			m.running = append(m.running[:i], m.running[i+1:]...)
+			break
+		}
+	}
+
+	if run.Finished {
+		return nil
+	}
+
+	m.running = append(m.running, run)
+	if len(m.running) == 1 {
+		return m.spinner.Tick
+	}
+
+	return nil
+}
+
+// runningStyle creates a style for the status of running tools.
+func (m *Model) runningStyle() lipgloss.Style {
+	return lipgloss.NewStyle().
+		Foreground(m.theme.AccentColors.Accent2).
+		Background(m.theme.BaseColors.Base01).
+		Width(m.maxWidth)
+}
+
 // renderMessages formats and renders all messages
 func (m *Model) renderMessages() {
 	output := strings.Builder{}
@@ -158,6 +224,17 @@ func (m *Model) renderMessages() {
 		output.WriteString("\n")
 	}
 
+	for _, run := range m.running {
+		timestamp := m.timestampStyle().Render(fmt.Sprintf("[%s]", run.StartedAt.Format("15:04:05")))
+		authorStyle := m.authorStyle().Width(m.maxWidth - lipgloss.Width(timestamp)).Foreground(m.theme.AccentColors.Accent2)
+		elapsed := time.Since(run.StartedAt).Truncate(time.Second)
+
+		output.WriteString(fmt.Sprintf("%s%s", timestamp, authorStyle.Render(fmt.Sprintf("%s->%s:", agent.Name, run.Tool))))
+		output.WriteString("\n")
+		output.WriteString(m.runningStyle().Render(fmt.Sprintf(runningStatus, m.spinner.View(), elapsed)))
+		output.WriteString("\n")
+	}
+
 	m.viewport.SetContent(output.String())
 }
 
@@ -12,6 +12,7 @@ import (
 	"github.com/datolabs-io/opsy/internal/agent"
 	"github.com/datolabs-io/opsy/internal/thememanager"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // stripANSI removes ANSI color codes from a string.
@@ -78,6 +79,55 @@ func TestUpdate(t *testing.T) {
 	assert.Equal(t, testMsg, m.messages[0])
 }
 
+// TestStreamedMessages tests that streamed messages are updated in place.
+func TestStreamedMessages(t *testing.T) {
+	m := New()
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+	timestamp := time.Now()
+
+	m, _ = m.Update(agent.Message{ID: "msg_1-0", Message: "Analyzing", Timestamp: timestamp})
+	m, _ = m.Update(agent.Message{ID: "msg_1-0", Message: "Analyzing the pods", Timestamp: timestamp})
+	require.Len(t, m.messages, 1)
+	assert.Equal(t, "Analyzing the pods", m.messages[0].Message)
+
+	m, _ = m.Update(agent.Message{Tool: "kubectl", Message: "Listed pods", Timestamp: timestamp})
+	m, _ = m.Update(agent.Message{ID: "msg_2-0", Message: "Done", Timestamp: timestamp})
+	m, _ = m.Update(agent.Message{ID: "msg_1-0", Message: "Analyzing the pods.", Timestamp: timestamp})
+	require.Len(t, m.messages, 3)
+	assert.Equal(t, "Analyzing the pods.", m.messages[0].Message)
+	assert.Equal(t, "Done", m.messages[2].Message)
+
+	view := stripANSI(m.View())
+	assert.Contains(t, view, "Analyzing the pods.")
+	assert.Equal(t, 1, strings.Count(view, "Analyzing"))
+}
+
+// TestRunningTools tests that the tools running at the same time are shown until they finish.
+func TestRunningTools(t *testing.T) {
+	m := New()
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+	startedAt := time.Now()
+
+	m, cmd := m.Update(agent.ToolRun{ID: "toolu_1", Tool: "Kubernetes", StartedAt: startedAt})
+	assert.NotNil(t, cmd, "the spinner starts ticking with the first running tool")
+	m, cmd = m.Update(agent.ToolRun{ID: "toolu_2", Tool: "AWS", StartedAt: startedAt})
+	assert.Nil(t, cmd)
+	require.Len(t, m.running, 2)
+
+	view := stripANSI(m.View())
+	assert.Contains(t, view, "Opsy->Kubernetes:")
+	assert.Contains(t, view, "Opsy->AWS:")
+	assert.Equal(t, 2, strings.Count(view, "Running for"))
+
+	m, _ = m.Update(agent.ToolRun{ID: "toolu_1", Tool: "Kubernetes", StartedAt: startedAt, Finished: true})
+	require.Len(t, m.running, 1)
+	assert.Equal(t, "toolu_2", m.running[0].ID)
+
+	view = stripANSI(m.View())
+	assert.NotContains(t, view, "Opsy->Kubernetes:")
+	assert.Contains(t, view, "Opsy->AWS:")
+}
+
 // TestView tests the view function of the messages pane component.
 func TestView(t *testing.T) {
 	theme := thememanager.Theme{
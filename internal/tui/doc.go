@@ -1,9 +1,10 @@
 // Package tui provides the terminal user interface for the Opsy application.
 //
-// The TUI is built using the Bubble Tea framework and consists of four main components:
+// The TUI is built using the Bubble Tea framework and consists of five main components:
 //   - Header: Displays the current task and application state
 //   - Messages Pane: Shows the conversation between the user and the AI
 //   - Commands Pane: Displays executed commands and their output
+//   - Input Pane: Accepts follow-up instructions once the task has finished
 //   - Footer: Shows AI model configuration and status
 //
 // Each component is independently managed and styled, using the application's theme
@@ -12,7 +13,7 @@
 //   - Header height adjusts based on task text wrapping
 //   - Messages pane takes 2/3 of the remaining height
 //   - Commands pane takes 1/3 of the remaining height
-//   - Footer maintains a fixed height
+//   - Input pane and footer maintain a fixed height
 //
 // Example usage:
 //
@@ -21,6 +22,7 @@
 //	    tui.WithConfig(cfg),
 //	    tui.WithTask("Analyze system performance"),
 //	    tui.WithToolsCount(5),
+//	    tui.WithFollowUps(followUps),
 //	)
 //	p := tea.NewProgram(tui)
 //	if _, err := p.Run(); err != nil {
@@ -32,15 +34,37 @@
 //   - WithConfig: Sets the AI model configuration
 //   - WithTask: Sets the current task being executed
 //   - WithToolsCount: Sets the number of available tools
+//   - WithFollowUps: Sets the channel the follow-up instructions are sent to
+//   - WithCancelTask: Sets the function called to cancel the running task
 //
 // Message Handling:
 //
 // The TUI processes several types of messages:
 //   - tea.WindowSizeMsg: Triggers layout recalculation
-//   - tea.KeyMsg: Handles keyboard input (e.g., Ctrl+C for quit)
+//   - tea.KeyMsg: Handles keyboard input (e.g., Ctrl+C for quit, approval choices, follow-up instructions)
 //   - agent.Message: Updates the messages pane
+//   - agent.ToolRun: Shows the running tools in the messages pane
 //   - tool.Command: Updates the commands pane
-//   - agent.Status: Updates the footer status
+//   - tool.ApprovalRequest: Shows the command awaiting approval in the commands pane
+//   - tool.CommandOutput: Shows the live output of a running command in the commands pane
+//   - tool.TerminalRequest: Hands the terminal over to the user to run an interactive command, and responds once the
+//     command has exited
+//   - agent.Status: Updates the footer status and enables the input pane once the agent has finished
+//   - inputpane.Submitted: Shows the follow-up instruction as the current task and sends it to the follow-ups channel
+//   - agent.Usage: Updates the tokens and cost in the footer
+//
+// Follow-ups:
+//
+// Once the agent has finished or failed, the user can type a follow-up instruction, e.g. "now do the same for
+// staging", and press enter. The instruction is sent to the channel set by WithFollowUps, and the receiver continues
+// the same agent.Conversation with it, so the agent keeps the context of the previous tasks. Keys are sent to the
+// input pane unless a command is awaiting approval.
+//
+// Cancelling:
+//
+// While a task runs, Ctrl+X cancels the running command, which the agent is told about so that it can adjust its plan,
+// and Ctrl+T cancels the whole task with the function set by WithCancelTask. A follow-up instruction can be typed once
+// the task has stopped.
 //
 // Thread Safety:
 //
@@ -7,11 +7,13 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/datolabs-io/opsy/internal/agent"
 	"github.com/datolabs-io/opsy/internal/config"
+	"github.com/datolabs-io/opsy/internal/llm"
 	"github.com/datolabs-io/opsy/internal/thememanager"
 	"github.com/datolabs-io/opsy/internal/tool"
 	"github.com/datolabs-io/opsy/internal/tui/components/commandspane"
 	"github.com/datolabs-io/opsy/internal/tui/components/footer"
 	"github.com/datolabs-io/opsy/internal/tui/components/header"
+	"github.com/datolabs-io/opsy/internal/tui/components/inputpane"
 	"github.com/datolabs-io/opsy/internal/tui/components/messagespane"
 )
 
@@ -22,11 +24,22 @@ type model struct {
 	footer       *footer.Model
 	messagesPane *messagespane.Model
 	commandsPane *commandspane.Model
+	inputPane    *inputpane.Model
 	config       config.Configuration
 	task         string
 	toolsCount   int
+	followUps    chan<- string
+	cancelTask   func()
+	windowSize   tea.WindowSizeMsg
 }
 
+const (
+	// keyCancelTask is the key binding cancelling the running task.
+	keyCancelTask = "ctrl+t"
+	// keyCancelCommand is the key binding cancelling the running command.
+	keyCancelCommand = "ctrl+x"
+)
+
 // Option is a function that configures the model.
 type Option func(*model)
 
@@ -44,16 +57,18 @@ func New(opts ...Option) *model {
 		opt(m)
 	}
 
+	model := llm.ModelFromConfig(m.config)
 	m.header = header.New(header.WithTheme(*m.theme), header.WithTask(m.task))
 	m.footer = footer.New(footer.WithTheme(*m.theme), footer.WithParameters(footer.Parameters{
-		Engine:      "Anthropic",
-		Model:       m.config.Anthropic.Model,
-		MaxTokens:   m.config.Anthropic.MaxTokens,
-		Temperature: m.config.Anthropic.Temperature,
+		Engine:      model.Provider,
+		Model:       model.Name,
+		MaxTokens:   model.MaxTokens,
+		Temperature: model.Temperature,
 		ToolsCount:  m.toolsCount,
 	}))
 	m.messagesPane = messagespane.New(messagespane.WithTheme(*m.theme))
 	m.commandsPane = commandspane.New(commandspane.WithTheme(*m.theme))
+	m.inputPane = inputpane.New(inputpane.WithTheme(*m.theme))
 
 	return m
 }
@@ -65,46 +80,56 @@ func (m *model) Init() tea.Cmd {
 
 // Update handles all messages and updates the TUI
 func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var headerCmd, footerCmd, messagesCmd, commandsCmd tea.Cmd
+	var headerCmd, footerCmd, messagesCmd, commandsCmd, inputCmd tea.Cmd
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		if msg.String() == "ctrl+c" {
+		switch msg.String() {
+		case "ctrl+c":
 			return m, tea.Quit
+		case keyCancelTask:
+			if m.cancelTask != nil && !m.inputPane.Enabled() {
+				m.cancelTask()
+			}
+			return m, nil
+		case keyCancelCommand:
+			m.commandsPane, commandsCmd = m.commandsPane.Update(commandspane.CancelCommand{})
+			return m, commandsCmd
+		}
+
+		if m.commandsPane.AwaitingApproval() {
+			m.commandsPane, commandsCmd = m.commandsPane.Update(msg)
+			return m, commandsCmd
 		}
+
+		m.inputPane, inputCmd = m.inputPane.Update(msg)
+		return m, inputCmd
 	case tea.WindowSizeMsg:
-		headerHeight := int(math.Ceil(float64(lipgloss.Width(m.task))/float64(msg.Width))) * 2
-		footerHeight := lipgloss.Height(m.footer.View())
-		remainingHeight := msg.Height - headerHeight - footerHeight - 8
-
-		m.header, headerCmd = m.header.Update(tea.WindowSizeMsg{
-			Width:  msg.Width,
-			Height: headerHeight,
-		})
-		m.footer, footerCmd = m.footer.Update(tea.WindowSizeMsg{
-			Width:  msg.Width,
-			Height: footerHeight,
-		})
-		m.messagesPane, messagesCmd = m.messagesPane.Update(tea.WindowSizeMsg{
-			Width:  msg.Width,
-			Height: remainingHeight * 2 / 3,
-		})
-		m.commandsPane, commandsCmd = m.commandsPane.Update(tea.WindowSizeMsg{
-			Width:  msg.Width,
-			Height: remainingHeight * 1 / 3,
-		})
-	case agent.Message:
+		m.windowSize = msg
+		return m, m.resize()
+	case inputpane.Submitted:
+		return m, m.followUp(msg.Task)
+	case agent.Message, agent.ToolRun:
 		m.messagesPane, messagesCmd = m.messagesPane.Update(msg)
 	case tool.Command:
 		m.commandsPane, commandsCmd = m.commandsPane.Update(msg)
+	case tool.ApprovalRequest:
+		m.commandsPane, commandsCmd = m.commandsPane.Update(msg)
+	case tool.CommandOutput:
+		m.commandsPane, commandsCmd = m.commandsPane.Update(msg)
+	case tool.TerminalRequest:
+		return m, attach(msg)
+	case agent.Usage:
+		m.footer, footerCmd = m.footer.Update(msg)
 	default:
 		m.header, headerCmd = m.header.Update(msg)
 		m.footer, footerCmd = m.footer.Update(msg)
 		m.messagesPane, messagesCmd = m.messagesPane.Update(msg)
 		m.commandsPane, commandsCmd = m.commandsPane.Update(msg)
+		m.inputPane, inputCmd = m.inputPane.Update(msg)
 	}
 
-	return m, tea.Batch(headerCmd, footerCmd, messagesCmd, commandsCmd)
+	return m, tea.Batch(headerCmd, footerCmd, messagesCmd, commandsCmd, inputCmd)
 }
 
 // View renders the TUI.
@@ -113,10 +138,75 @@ func (m *model) View() string {
 		m.header.View(),
 		m.messagesPane.View(),
 		m.commandsPane.View(),
+		m.inputPane.View(),
 		m.footer.View(),
 	)
 }
 
+// resize lays out the components for the last window size. The header grows with the task, the messages pane takes
+// 2/3 and the commands pane 1/3 of the height left by the header, the input pane and the footer.
+func (m *model) resize() tea.Cmd {
+	var headerCmd, footerCmd, messagesCmd, commandsCmd, inputCmd tea.Cmd
+	width := m.windowSize.Width
+	if width <= 0 {
+		return nil
+	}
+
+	headerHeight := int(math.Ceil(float64(lipgloss.Width(m.task))/float64(width))) * 2
+	footerHeight := lipgloss.Height(m.footer.View())
+	inputHeight := lipgloss.Height(m.inputPane.View())
+	remainingHeight := m.windowSize.Height - headerHeight - footerHeight - inputHeight - 8
+
+	m.header, headerCmd = m.header.Update(tea.WindowSizeMsg{
+		Width:  width,
+		Height: headerHeight,
+	})
+	m.footer, footerCmd = m.footer.Update(tea.WindowSizeMsg{
+		Width:  width,
+		Height: footerHeight,
+	})
+	m.messagesPane, messagesCmd = m.messagesPane.Update(tea.WindowSizeMsg{
+		Width:  width,
+		Height: remainingHeight * 2 / 3,
+	})
+	m.commandsPane, commandsCmd = m.commandsPane.Update(tea.WindowSizeMsg{
+		Width:  width,
+		Height: remainingHeight * 1 / 3,
+	})
+	m.inputPane, inputCmd = m.inputPane.Update(tea.WindowSizeMsg{
+		Width:  width,
+		Height: inputHeight,
+	})
+
+	return tea.Batch(headerCmd, footerCmd, messagesCmd, commandsCmd, inputCmd)
+}
+
+// followUp shows the follow-up instruction as the current task and sends it to the follow-ups channel, so that the
+// agent continues the conversation with it.
+func (m *model) followUp(task string) tea.Cmd {
+	if m.followUps == nil {
+		return nil
+	}
+
+	m.task = task
+	m.header.SetTask(task)
+	followUps := m.followUps
+
+	return tea.Batch(m.resize(), func() tea.Msg {
+		followUps <- task
+		return nil
+	})
+}
+
+// attach hands the terminal over to the user to run the interactive command of the request, and sends the result to
+// the request's Response channel once the user has finished.
+func attach(request tool.TerminalRequest) tea.Cmd {
+	return tea.Exec(request.Session, func(err error) tea.Msg {
+		request.Response <- err
+		return nil
+	})
+}
+
 // WithTask sets the task that the agent will execute.
 func WithTask(task string) Option {
 	return func(m *model) {
@@ -138,6 +228,20 @@ func WithTheme(theme *thememanager.Theme) Option {
 	}
 }
 
+// WithFollowUps sets the channel the follow-up instructions submitted by the user are sent to.
+func WithFollowUps(followUps chan<- string) Option {
+	return func(m *model) {
+		m.followUps = followUps
+	}
+}
+
+// WithCancelTask sets the function cancelling the running task, called when the user presses ctrl+t.
+func WithCancelTask(cancel func()) Option {
+	return func(m *model) {
+		m.cancelTask = cancel
+	}
+}
+
 // WithToolsCount sets the number of tools that the agent will use.
 func WithToolsCount(toolsCount int) Option {
 	return func(m *model) {
@@ -1,11 +1,14 @@
 package tui
 
 import (
+	"context"
 	"testing"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/datolabs-io/opsy/internal/agent"
 	"github.com/datolabs-io/opsy/internal/config"
 	"github.com/datolabs-io/opsy/internal/thememanager"
+	"github.com/datolabs-io/opsy/internal/tool"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -68,6 +71,97 @@ func TestModel_Update(t *testing.T) {
 		assert.NotNil(t, cmd)
 	})
 
+	t.Run("forwards keys to pending approval", func(t *testing.T) {
+		m := New()
+		request := tool.NewApprovalRequest(context.Background(), tool.Command{Command: "ls -la"})
+		_, _ = m.Update(request)
+		assert.True(t, m.commandsPane.AwaitingApproval())
+
+		_, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+		assert.False(t, m.commandsPane.AwaitingApproval())
+		assert.Equal(t, tool.DecisionApproved, (<-request.Response).Decision)
+	})
+
+	t.Run("dismisses pending approval of a cancelled task", func(t *testing.T) {
+		m := New()
+		ctx, cancel := context.WithCancel(context.Background())
+		request := tool.NewApprovalRequest(ctx, tool.Command{Command: "ls -la"})
+		_, cmd := m.Update(request)
+		require.NotNil(t, cmd)
+
+		cancel()
+		_, _ = m.Update(cmd())
+		assert.False(t, m.commandsPane.AwaitingApproval())
+	})
+
+	t.Run("forwards running command output", func(t *testing.T) {
+		m := New()
+		_, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+		_, cmd := m.Update(tool.CommandOutput{Command: tool.Command{ID: "cmd-1", Command: "kubectl logs -f api"}, Started: true})
+		assert.NotNil(t, cmd)
+		assert.Contains(t, m.View(), "kubectl logs -f api")
+	})
+
+	t.Run("hands the terminal over for interactive commands", func(t *testing.T) {
+		m := New()
+		request := tool.NewTerminalRequest(tool.Command{Command: "aws sso login"}, nil)
+		_, cmd := m.Update(request)
+		require.NotNil(t, cmd)
+		assert.NotNil(t, cmd())
+	})
+
+	t.Run("cancels the running task", func(t *testing.T) {
+		cancelled := 0
+		m := New(WithCancelTask(func() { cancelled++ }))
+		_, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+		assert.Equal(t, 1, cancelled)
+
+		_, _ = m.Update(agent.Status(agent.StatusFinished))
+		_, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+		assert.Equal(t, 1, cancelled, "a finished task is not cancelled")
+	})
+
+	t.Run("cancels the running command", func(t *testing.T) {
+		cancelled := false
+		m := New()
+		_, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+		_, _ = m.Update(tool.CommandOutput{Command: tool.Command{ID: "cmd-1", Command: "sleep 60"}, Started: true,
+			Cancel: func() { cancelled = true }})
+
+		_, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlX})
+		assert.True(t, cancelled)
+		assert.Contains(t, m.View(), "Cancelling")
+	})
+
+	t.Run("forwards usage to footer", func(t *testing.T) {
+		m := New()
+		_, _ = m.Update(tea.WindowSizeMsg{Width: 250, Height: 50})
+		_, _ = m.Update(agent.Usage{Requests: 1, InputTokens: 1500, OutputTokens: 20, Cost: 0.01})
+		assert.Contains(t, m.View(), "1.5k in / 20 out")
+	})
+
+	t.Run("sends follow-ups once the agent finishes", func(t *testing.T) {
+		followUps := make(chan string, 1)
+		m := New(WithTask("deploy to production"), WithFollowUps(followUps))
+		_, _ = m.Update(tea.WindowSizeMsg{Width: 100, Height: 50})
+
+		_, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+		assert.False(t, m.inputPane.Enabled())
+
+		_, _ = m.Update(agent.Status(agent.StatusFinished))
+		assert.True(t, m.inputPane.Enabled())
+		_, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("now staging")})
+		_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		require.NotNil(t, cmd)
+
+		_, cmd = m.Update(cmd())
+		require.NotNil(t, cmd)
+		go cmd()
+		assert.Equal(t, "now staging", <-followUps)
+		assert.Equal(t, "now staging", m.task)
+		assert.Contains(t, m.View(), "now staging")
+	})
+
 	t.Run("handle window size message", func(t *testing.T) {
 		m := New()
 		updatedModel, _ := m.Update(tea.WindowSizeMsg{